@@ -0,0 +1,242 @@
+package POI
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Minute is the number of minutes since midnight, 1440 represents the end of a day
+type Minute uint16
+
+const (
+	MinutesPerHour = 60
+	MinutesPerDay  = Minute(24 * MinutesPerHour)
+)
+
+// MinuteInterval is a time interval within a day with minute precision, the end is exclusive
+type MinuteInterval struct {
+	Start Minute `json:"start"`
+	End   Minute `json:"end"`
+}
+
+// Inclusive returns true if the current interval includes the new interval in the parameter
+func (interval MinuteInterval) Inclusive(newInterval MinuteInterval) bool {
+	return newInterval.Start >= interval.Start && newInterval.End <= interval.End
+}
+
+// ToMinuteInterval converts an hourly time interval to minute precision
+func (interval *TimeInterval) ToMinuteInterval() MinuteInterval {
+	return MinuteInterval{Start: Minute(interval.Start) * MinutesPerHour, End: Minute(interval.End) * MinutesPerHour}
+}
+
+// DailyOpeningHours describes when a place is open in a day
+// Intervals include the hours spilled over from the previous day for places open past midnight
+type DailyOpeningHours struct {
+	Intervals   []MinuteInterval `json:"intervals"`
+	Closed      bool             `json:"closed"`        // the place does not open on this day
+	Open24Hours bool             `json:"open_24_hours"` // the place is open for the whole day
+	Unavailable bool             `json:"unavailable"`   // no opening hours data for this day, assumed to be open
+}
+
+// WeeklyOpeningHours is indexed by Weekday, starting from Monday
+type WeeklyOpeningHours [7]DailyOpeningHours
+
+// IsOpenDuring returns true if the place is open during the entire interval on the given day
+// days without opening hours data are assumed to be open, callers should not check Unavailable on their own
+func (hours *WeeklyOpeningHours) IsOpenDuring(day Weekday, interval MinuteInterval) bool {
+	dailyHours := hours[day]
+	if dailyHours.Unavailable {
+		return true
+	}
+	for _, openingInterval := range dailyHours.Intervals {
+		if openingInterval.Inclusive(interval) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWeeklyOpeningHours parses opening hours of all weekdays in the format of Google Maps weekday text
+// hours past midnight are moved to the next day, and Sunday spills over to Monday.
+// Days that cannot be parsed are closed and the first parsing error is returned.
+func ParseWeeklyOpeningHours(hours [7]string) (weeklyHours WeeklyOpeningHours, err error) {
+	spills := make([][]MinuteInterval, len(hours))
+	for day, openingHour := range hours {
+		var parseErr error
+		weeklyHours[day], spills[day], parseErr = ParseDailyOpeningHours(openingHour)
+		if parseErr != nil && err == nil {
+			err = parseErr
+		}
+	}
+	for day := range hours {
+		nextDay := (day + 1) % len(hours)
+		if len(spills[day]) == 0 || weeklyHours[nextDay].Unavailable {
+			continue
+		}
+		weeklyHours[nextDay].Intervals = mergeMinuteIntervals(append(weeklyHours[nextDay].Intervals, spills[day]...))
+		// a day marked closed is still open for the hours spilled over from the previous night
+		weeklyHours[nextDay].Closed = false
+	}
+	return
+}
+
+var (
+	amPmPattern        = regexp.MustCompile(`(?i)\b([ap])\.?\s?m\b\.?`)
+	timeRangeSeparator = regexp.MustCompile(`\s*(?:[‐‑‒–—―~-]|\bto\b)\s*`)
+	clockTimePattern   = regexp.MustCompile(`^(\d{1,2})(?:[:.h](\d{2}))?\s*(am|pm)?$`)
+	// remarks such as "(closed for lunch 12–1)" are not time ranges
+	remarkPattern = regexp.MustCompile(`\s*\([^)]*\)`)
+	// Google Maps uses different kinds of white spaces in opening hours of different locales
+	spaceReplacer = strings.NewReplacer("\u202f", " ", "\u2009", " ", "\u00a0", " ")
+)
+
+// ParseDailyOpeningHours parses opening hours of a day with one or more time ranges such as
+// "Monday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM", "Friday: 18:00–02:00", "Sunday: Closed" and "Open 24 hours".
+// The hours past midnight of a time range are returned as spill, which belong to the next day.
+func ParseDailyOpeningHours(openingHour string) (hours DailyOpeningHours, spill []MinuteInterval, err error) {
+	hours.Intervals = make([]MinuteInterval, 0)
+	openingHour = spaceReplacer.Replace(openingHour)
+	// remove weekday prefix
+	if idx := strings.Index(openingHour, ": "); idx >= 0 {
+		openingHour = openingHour[idx+2:]
+	}
+	openingHour = strings.ToLower(strings.TrimSpace(remarkPattern.ReplaceAllString(openingHour, "")))
+
+	switch {
+	case openingHour == "":
+		hours.Unavailable = true
+		return
+	case openingHour == "closed":
+		hours.Closed = true
+		return
+	case strings.Contains(openingHour, "24 hours") || strings.Contains(openingHour, "24h"):
+		hours.Open24Hours = true
+		hours.Intervals = append(hours.Intervals, MinuteInterval{Start: 0, End: MinutesPerDay})
+		return
+	}
+
+	openingHour = amPmPattern.ReplaceAllString(openingHour, "${1}m")
+	for _, timeRange := range strings.FieldsFunc(openingHour, func(r rune) bool { return r == ',' || r == ';' }) {
+		times := timeRangeSeparator.Split(strings.TrimSpace(timeRange), -1)
+		if len(times) != 2 {
+			err = errors.New("cannot parse opening hour: " + timeRange)
+			return DailyOpeningHours{Intervals: make([]MinuteInterval, 0)}, nil, err
+		}
+		var start, end Minute
+		if start, end, err = parseTimeRange(times[0], times[1]); err != nil {
+			return DailyOpeningHours{Intervals: make([]MinuteInterval, 0)}, nil, err
+		}
+
+		switch {
+		case end == start:
+			hours.Intervals = append(hours.Intervals, MinuteInterval{Start: 0, End: MinutesPerDay})
+		case end < start: // late night hours
+			hours.Intervals = append(hours.Intervals, MinuteInterval{Start: start, End: MinutesPerDay})
+			if end > 0 {
+				spill = append(spill, MinuteInterval{Start: 0, End: end})
+			}
+		default:
+			hours.Intervals = append(hours.Intervals, MinuteInterval{Start: start, End: end})
+		}
+	}
+	hours.Intervals = mergeMinuteIntervals(hours.Intervals)
+	hours.Open24Hours = len(hours.Intervals) == 1 && hours.Intervals[0] == MinuteInterval{Start: 0, End: MinutesPerDay}
+	return
+}
+
+// ParseTimeIntervals parses opening hours of a day into hourly time intervals sorted by start time.
+// Start of a range is rounded up and end of a range is rounded down to whole hours so that a place is open during the
+// entire interval. Hours past midnight are dropped, use ParseWeeklyOpeningHours to keep them.
+func ParseTimeIntervals(openingHour string) (intervals []TimeInterval, err error) {
+	hours, _, err := ParseDailyOpeningHours(openingHour)
+	if err != nil {
+		return nil, err
+	}
+	intervals = make([]TimeInterval, 0)
+	for _, interval := range hours.Intervals {
+		start := (interval.Start + MinutesPerHour - 1) / MinutesPerHour
+		end := interval.End / MinutesPerHour
+		if start < end {
+			intervals = append(intervals, TimeInterval{Start: Hour(start), End: Hour(end)})
+		}
+	}
+	return
+}
+
+// parseTimeRange converts start and end time of a range to minutes since midnight
+// the start time inherits AM/PM from the end time if omitted, e.g. "5:00 – 10:00 PM"
+func parseTimeRange(startTime string, endTime string) (start Minute, end Minute, err error) {
+	end, endAmPm, err := parseClockTime(endTime, "")
+	if err != nil {
+		return
+	}
+	start, _, err = parseClockTime(startTime, endAmPm)
+	if err != nil {
+		return
+	}
+	// "11:00 – 2:00 PM" means 11:00 AM to 2:00 PM
+	if endAmPm == "pm" && start > end && start >= 12*MinutesPerHour && !strings.Contains(startTime, "m") {
+		start -= 12 * MinutesPerHour
+	}
+	// midnight as the end of a range
+	if end == 0 {
+		end = MinutesPerDay
+	}
+	return
+}
+
+// parseClockTime converts a time such as "9:30 pm" or "21:30" to minutes since midnight
+// defaultAmPm is used if the time does not specify AM or PM
+func parseClockTime(clockTime string, defaultAmPm string) (minutes Minute, amPm string, err error) {
+	matches := clockTimePattern.FindStringSubmatch(strings.TrimSpace(clockTime))
+	if matches == nil {
+		err = errors.New("cannot parse time: " + clockTime)
+		return
+	}
+	hour, _ := strconv.Atoi(matches[1])
+	minute := 0
+	if matches[2] != "" {
+		minute, _ = strconv.Atoi(matches[2])
+	}
+	amPm = matches[3]
+	if amPm == "" {
+		amPm = defaultAmPm
+	}
+	if minute > 59 || hour > 24 || (hour == 24 && minute > 0) || (amPm != "" && (hour == 0 || hour > 12)) {
+		err = errors.New("invalid time: " + clockTime)
+		return
+	}
+	switch amPm {
+	case "am":
+		hour %= 12
+	case "pm":
+		hour = hour%12 + 12
+	}
+	minutes = Minute(hour*MinutesPerHour + minute)
+	return
+}
+
+// mergeMinuteIntervals sorts intervals by start time and merges overlapping or adjacent intervals
+func mergeMinuteIntervals(intervals []MinuteInterval) []MinuteInterval {
+	if len(intervals) == 0 {
+		return intervals
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+	merged := []MinuteInterval{intervals[0]}
+	for _, interval := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if interval.Start <= last.End {
+			if interval.End > last.End {
+				last.End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
@@ -0,0 +1,130 @@
+package POI
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	weekdayNames = [7]string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	osmWeekdays  = map[string]Weekday{
+		"mo": DateMonday, "tu": DateTuesday, "we": DateWednesday, "th": DateThursday,
+		"fr": DateFriday, "sa": DateSaturday, "su": DateSunday,
+	}
+	// public and school holidays
+	osmHolidays = map[string]bool{"ph": true, "sh": true}
+
+	osmWeekdaySelectorPattern = regexp.MustCompile(`^([A-Za-z]{2}(?:\s*[-,]\s*[A-Za-z]{2})*)(?:\s+|$)(.*)$`)
+	osmTimeRangePattern       = regexp.MustCompile(`^(\d{1,2}):(\d{2})\s*-\s*(\d{1,2}):(\d{2})$`)
+)
+
+// ParseOSMOpeningHours converts an OpenStreetMap opening_hours value such as "Mo-Fr 09:00-17:00; Sa 10:00-14:00; Su off"
+// to opening hours of each weekday starting from Monday in the format of Google Maps weekday text, e.g. "Monday: 09:00–17:00".
+// Later rules override earlier rules for the days they select, and days not selected by any rule are closed.
+// Empty values give empty hours, and values with selectors other than weekdays, such as months, cannot be parsed.
+func ParseOSMOpeningHours(openingHours string) (hours [7]string, err error) {
+	openingHours = strings.TrimSpace(openingHours)
+	if openingHours == "" {
+		return
+	}
+	if openingHours == "24/7" {
+		for day := range hours {
+			hours[day] = weekdayNames[day] + ": Open 24 hours"
+		}
+		return
+	}
+
+	var dailyHours [7]string
+	// fallback rules are treated as normal rules
+	for _, rule := range strings.Split(strings.ReplaceAll(openingHours, "||", ";"), ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		days, timeSelector, parseErr := parseOSMWeekdaySelector(rule)
+		if parseErr != nil {
+			return [7]string{}, parseErr
+		}
+		var dayHours string
+		if dayHours, parseErr = parseOSMTimeSelector(timeSelector); parseErr != nil {
+			return [7]string{}, parseErr
+		}
+		for _, day := range days {
+			dailyHours[day] = dayHours
+		}
+	}
+
+	for day, dayHours := range dailyHours {
+		if dayHours == "" {
+			dayHours = "Closed"
+		}
+		hours[day] = weekdayNames[day] + ": " + dayHours
+	}
+	return
+}
+
+// parseOSMWeekdaySelector returns the days selected by a rule and the rest of the rule
+// rules without weekday selectors select every day, and holidays are ignored
+func parseOSMWeekdaySelector(rule string) (days []Weekday, timeSelector string, err error) {
+	matches := osmWeekdaySelectorPattern.FindStringSubmatch(rule)
+	if matches == nil {
+		return []Weekday{DateMonday, DateTuesday, DateWednesday, DateThursday, DateFriday, DateSaturday, DateSunday}, rule, nil
+	}
+	timeSelector = strings.TrimSpace(matches[2])
+	for _, daysRange := range strings.Split(matches[1], ",") {
+		bounds := strings.Split(daysRange, "-")
+		first, firstExists := osmWeekdays[strings.ToLower(strings.TrimSpace(bounds[0]))]
+		if len(bounds) == 1 && osmHolidays[strings.ToLower(strings.TrimSpace(bounds[0]))] {
+			continue
+		}
+		if !firstExists || len(bounds) > 2 {
+			return nil, "", errors.New("cannot parse opening hours rule: " + rule)
+		}
+		last := first
+		if len(bounds) == 2 {
+			var lastExists bool
+			if last, lastExists = osmWeekdays[strings.ToLower(strings.TrimSpace(bounds[1]))]; !lastExists {
+				return nil, "", errors.New("cannot parse opening hours rule: " + rule)
+			}
+		}
+		// ranges such as Fr-Mo wrap around Sunday
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return
+}
+
+// parseOSMTimeSelector converts time ranges such as "11:30-14:30,17:00-02:00" to "11:30–14:30, 17:00–02:00"
+func parseOSMTimeSelector(timeSelector string) (string, error) {
+	switch strings.ToLower(timeSelector) {
+	case "off", "closed":
+		return "Closed", nil
+	case "24/7", "00:00-24:00":
+		return "Open 24 hours", nil
+	}
+	timeRanges := strings.Split(timeSelector, ",")
+	for idx, timeRange := range timeRanges {
+		matches := osmTimeRangePattern.FindStringSubmatch(strings.TrimSpace(timeRange))
+		if matches == nil {
+			return "", errors.New("cannot parse opening hours time range: " + timeRange)
+		}
+		startHour, _ := strconv.Atoi(matches[1])
+		endHour, _ := strconv.Atoi(matches[3])
+		if startHour > 24 || endHour > 48 {
+			return "", errors.New("invalid opening hours time range: " + timeRange)
+		}
+		// hours past midnight such as 26:00 are hours of the next day
+		if endHour > 24 {
+			endHour -= 24
+		}
+		timeRanges[idx] = fmt.Sprintf("%02d:%s–%02d:%s", startHour, matches[2], endHour, matches[4])
+	}
+	return strings.Join(timeRanges, ", "), nil
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"reflect"
 	"regexp"
+	"time"
 
 	"googlemaps.github.io/maps"
 )
@@ -20,6 +21,11 @@ const (
 	DateSunday
 )
 
+// GetWeekday converts the day of week of a date to a Weekday, which starts from Monday
+func GetWeekday(date time.Time) Weekday {
+	return Weekday((int(date.Weekday()) + 6) % 7)
+}
+
 type PlacePhoto struct {
 	// reference from Google Images
 	Reference string `bson:"reference"`
@@ -81,10 +87,20 @@ func (place *Place) GetStatus() BusinessStatus {
 	return place.Status
 }
 
+// IsClosed returns true if the place is closed temporarily or permanently
+func (place *Place) IsClosed() bool {
+	return place.Status == ClosedTemporarily || place.Status == ClosedPermanently
+}
+
 func (place *Place) GetHour(day Weekday) string {
 	return place.Hours[day]
 }
 
+// GetOpeningHours parses opening hours of all weekdays with minute precision
+func (place *Place) GetOpeningHours() (WeeklyOpeningHours, error) {
+	return ParseWeeklyOpeningHours(place.Hours)
+}
+
 func (place *Place) GetID() string {
 	return place.ID
 }
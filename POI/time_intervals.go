@@ -2,10 +2,7 @@ package POI
 
 import (
 	"errors"
-	"github.com/weihesdlegend/Vacation-planner/utils"
-	"regexp"
 	"strconv"
-	"strings"
 )
 
 type Hour uint8
@@ -87,43 +84,24 @@ func (timeIntervals *GoogleMapsTimeIntervals) NumIntervals() int {
 	return timeIntervals.numIntervals
 }
 
-// given a string of form "Monday: 10:45 PM - 11:78 AM", return a TimeInterval with start and end hour in [0-24]
+// ParseTimeInterval returns the first opening interval of a day in whole hours
+// start is rounded up and end is rounded down so that a place is open during the entire interval
+// Deprecated: use ParseDailyOpeningHours which keeps minutes, split shifts and overnight hours
 func ParseTimeInterval(openingHour string) (interval TimeInterval, err error) {
-	closed, _ := regexp.Match(`Closed`, []byte(openingHour))
-	if closed {
-		interval.Start = 255
-		interval.End = 255
+	hours, _, err := ParseDailyOpeningHours(openingHour)
+	if err != nil {
 		return
 	}
-	hour_re := regexp.MustCompile(`[\d]{1,2}:[\d]{2}`)
-	hours := hour_re.FindAll([]byte(openingHour), -1)
-
-	am_pm_re := regexp.MustCompile(`[apAP][mM]`)
-	am_pm := am_pm_re.FindAll([]byte(openingHour), -1)
-
-	if len(hours) < 2 || len(am_pm) < 2 {
+	if hours.Unavailable {
 		return TimeInterval{}, errors.New("cannot parse opening hour")
 	}
-
-	interval.Start = Hour(calculateHour(string(hours[0]), string(am_pm[0])))
-	interval.End = Hour(calculateHour(string(hours[1]), string(am_pm[1])))
-
-	if interval.Start > interval.End { // late night hours
-		interval.End = 24
+	if hours.Closed || len(hours.Intervals) == 0 {
+		interval.Start = 255
+		interval.End = 255
+		return
 	}
-
+	first := hours.Intervals[0]
+	interval.Start = Hour((first.Start + MinutesPerHour - 1) / MinutesPerHour)
+	interval.End = Hour(first.End / MinutesPerHour)
 	return
 }
-
-func calculateHour(time string, am_pm string) uint8 {
-	t := strings.Split(time, ":")
-	hour, err := strconv.ParseUint(t[0], 10, 8)
-	utils.LogErrorWithLevel(err, utils.LogError)
-
-	if am_pm == "AM" || am_pm == "am" {
-		return uint8(hour)
-	} else if am_pm == "PM" || am_pm == "pm" {
-		return uint8(hour) + 12
-	}
-	return 255 // err
-}
@@ -0,0 +1,84 @@
+// Command catalogue exports the place catalogue of a Redis to an NDJSON or GeoJSON bundle, or imports a bundle into a Redis
+//
+//	catalogue -export chicago.ndjson -city chicago -country usa
+//	REDISCLOUD_URL=redis://localhost:6379 catalogue -import chicago.ndjson
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/url"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func main() {
+	redisURL := flag.String("redis-url", os.Getenv("REDISCLOUD_URL"), "URL of the Redis, REDISCLOUD_URL by default")
+	exportFile := flag.String("export", "", "file of the exported bundle, - for stdout")
+	importFile := flag.String("import", "", "file of the bundle to import, - for stdin")
+	format := flag.String("format", iowrappers.CatalogueFormatNDJSON, "format of the exported bundle, ndjson or geojson")
+	city := flag.String("city", "", "export places within the radius of the city")
+	country := flag.String("country", "", "country of the city")
+	radius := flag.Uint64("radius", iowrappers.DefaultCatalogueCityRadius, "radius around the city in meters")
+	bbox := flag.String("bbox", "", "export places within the bounding box of min_lng,min_lat,max_lng,max_lat")
+	flag.Parse()
+
+	if (*exportFile == "") == (*importFile == "") {
+		log.Fatal("exactly one of -export and -import is required")
+	}
+	parsedRedisURL, err := url.Parse(*redisURL)
+	if err != nil || *redisURL == "" {
+		log.Fatalf("invalid Redis URL %q", *redisURL)
+	}
+	iowrappers.CreateLogger()
+	redisClient := iowrappers.CreateRedisClient(parsedRedisURL)
+	ctx := context.Background()
+
+	if *importFile != "" {
+		var reader io.Reader = os.Stdin
+		if *importFile != "-" {
+			bundle, err := os.Open(*importFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer bundle.Close()
+			reader = bundle
+		}
+		stats, err := redisClient.ImportCatalogue(ctx, reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("imported %d places, %d cities and %d aliases", stats.Places, stats.Cities, stats.Aliases)
+		return
+	}
+
+	filter := iowrappers.CatalogueFilter{}
+	if *city != "" {
+		filter = iowrappers.CatalogueFilter{City: *city, Country: *country, Radius: *radius}
+	}
+	if *bbox != "" {
+		if *city != "" {
+			log.Fatal("-bbox cannot be used with -city")
+		}
+		if filter.BoundingBox, err = iowrappers.ParseBoundingBox(*bbox); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var writer io.Writer = os.Stdout
+	if *exportFile != "-" {
+		bundle, err := os.Create(*exportFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bundle.Close()
+		writer = bundle
+	}
+	stats, err := redisClient.ExportCatalogue(ctx, writer, *format, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("exported %d places, %d cities and %d aliases", stats.Places, stats.Cities, stats.Aliases)
+}
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"context"
 	"github.com/mpraski/clusters"
 	"github.com/weihesdlegend/Vacation-planner/POI"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
@@ -51,7 +52,7 @@ func (placeManager *ClustersManager) PlaceSearch(location string, searchRadius u
 	}
 	request.MinNumResults = 20
 
-	placeManager.places, _ = placeManager.Client.NearbySearch(nil, &request)
+	placeManager.places, _ = placeManager.Client.NearbySearch(context.Background(), &request)
 
 	locationData := make([][]float64, len(placeManager.places))
 	for idx, place := range placeManager.places {
@@ -12,6 +12,7 @@ import (
 	"bytes"
 	"log"
 	"math"
+	"sort"
 	"strings"
 )
 
@@ -34,6 +35,24 @@ func (tree *MinSpanningTree) PreOrderTraversal(nodes map[string]*Vertex) string
 		strings.Trim(dfs(m[tree.Root.Name], m), "->")
 }
 
+// PreOrder returns names of vertexes in the pre-order traversal of the min spanning tree
+// children are visited in the order of their names so that the result is deterministic
+func (tree *MinSpanningTree) PreOrder(nodes map[string]*Vertex) []string {
+	m := findChildren(nodes)
+	names := make([]string, 0, len(m))
+	var visit func(node *treeNode)
+	visit = func(node *treeNode) {
+		names = append(names, node.name)
+		children := append([]string(nil), node.Children...)
+		sort.Strings(children)
+		for _, child := range children {
+			visit(m[child])
+		}
+	}
+	visit(m[tree.Root.Name])
+	return names
+}
+
 func dfs(subtree *treeNode, m map[string]*treeNode) string {
 	if len(subtree.Children) == 0 {
 		return subtree.name
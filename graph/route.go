@@ -0,0 +1,199 @@
+package graph
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	// routes with at most this number of movable points are solved exactly with dynamic programming
+	ExactRouteMaxPoints = 10
+	// maximum number of improving passes of 2-opt
+	twoOptMaxPasses = 100
+)
+
+// Compatibility reports whether a point can be visited at a position of the route
+type Compatibility func(point int, position int) bool
+
+// OptimizeRoute finds a short path visiting all points once, where point i is originally visited at position i.
+// Pinned points keep their positions, and the other points are reordered among the remaining positions
+// if compatible allows them to be visited at the new positions. A nil compatible allows all positions.
+// It returns the point visited at each position and the total distance of the path in meters.
+func OptimizeRoute(points []Point, pinned []bool, compatible Compatibility) (order []int, totalDistance float64) {
+	if compatible == nil {
+		compatible = func(int, int) bool { return true }
+	}
+	route := routeProblem{points: points, pinned: pinned, compatible: compatible}
+	for idx := range points {
+		if !pinned[idx] {
+			route.free = append(route.free, idx)
+		}
+	}
+
+	if len(route.free) <= ExactRouteMaxPoints {
+		order = route.exactOrder()
+	} else {
+		order = route.approximateOrder()
+	}
+	return order, route.distance(order)
+}
+
+type routeProblem struct {
+	points     []Point
+	pinned     []bool
+	compatible Compatibility
+	free       []int // points that can be reordered, which are also the positions they can take
+}
+
+func (route *routeProblem) distance(order []int) (totalDistance float64) {
+	for idx := 1; idx < len(order); idx++ {
+		totalDistance += route.points[order[idx-1]].dist(route.points[order[idx]])
+	}
+	return
+}
+
+// feasible checks if the free points in the sequence can be visited at the free positions in order
+func (route *routeProblem) feasible(freeSequence []int) bool {
+	for idx, point := range freeSequence {
+		if !route.compatible(point, route.free[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// toOrder puts the sequence of free points to the free positions of the route
+func (route *routeProblem) toOrder(freeSequence []int) []int {
+	order := make([]int, len(route.points))
+	for idx := range order {
+		order[idx] = idx
+	}
+	for idx, point := range freeSequence {
+		order[route.free[idx]] = point
+	}
+	return order
+}
+
+// exactOrder runs a Held-Karp style dynamic programming over positions
+// a state is the set of free points visited so far and the last visited point
+func (route *routeProblem) exactOrder() []int {
+	type state struct {
+		visited uint
+		last    int
+	}
+	type record struct {
+		distance float64
+		previous state
+		point    int
+	}
+
+	freeIndexes := make(map[int]int, len(route.free))
+	for idx, point := range route.free {
+		freeIndexes[point] = idx
+	}
+
+	initial := state{last: -1}
+	layers := make([]map[state]record, len(route.points)+1)
+	layers[0] = map[state]record{initial: {}}
+	for position := range route.points {
+		layers[position+1] = make(map[state]record)
+		for current, currentRecord := range layers[position] {
+			candidates := []int{position}
+			if !route.pinned[position] {
+				candidates = route.free
+			}
+			for _, point := range candidates {
+				next := current
+				if !route.pinned[position] {
+					bit := uint(1) << uint(freeIndexes[point])
+					if current.visited&bit != 0 || !route.compatible(point, position) {
+						continue
+					}
+					next.visited |= bit
+				}
+				next.last = point
+				distance := currentRecord.distance
+				if current.last >= 0 {
+					distance += route.points[current.last].dist(route.points[point])
+				}
+				if existing, exists := layers[position+1][next]; !exists || distance < existing.distance {
+					layers[position+1][next] = record{distance: distance, previous: current, point: point}
+				}
+			}
+		}
+	}
+
+	best, bestDistance := state{}, math.Inf(1)
+	for current, currentRecord := range layers[len(route.points)] {
+		if bits.OnesCount(current.visited) == len(route.free) && currentRecord.distance < bestDistance {
+			best, bestDistance = current, currentRecord.distance
+		}
+	}
+	if math.IsInf(bestDistance, 1) {
+		return route.toOrder(route.free)
+	}
+
+	order := make([]int, len(route.points))
+	for position := len(route.points); position > 0; position-- {
+		currentRecord := layers[position][best]
+		order[position-1] = currentRecord.point
+		best = currentRecord.previous
+	}
+	return order
+}
+
+// approximateOrder starts from the pre-order traversal of the min spanning tree of free points,
+// which is at most twice as long as the optimal tour, and improves it with 2-opt
+func (route *routeProblem) approximateOrder() []int {
+	vertexes := make([]*Vertex, len(route.free))
+	for idx, point := range route.free {
+		location := route.points[point]
+		vertexes[idx] = &Vertex{Name: strconv.Itoa(point), Location: location}
+	}
+	GenerateGraph(vertexes, false)
+	tree := MinSpanningTree{Root: vertexes[0]}
+	treeSequence := make([]int, 0, len(route.free))
+	for _, name := range tree.PreOrder(tree.Construct(vertexes)) {
+		point, _ := strconv.Atoi(name)
+		treeSequence = append(treeSequence, point)
+	}
+
+	bestOrder := route.toOrder(route.twoOpt(append([]int(nil), route.free...)))
+	if route.feasible(treeSequence) {
+		treeOrder := route.toOrder(route.twoOpt(treeSequence))
+		if route.distance(treeOrder) < route.distance(bestOrder) {
+			bestOrder = treeOrder
+		}
+	}
+	return bestOrder
+}
+
+// twoOpt reverses segments of the free points as long as the route gets shorter
+func (route *routeProblem) twoOpt(freeSequence []int) []int {
+	bestDistance := route.distance(route.toOrder(freeSequence))
+	for pass := 0; pass < twoOptMaxPasses; pass++ {
+		improved := false
+		for i := 0; i < len(freeSequence)-1; i++ {
+			for j := i + 1; j < len(freeSequence); j++ {
+				reverse(freeSequence, i, j)
+				if distance := route.distance(route.toOrder(freeSequence)); distance < bestDistance && route.feasible(freeSequence) {
+					bestDistance = distance
+					improved = true
+				} else {
+					reverse(freeSequence, i, j)
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return freeSequence
+}
+
+func reverse(sequence []int, i int, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		sequence[i], sequence[j] = sequence[j], sequence[i]
+	}
+}
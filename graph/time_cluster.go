@@ -101,13 +101,10 @@ func (placeManager *TimeClustersManager) Clustering(day POI.Weekday) {
 }
 
 func (placeManager *TimeClustersManager) assign(place *POI.Place, day POI.Weekday) {
-	openingHour := place.GetHour(day) // get opening hour for a weekday
-	openingInterval, err := POI.ParseTimeInterval(openingHour)
-	if err != nil {
-		return
-	}
+	// days without opening hours data are assumed to be open, days that cannot be parsed are closed
+	openingHours, _ := place.GetOpeningHours()
 	for _, interval := range *placeManager.TimeClusters.TimeIntervals.GetAllIntervals() {
-		if openingInterval.Inclusive(&interval) {
+		if openingHours.IsOpenDuring(day, interval.ToMinuteInterval()) {
 			clusterKey := interval.Serialize()
 			clusterPlaces := &placeManager.TimeClusters.Clusters[clusterKey].Places
 			*clusterPlaces = append(*clusterPlaces, *place)
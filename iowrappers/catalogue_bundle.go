@@ -0,0 +1,541 @@
+package iowrappers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+// bundles of the place catalogue are a header followed by records of cities, aliases and places,
+// either as newline-delimited JSON or as a GeoJSON feature collection of places
+const (
+	CatalogueBundleFormat  = "vacation-planner-catalogue"
+	CatalogueBundleVersion = 1
+
+	CatalogueFormatNDJSON  = "ndjson"
+	CatalogueFormatGeoJSON = "geojson"
+
+	DefaultCatalogueCityRadius = 20000 // meters
+
+	catalogueRecordHeader = "header"
+	catalogueRecordCity   = "city"
+	catalogueRecordAlias  = "alias"
+	catalogueRecordPlace  = "place"
+
+	catalogueGeocodesKey     = "geocode:cities"
+	catalogueCityAliasKey    = "location_name_alias_mapping:city_names"
+	catalogueCountryAliasKey = "location_name_alias_mapping:country_names"
+	catalogueImportBatchSize = 500
+)
+
+var ErrInvalidCatalogueBundle = errors.New("invalid catalogue bundle")
+
+// geo index key prefixes of places, the keys are the prefixes followed by place categories
+var catalogueGeoIndexPrefixes = []string{PlaceIDsKeyPrefix + ":", osmPlacesKeyPrefix}
+
+// BoundingBox is an area between two longitudes and two latitudes in degrees
+type BoundingBox struct {
+	MinLng float64 `json:"min_lng"`
+	MinLat float64 `json:"min_lat"`
+	MaxLng float64 `json:"max_lng"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// ParseBoundingBox parses a bounding box in the format of "min_lng,min_lat,max_lng,max_lat"
+func ParseBoundingBox(bbox string) (*BoundingBox, error) {
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bounding box %q must be min_lng,min_lat,max_lng,max_lat", bbox)
+	}
+	values := make([]float64, len(parts))
+	for idx, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bounding box %q must be min_lng,min_lat,max_lng,max_lat", bbox)
+		}
+		values[idx] = value
+	}
+	box := &BoundingBox{MinLng: values[0], MinLat: values[1], MaxLng: values[2], MaxLat: values[3]}
+	if box.MinLng > box.MaxLng || box.MinLat > box.MaxLat {
+		return nil, fmt.Errorf("bounding box %q has minimums greater than maximums", bbox)
+	}
+	return box, nil
+}
+
+// Contains reports whether the location of [lng, lat] is in the box
+func (box *BoundingBox) Contains(location [2]float64) bool {
+	return location[0] >= box.MinLng && location[0] <= box.MaxLng && location[1] >= box.MinLat && location[1] <= box.MaxLat
+}
+
+// CatalogueFilter selects places within the radius of a city or within a bounding box, and the whole catalogue if empty
+type CatalogueFilter struct {
+	City        string       `json:"city,omitempty"`
+	Country     string       `json:"country,omitempty"`
+	Radius      uint64       `json:"radius,omitempty"` // meters, DefaultCatalogueCityRadius if 0
+	BoundingBox *BoundingBox `json:"bbox,omitempty"`
+}
+
+type CatalogueHeader struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Filter     CatalogueFilter `json:"filter"`
+}
+
+// CatalogueCity is a cached geocode
+type CatalogueCity struct {
+	City     string     `json:"city"`
+	Country  string     `json:"country"`
+	Location [2]float64 `json:"location"` // [lng, lat]
+}
+
+// CatalogueAlias maps a city or country name to the name of cached geocodes
+type CatalogueAlias struct {
+	Kind      string `json:"kind"` // city or country
+	Name      string `json:"name"`
+	Canonical string `json:"canonical"`
+}
+
+type CataloguePlace struct {
+	Place         POI.Place `json:"place"`
+	Indexes       []string  `json:"indexes,omitempty"`        // key prefixes of the geo indexes of the place
+	LastRefreshed int64     `json:"last_refreshed,omitempty"` // Unix time
+}
+
+// catalogueRecord is a line of NDJSON bundles, with the fields of the record of the type
+type catalogueRecord struct {
+	Type string `json:"type"`
+	*CatalogueHeader
+	*CatalogueCity
+	*CatalogueAlias
+	*CataloguePlace
+}
+
+// geoJSONCatalogue is a GeoJSON bundle, where cities and aliases are foreign members of the feature collection
+type geoJSONCatalogue struct {
+	Type      string             `json:"type"`
+	Catalogue *CatalogueHeader   `json:"catalogue"`
+	Cities    []CatalogueCity    `json:"cities"`
+	Aliases   []CatalogueAlias   `json:"aliases"`
+	Features  []geoJSONPlaceItem `json:"features"`
+}
+
+// geoJSONPlaceItem is a feature of a place, whose geometry is for GIS tools and places are imported from the properties
+type geoJSONPlaceItem struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Geometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties CataloguePlace `json:"properties"`
+}
+
+// CatalogueStats counts the records of an export or an import
+type CatalogueStats struct {
+	Places  int `json:"places"`
+	Cities  int `json:"cities"`
+	Aliases int `json:"aliases"`
+}
+
+// ExportCatalogue writes the places, geocodes and aliases selected by the filter as a bundle of the format
+// the filter is checked before anything is written
+func (redisClient *RedisClient) ExportCatalogue(context context.Context, writer io.Writer, format string, filter CatalogueFilter) (stats CatalogueStats, err error) {
+	if format != CatalogueFormatNDJSON && format != CatalogueFormatGeoJSON {
+		return stats, fmt.Errorf("unknown catalogue format %q", format)
+	}
+	cities, err := redisClient.catalogueCities(context)
+	if err != nil {
+		return
+	}
+	includePlace, cities, err := redisClient.applyCatalogueFilter(context, &filter, cities)
+	if err != nil {
+		return
+	}
+	aliases, err := redisClient.catalogueAliases(context, cities)
+	if err != nil {
+		return
+	}
+
+	bufferedWriter := bufio.NewWriter(writer)
+	bundle := &catalogueBundleWriter{writer: bufferedWriter, format: format}
+	bundle.writeHeader(CatalogueHeader{Format: CatalogueBundleFormat, Version: CatalogueBundleVersion, ExportedAt: time.Now().UTC(), Filter: filter}, cities, aliases)
+	stats.Cities, stats.Aliases = len(cities), len(aliases)
+
+	placeKeyPrefix := PlaceDetailsKeyPrefix + ":place_ID:"
+	var cursor uint64
+	for {
+		var placeKeys []string
+		if placeKeys, cursor, err = redisClient.client.Scan(context, cursor, placeKeyPrefix+"*", 100).Result(); err != nil {
+			return
+		}
+		var places []CataloguePlace
+		if places, err = redisClient.cataloguePlaces(context, placeKeys, includePlace); err != nil {
+			return
+		}
+		for _, place := range places {
+			bundle.writePlace(place)
+		}
+		stats.Places += len(places)
+		if cursor == 0 {
+			break
+		}
+	}
+	bundle.writeFooter()
+	if bundle.err != nil {
+		return stats, bundle.err
+	}
+	return stats, bufferedWriter.Flush()
+}
+
+// applyCatalogueFilter returns whether places are selected by the filter and the cities of the selected places
+func (redisClient *RedisClient) applyCatalogueFilter(context context.Context, filter *CatalogueFilter, cities []CatalogueCity) (func(location [2]float64) bool, []CatalogueCity, error) {
+	switch {
+	case filter.City != "":
+		query := &GeocodeQuery{City: filter.City, Country: filter.Country}
+		lat, lng, err := redisClient.GetGeocode(context, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		if filter.Radius == 0 {
+			filter.Radius = DefaultCatalogueCityRadius
+		}
+		radius := float64(filter.Radius)
+		selectedCities := make([]CatalogueCity, 0, 1)
+		for _, city := range cities {
+			if city.City == query.City && city.Country == query.Country {
+				selectedCities = append(selectedCities, city)
+			}
+		}
+		return func(location [2]float64) bool {
+			return utils.HaversineDist([]float64{lat, lng}, []float64{location[1], location[0]}) <= radius
+		}, selectedCities, nil
+	case filter.BoundingBox != nil:
+		selectedCities := make([]CatalogueCity, 0)
+		for _, city := range cities {
+			if filter.BoundingBox.Contains(city.Location) {
+				selectedCities = append(selectedCities, city)
+			}
+		}
+		return filter.BoundingBox.Contains, selectedCities, nil
+	}
+	return func([2]float64) bool { return true }, cities, nil
+}
+
+// catalogueCities returns cached geocodes, fields of geocodes are city_country in lower case
+func (redisClient *RedisClient) catalogueCities(context context.Context) ([]CatalogueCity, error) {
+	geocodes, err := redisClient.client.HGetAll(context, catalogueGeocodesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	cities := make([]CatalogueCity, 0, len(geocodes))
+	for field, geocode := range geocodes {
+		separatorIdx := strings.LastIndex(field, "_")
+		latLng, parseErr := utils.ParseLocation(geocode)
+		if separatorIdx < 0 || parseErr != nil {
+			Logger.Errorf("[catalogue] skipped invalid geocode %s of %s", geocode, field)
+			continue
+		}
+		cities = append(cities, CatalogueCity{City: field[:separatorIdx], Country: field[separatorIdx+1:], Location: [2]float64{latLng[1], latLng[0]}})
+	}
+	return cities, nil
+}
+
+// catalogueAliases returns city and country aliases of names of the cities
+func (redisClient *RedisClient) catalogueAliases(context context.Context, cities []CatalogueCity) ([]CatalogueAlias, error) {
+	canonicalNames := map[string]map[string]bool{catalogueRecordCity: {}, "country": {}}
+	for _, city := range cities {
+		canonicalNames[catalogueRecordCity][city.City] = true
+		canonicalNames["country"][city.Country] = true
+	}
+	aliases := make([]CatalogueAlias, 0)
+	for kind, key := range map[string]string{catalogueRecordCity: catalogueCityAliasKey, "country": catalogueCountryAliasKey} {
+		mapping, err := redisClient.client.HGetAll(context, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		for name, canonical := range mapping {
+			if canonicalNames[kind][canonical] {
+				aliases = append(aliases, CatalogueAlias{Kind: kind, Name: name, Canonical: canonical})
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// cataloguePlaces returns the places of the place details keys selected by the filter with their geo indexes and refresh times
+func (redisClient *RedisClient) cataloguePlaces(context context.Context, placeKeys []string, includePlace func(location [2]float64) bool) ([]CataloguePlace, error) {
+	if len(placeKeys) == 0 {
+		return nil, nil
+	}
+	placeJsons, err := redisClient.client.MGet(context, placeKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	places := make([]CataloguePlace, 0, len(placeKeys))
+	for idx, placeJson := range placeJsons {
+		json_, isString := placeJson.(string)
+		if !isString {
+			continue
+		}
+		var place POI.Place
+		if err = json.Unmarshal([]byte(json_), &place); err != nil {
+			Logger.Errorf("[catalogue] skipped invalid place %s: %v", placeKeys[idx], err)
+			continue
+		}
+		if includePlace(place.Location.Coordinates) {
+			places = append(places, CataloguePlace{Place: place})
+		}
+	}
+
+	pipeline := redisClient.client.Pipeline()
+	indexCmds := make([][]*redis.FloatCmd, len(places))
+	refreshCmds := make([]*redis.FloatCmd, len(places))
+	for idx, place := range places {
+		category := strings.ToLower(string(POI.GetPlaceCategory(place.Place.LocationType)))
+		for _, prefix := range catalogueGeoIndexPrefixes {
+			indexCmds[idx] = append(indexCmds[idx], pipeline.ZScore(context, prefix+category, place.Place.ID))
+		}
+		refreshCmds[idx] = pipeline.ZScore(context, PlaceLastRefreshedKey, place.Place.ID)
+	}
+	if _, err = pipeline.Exec(context); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for idx := range places {
+		for prefixIdx, indexCmd := range indexCmds[idx] {
+			if indexCmd.Err() == nil {
+				places[idx].Indexes = append(places[idx].Indexes, catalogueGeoIndexPrefixes[prefixIdx])
+			}
+		}
+		places[idx].LastRefreshed = int64(refreshCmds[idx].Val())
+	}
+	return places, nil
+}
+
+// catalogueBundleWriter writes records of a bundle, and keeps the first error
+type catalogueBundleWriter struct {
+	writer    io.Writer
+	format    string
+	numPlaces int
+	err       error
+}
+
+func (bundle *catalogueBundleWriter) write(value interface{}, suffix string) {
+	if bundle.err != nil {
+		return
+	}
+	json_, err := json.Marshal(value)
+	if err == nil {
+		_, err = bundle.writer.Write(append(json_, suffix...))
+	}
+	bundle.err = err
+}
+
+func (bundle *catalogueBundleWriter) writeHeader(header CatalogueHeader, cities []CatalogueCity, aliases []CatalogueAlias) {
+	if bundle.format == CatalogueFormatGeoJSON {
+		_, bundle.err = io.WriteString(bundle.writer, `{"type":"FeatureCollection","catalogue":`)
+		bundle.write(header, `,"cities":`)
+		bundle.write(cities, `,"aliases":`)
+		bundle.write(aliases, `,"features":[`)
+		return
+	}
+	bundle.write(catalogueRecord{Type: catalogueRecordHeader, CatalogueHeader: &header}, "\n")
+	for idx := range cities {
+		bundle.write(catalogueRecord{Type: catalogueRecordCity, CatalogueCity: &cities[idx]}, "\n")
+	}
+	for idx := range aliases {
+		bundle.write(catalogueRecord{Type: catalogueRecordAlias, CatalogueAlias: &aliases[idx]}, "\n")
+	}
+}
+
+func (bundle *catalogueBundleWriter) writePlace(place CataloguePlace) {
+	if bundle.format == CatalogueFormatGeoJSON {
+		if bundle.numPlaces > 0 && bundle.err == nil {
+			_, bundle.err = io.WriteString(bundle.writer, ",\n")
+		}
+		feature := geoJSONPlaceItem{Type: "Feature", ID: place.Place.ID, Properties: place}
+		feature.Geometry.Type, feature.Geometry.Coordinates = "Point", place.Place.Location.Coordinates
+		bundle.write(feature, "")
+	} else {
+		bundle.write(catalogueRecord{Type: catalogueRecordPlace, CataloguePlace: &place}, "\n")
+	}
+	bundle.numPlaces++
+}
+
+func (bundle *catalogueBundleWriter) writeFooter() {
+	if bundle.format == CatalogueFormatGeoJSON && bundle.err == nil {
+		_, bundle.err = io.WriteString(bundle.writer, "]}\n")
+	}
+}
+
+// ImportCatalogue stores the places, geocodes and aliases of an NDJSON or GeoJSON bundle, and rebuilds the geo indexes of the places
+// existing places with the same IDs are replaced, and cached plans with changed places are invalidated
+func (redisClient *RedisClient) ImportCatalogue(context context.Context, reader io.Reader) (stats CatalogueStats, err error) {
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	var first json.RawMessage
+	if err = decoder.Decode(&first); err != nil {
+		return stats, fmt.Errorf("%w: %v", ErrInvalidCatalogueBundle, err)
+	}
+	var firstRecord catalogueRecord
+	if err = json.Unmarshal(first, &firstRecord); err != nil {
+		return stats, fmt.Errorf("%w: %v", ErrInvalidCatalogueBundle, err)
+	}
+
+	importer := &catalogueImporter{redisClient: redisClient, stats: &stats}
+	switch firstRecord.Type {
+	case catalogueRecordHeader:
+		if err = checkCatalogueHeader(firstRecord.CatalogueHeader); err != nil {
+			return
+		}
+		for {
+			var record catalogueRecord
+			if err = decoder.Decode(&record); err == io.EOF {
+				break
+			} else if err != nil {
+				return stats, fmt.Errorf("%w: %v", ErrInvalidCatalogueBundle, err)
+			}
+			if err = importer.importRecord(context, record); err != nil {
+				return
+			}
+		}
+	case "FeatureCollection":
+		var collection geoJSONCatalogue
+		if err = json.Unmarshal(first, &collection); err != nil {
+			return stats, fmt.Errorf("%w: %v", ErrInvalidCatalogueBundle, err)
+		}
+		if err = checkCatalogueHeader(collection.Catalogue); err != nil {
+			return
+		}
+		for idx := range collection.Cities {
+			if err = importer.importRecord(context, catalogueRecord{Type: catalogueRecordCity, CatalogueCity: &collection.Cities[idx]}); err != nil {
+				return
+			}
+		}
+		for idx := range collection.Aliases {
+			if err = importer.importRecord(context, catalogueRecord{Type: catalogueRecordAlias, CatalogueAlias: &collection.Aliases[idx]}); err != nil {
+				return
+			}
+		}
+		for idx := range collection.Features {
+			if err = importer.importRecord(context, catalogueRecord{Type: catalogueRecordPlace, CataloguePlace: &collection.Features[idx].Properties}); err != nil {
+				return
+			}
+		}
+	default:
+		return stats, fmt.Errorf("%w: a bundle starts with a header or is a GeoJSON feature collection", ErrInvalidCatalogueBundle)
+	}
+	if err = importer.flushPlaces(context); err != nil {
+		return
+	}
+	Logger.Infof("[catalogue] imported %d places, %d cities and %d aliases", stats.Places, stats.Cities, stats.Aliases)
+	return
+}
+
+func checkCatalogueHeader(header *CatalogueHeader) error {
+	if header == nil || header.Format != CatalogueBundleFormat {
+		return fmt.Errorf("%w: the bundle is not of the format %s", ErrInvalidCatalogueBundle, CatalogueBundleFormat)
+	}
+	if header.Version < 1 || header.Version > CatalogueBundleVersion {
+		return fmt.Errorf("%w: version %d is not supported, the latest version is %d", ErrInvalidCatalogueBundle, header.Version, CatalogueBundleVersion)
+	}
+	return nil
+}
+
+// catalogueImporter stores places in batches
+type catalogueImporter struct {
+	redisClient *RedisClient
+	stats       *CatalogueStats
+	places      []CataloguePlace
+}
+
+func (importer *catalogueImporter) importRecord(context context.Context, record catalogueRecord) error {
+	redisClient := importer.redisClient
+	switch {
+	case record.Type == catalogueRecordCity && record.CatalogueCity != nil:
+		city := record.CatalogueCity
+		if city.City == "" || city.Country == "" {
+			return fmt.Errorf("%w: cities require city and country names", ErrInvalidCatalogueBundle)
+		}
+		field := strings.ToLower(strings.Join([]string{city.City, city.Country}, "_"))
+		geocode := fmt.Sprintf("%.6f,%.6f", city.Location[1], city.Location[0])
+		if err := redisClient.client.HSet(context, catalogueGeocodesKey, field, geocode).Err(); err != nil {
+			return err
+		}
+		importer.stats.Cities++
+	case record.Type == catalogueRecordAlias && record.CatalogueAlias != nil:
+		alias := record.CatalogueAlias
+		key := catalogueCityAliasKey
+		if alias.Kind == "country" {
+			key = catalogueCountryAliasKey
+		} else if alias.Kind != catalogueRecordCity {
+			return fmt.Errorf("%w: unknown alias kind %q", ErrInvalidCatalogueBundle, alias.Kind)
+		}
+		if err := redisClient.client.HSet(context, key, strings.ToLower(alias.Name), strings.ToLower(alias.Canonical)).Err(); err != nil {
+			return err
+		}
+		importer.stats.Aliases++
+	case record.Type == catalogueRecordPlace && record.CataloguePlace != nil:
+		if record.Place.ID == "" {
+			return fmt.Errorf("%w: places require IDs", ErrInvalidCatalogueBundle)
+		}
+		for _, prefix := range record.Indexes {
+			if prefix != catalogueGeoIndexPrefixes[0] && prefix != catalogueGeoIndexPrefixes[1] {
+				return fmt.Errorf("%w: unknown geo index %q of place %s", ErrInvalidCatalogueBundle, prefix, record.Place.ID)
+			}
+		}
+		importer.places = append(importer.places, *record.CataloguePlace)
+		if len(importer.places) == catalogueImportBatchSize {
+			return importer.flushPlaces(context)
+		}
+	default:
+		return fmt.Errorf("%w: unknown record type %q", ErrInvalidCatalogueBundle, record.Type)
+	}
+	return nil
+}
+
+// flushPlaces stores the places of the batch in their geo indexes and keeps their refresh times
+func (importer *catalogueImporter) flushPlaces(context context.Context) error {
+	placesOfIndexes := make(map[string][]POI.Place)
+	wg := &sync.WaitGroup{}
+	for _, place := range importer.places {
+		if len(place.Indexes) == 0 {
+			wg.Add(1)
+			importer.redisClient.setPlace(context, place.Place, wg)
+		}
+		for _, prefix := range place.Indexes {
+			placesOfIndexes[prefix] = append(placesOfIndexes[prefix], place.Place)
+		}
+	}
+	for prefix, places := range placesOfIndexes {
+		importer.redisClient.setPlacesOnCategory(context, prefix, places)
+	}
+
+	// places without refresh times are added as the stalest places by the refresh worker
+	pipeline := importer.redisClient.client.Pipeline()
+	for _, place := range importer.places {
+		if place.LastRefreshed > 0 {
+			pipeline.ZAdd(context, PlaceLastRefreshedKey, &redis.Z{Score: float64(place.LastRefreshed), Member: place.Place.ID})
+		} else {
+			pipeline.ZRem(context, PlaceLastRefreshedKey, place.Place.ID)
+		}
+	}
+	if len(importer.places) > 0 {
+		if _, err := pipeline.Exec(context); err != nil {
+			return err
+		}
+	}
+	importer.stats.Places += len(importer.places)
+	importer.places = importer.places[:0]
+	return nil
+}
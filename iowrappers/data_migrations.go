@@ -2,120 +2,221 @@ package iowrappers
 
 import (
 	"context"
-	"github.com/weihesdlegend/Vacation-planner/utils"
-	"reflect"
+	"fmt"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"googlemaps.github.io/maps"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 const (
-	BatchSize = 300
+	PlaceFieldMigrationBatchSize = 300
+	PlaceFieldMigrationWorkers   = 10 // maximum number of concurrent place details searches of a batch
+
+	migratedPlacesKeyPrefix = "migration:" // sets of places updated by migrations of place fields
 )
 
-// a generic migration method
-// returns place details results for the calling function to extract and use specific fields
-func (poiSearcher *PoiSearcher) addDataFieldsToPlaces(context context.Context, field string, batchSize int) (map[string]PlaceDetailSearchResult, error) {
-	mapsClient := poiSearcher.GetMapsClient()
-	redisClient := poiSearcher.GetRedisClient()
-	placeDetailsKeys, totalPlacesCount, err := redisClient.GetPlaceCountInRedis(context)
-	if err != nil {
-		return nil, err
+// placeFieldMigration adds a field of place details searches to cached places
+// updated places are kept in a Redis set, since the zero value of the new field may be valid
+type placeFieldMigration struct {
+	name        string
+	field       string
+	version     int
+	mapsClient  *MapsClient
+	redisClient *RedisClient
+	// value returns the value of the field of the place
+	value func(place POI.Place) interface{}
+	// update sets the field of the place to the value of the details search
+	update func(place *POI.Place, details maps.PlaceDetailsResult)
+}
+
+func CreateUserRatingsTotalMigration(mapsClient *MapsClient, redisClient *RedisClient) Migration {
+	return &placeFieldMigration{
+		name:        "user-ratings-total",
+		field:       "user_ratings_total",
+		version:     1,
+		mapsClient:  mapsClient,
+		redisClient: redisClient,
+		value:       func(place POI.Place) interface{} { return place.UserRatingsTotal },
+		update: func(place *POI.Place, details maps.PlaceDetailsResult) {
+			place.SetUserRatingsTotal(details.UserRatingsTotal)
+		},
 	}
+}
 
-	// persist updated places in a Redis Set
-	// we cannot rely on checking the value of the new field
-	// to determine if the place is updated. The default value is 0,
-	// and some places may not have any rating.
-	updatedPlacesRedisKey := "migration:" + field
+func CreateUrlMigration(mapsClient *MapsClient, redisClient *RedisClient) Migration {
+	return &placeFieldMigration{
+		name:        "url",
+		field:       "url",
+		version:     1,
+		mapsClient:  mapsClient,
+		redisClient: redisClient,
+		value:       func(place POI.Place) interface{} { return place.URL },
+		update: func(place *POI.Place, details maps.PlaceDetailsResult) {
+			place.SetURL(details.URL)
+		},
+	}
+}
 
-	// store place IDs
-	placesNeedUpdate := make([]string, 0)
-	for _, placeDetailsKey := range placeDetailsKeys {
-		placeId := strings.Split(placeDetailsKey, ":")[2]
+func (migration *placeFieldMigration) Name() string {
+	return migration.name
+}
+
+func (migration *placeFieldMigration) Version() int {
+	return migration.version
+}
+
+// Batch updates a page of cached places, the checkpoint is the cursor of the scan of place keys
+// dry runs report places not updated yet without searching place details, and write nothing
+func (migration *placeFieldMigration) Batch(context context.Context, checkpoint string, dryRun bool) (batch MigrationBatch, err error) {
+	var cursor uint64
+	if checkpoint != "" {
+		if cursor, err = strconv.ParseUint(checkpoint, 10, 64); err != nil {
+			return
+		}
+	}
+	redisClient := migration.redisClient
+	placeKeyPrefix := PlaceDetailsKeyPrefix + ":place_ID:"
+	placeKeys, cursor, err := redisClient.client.Scan(context, cursor, placeKeyPrefix+"*", PlaceFieldMigrationBatchSize).Result()
+	if err != nil {
+		return
+	}
+	batch.Checkpoint, batch.Done, batch.Processed = strconv.FormatUint(cursor, 10), cursor == 0, len(placeKeys)
+
+	updatedPlacesRedisKey := migratedPlacesKeyPrefix + migration.field
+	placesNeedUpdate := make([]string, 0, len(placeKeys))
+	for _, placeKey := range placeKeys {
+		placeId := strings.TrimPrefix(placeKey, placeKeyPrefix)
+		// places of OpenStreetMap have no Google Maps details
+		if strings.HasPrefix(placeId, osmPlaceIdPrefix) {
+			continue
+		}
 		updated, _ := redisClient.client.SIsMember(context, updatedPlacesRedisKey, placeId).Result()
 		if !updated {
 			placesNeedUpdate = append(placesNeedUpdate, placeId)
 		}
 	}
-	Logger.Infof("[data migration] The number of places need update is %d with target field: %s", len(placesNeedUpdate), field)
 
-	placesToUpdateCount := utils.MinInt(len(placesNeedUpdate), batchSize)
-	newPlaceDetailsResults := make([]PlaceDetailSearchResult, placesToUpdateCount)
-	Logger.Infof("[data migration] Place to update count: %d, batch size is: %d", placesToUpdateCount, batchSize)
-	Logger.Infof("[data migration] Getting %d place details with target field: %s", placesToUpdateCount, field)
+	if dryRun {
+		for _, placeId := range placesNeedUpdate {
+			batch.Changes = append(batch.Changes, fmt.Sprintf("place %s: %s needs update", placeId, migration.field))
+		}
+		Logger.Infof("[data migration] %d of %d places need update with target field %s", len(placesNeedUpdate), len(placeKeys), migration.field)
+		return
+	}
 
-	fields := []string{field}
+	detailsResults := make([]PlaceDetailSearchResult, len(placesNeedUpdate))
+	wg := &sync.WaitGroup{}
+	wg.Add(len(placesNeedUpdate))
+	workers := make(chan struct{}, PlaceFieldMigrationWorkers)
+	for idx, placeId := range placesNeedUpdate {
+		workers <- struct{}{}
+		go func(idx int, placeId string) {
+			defer func() { <-workers }()
+			PlaceDetailsSearchWrapper(context, migration.mapsClient, idx, placeId, []string{migration.field}, &detailsResults[idx], wg)
+		}(idx, placeId)
+	}
+	wg.Wait()
 
-	wg := sync.WaitGroup{}
-	wg.Add(placesToUpdateCount)
-	for idx, placeId := range placesNeedUpdate[:placesToUpdateCount] {
+	// places of failed details searches are updated by later runs
+	updateWg := &sync.WaitGroup{}
+	for idx, placeId := range placesNeedUpdate {
+		if detailsResults[idx].Err != nil {
+			batch.Errors = append(batch.Errors, fmt.Sprintf("place %s: %v", placeId, detailsResults[idx].Err))
+			continue
+		}
+		place, placeErr := redisClient.getPlace(context, placeId)
+		if placeErr != nil {
+			batch.Errors = append(batch.Errors, fmt.Sprintf("place %s: %v", placeId, placeErr))
+			continue
+		}
+		oldValue := migration.value(place)
+		migration.update(&place, *detailsResults[idx].Res)
+		if newValue := migration.value(place); oldValue != newValue {
+			batch.Changes = append(batch.Changes, fmt.Sprintf("place %s: %s %v -> %v", placeId, migration.field, oldValue, newValue))
+			updateWg.Add(1)
+			go redisClient.setPlace(context, place, updateWg)
+		}
 		redisClient.client.SAdd(context, updatedPlacesRedisKey, placeId)
-
-		go PlaceDetailsSearchWrapper(context, mapsClient, idx, placeId, fields, &newPlaceDetailsResults[idx], &wg)
 	}
+	updateWg.Wait()
+	Logger.Infof("[data migration] %d of %d places need update with target field %s, %d places changed, %d places failed",
+		len(placesNeedUpdate), len(placeKeys), migration.field, len(batch.Changes), len(batch.Errors))
+	return
+}
 
-	wg.Wait()
-	results := make(map[string]PlaceDetailSearchResult)
+// slotSolutionCacheMigration removes slot solution and multi-day solution keys of cache versions other than SlotSolutionCacheVersion
+// keys of the unversioned scheme may be shared by different requests, so the cached solutions are dropped instead of re-keyed
+type slotSolutionCacheMigration struct {
+	redisClient *RedisClient
+}
 
-	for idx, placeId := range placesNeedUpdate[:placesToUpdateCount] {
-		results[placeId] = newPlaceDetailsResults[idx]
-	}
-	Logger.Infof("[data migration] %d places left to update out of total of %d",
-		len(placesNeedUpdate)-placesToUpdateCount,
-		totalPlacesCount)
+var slotSolutionCacheKeyPrefixes = []string{SlotSolutionKeyPrefix, MultiDaySolutionKeyPrefix}
 
-	return results, nil
+func CreateSlotSolutionCacheMigration(redisClient *RedisClient) Migration {
+	return &slotSolutionCacheMigration{redisClient: redisClient}
 }
 
-// add user_ratings_total field to Places
-func (poiSearcher *PoiSearcher) AddUserRatingsTotal(context context.Context) error {
-	placeIdToDetailedSearchResults, err := poiSearcher.addDataFieldsToPlaces(context, "user_ratings_total", BatchSize)
+func (migration *slotSolutionCacheMigration) Name() string {
+	return "slot-solution-cache"
+}
+
+func (migration *slotSolutionCacheMigration) Version() int {
+	return 1
+}
+
+// Batch removes outdated keys of a page of the scan of a key prefix
+// the checkpoint is the index of the key prefix and the cursor of the scan
+func (migration *slotSolutionCacheMigration) Batch(context context.Context, checkpoint string, dryRun bool) (batch MigrationBatch, err error) {
+	var prefixIdx int
+	var cursor uint64
+	if checkpoint != "" {
+		if _, err = fmt.Sscanf(checkpoint, "%d:%d", &prefixIdx, &cursor); err != nil {
+			return
+		}
+	}
+	if prefixIdx >= len(slotSolutionCacheKeyPrefixes) {
+		batch.Checkpoint, batch.Done = checkpoint, true
+		return
+	}
+	keyPrefix := slotSolutionCacheKeyPrefixes[prefixIdx]
+	currentPrefix := keyPrefix + ":" + SlotSolutionCacheVersion + ":"
+	keys, cursor, err := migration.redisClient.client.Scan(context, cursor, keyPrefix+":*", 100).Result()
 	if err != nil {
-		return err
+		return
 	}
-
-	redisClient := poiSearcher.GetRedisClient()
-	wg := sync.WaitGroup{}
-	wg.Add(len(placeIdToDetailedSearchResults))
-	for placeId, detailedResult := range placeIdToDetailedSearchResults {
-		place, err := redisClient.getPlace(context, placeId)
-		if err != nil {
-			continue
+	batch.Processed = len(keys)
+	outdatedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, currentPrefix) {
+			outdatedKeys = append(outdatedKeys, key)
+			batch.Changes = append(batch.Changes, fmt.Sprintf("remove %q", key))
 		}
-		// FIXME: figure out the reason for maps client return null pointer as result
-		if reflect.ValueOf(detailedResult.Res).IsNil() {
-			place.SetUserRatingsTotal(0)
-		} else {
-			place.SetUserRatingsTotal(detailedResult.Res.UserRatingsTotal)
+	}
+	if len(outdatedKeys) > 0 && !dryRun {
+		if err = migration.redisClient.client.Del(context, outdatedKeys...).Err(); err != nil {
+			return
 		}
-		go redisClient.setPlace(context, place, &wg)
 	}
-	wg.Wait()
-	return nil
-}
-
-func (poiSearcher *PoiSearcher) AddUrl(context context.Context) error {
-	placeIdToDetailedSearchResults, err := poiSearcher.addDataFieldsToPlaces(context, "url", BatchSize)
-	if err != nil {
-		return err
+	if cursor == 0 {
+		prefixIdx++
 	}
+	batch.Checkpoint = fmt.Sprintf("%d:%d", prefixIdx, cursor)
+	batch.Done = prefixIdx == len(slotSolutionCacheKeyPrefixes)
+	return
+}
 
-	redisClient := poiSearcher.GetRedisClient()
-	wg := sync.WaitGroup{}
-	wg.Add(len(placeIdToDetailedSearchResults))
-	for placeId, detailedResult := range placeIdToDetailedSearchResults {
-		place, err := redisClient.getPlace(context, placeId)
-		if err != nil {
-			continue
-		}
-		// FIXME: figure out the reason for maps client return null pointer as result
-		if reflect.ValueOf(detailedResult.Res).IsNil() {
-			place.SetURL("")
-		} else {
-			place.SetURL(detailedResult.Res.URL)
+// RemoveOutdatedSlotSolutions runs the slot solution cache migration to the end
+func (redisClient *RedisClient) RemoveOutdatedSlotSolutions(context context.Context) (removedCount int, err error) {
+	migration := CreateSlotSolutionCacheMigration(redisClient)
+	var batch MigrationBatch
+	for !batch.Done {
+		if batch, err = migration.Batch(context, batch.Checkpoint, false); err != nil {
+			return
 		}
-		go redisClient.setPlace(context, place, &wg)
+		removedCount += len(batch.Changes)
 	}
-	wg.Wait()
-	return nil
+	Logger.Infof("[data migration] removed %d slot solution keys of outdated cache versions", removedCount)
+	return
 }
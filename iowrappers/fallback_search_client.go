@@ -0,0 +1,82 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+// FallbackSearchClient chains search clients of different providers
+// each search is sent to the next search client only if the previous ones fail
+type FallbackSearchClient struct {
+	searchClients []SearchClient
+}
+
+func CreateFallbackSearchClient(searchClients ...SearchClient) *FallbackSearchClient {
+	logErr(CreateLogger(), utils.LogError)
+	return &FallbackSearchClient{searchClients: searchClients}
+}
+
+func (client *FallbackSearchClient) GetGeocode(context context.Context, query *GeocodeQuery) (lat float64, lng float64, err error) {
+	err = errors.New("no search client")
+	for _, searchClient := range client.searchClients {
+		if lat, lng, err = searchClient.GetGeocode(context, query); err == nil {
+			return
+		}
+		Logger.Debugf("geocoding of %s, %s falls back to the next search client: %v", query.City, query.Country, err)
+	}
+	return
+}
+
+// NearbySearch returns places of the first search client finding at least the minimum number of results of the request,
+// or the most places found by any search client, and places of different search clients are never mixed
+// partial results of a search client are used with the PartialNearbySearchError of the search client
+func (client *FallbackSearchClient) NearbySearch(context context.Context, request *PlaceSearchRequest) ([]POI.Place, error) {
+	var places []POI.Place
+	var placesErr error
+	err := errors.New("no search client")
+	var succeeded bool
+	for _, searchClient := range client.searchClients {
+		// search clients may change the request
+		clientRequest := *request
+		clientPlaces, searchErr := searchClient.NearbySearch(context, &clientRequest)
+		var partialErr *PartialNearbySearchError
+		if searchErr != nil && !errors.As(searchErr, &partialErr) {
+			err = searchErr
+			Logger.Debugf("nearby search falls back to the next search client: %v", searchErr)
+			continue
+		}
+		if !succeeded || len(clientPlaces) > len(places) {
+			places, placesErr, succeeded = clientPlaces, searchErr, true
+		}
+		if uint(len(places)) >= request.MinNumResults {
+			break
+		}
+	}
+	if !succeeded {
+		return make([]POI.Place, 0), err
+	}
+	return places, placesErr
+}
+
+func (client *FallbackSearchClient) PlaceDetailsSearch(context context.Context, placeId string) (place POI.Place, err error) {
+	err = errors.New("no search client")
+	for _, searchClient := range client.searchClients {
+		if place, err = searchClient.PlaceDetailsSearch(context, placeId); err == nil {
+			return
+		}
+	}
+	return
+}
+
+// GetMapsClient returns the first Google Maps search client in the chain, or nil if there is none
+func (client *FallbackSearchClient) GetMapsClient() *MapsClient {
+	for _, searchClient := range client.searchClients {
+		if mapsClient, isMapsClient := searchClient.(*MapsClient); isMapsClient {
+			return mapsClient
+		}
+	}
+	return nil
+}
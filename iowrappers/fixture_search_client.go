@@ -0,0 +1,106 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+	"googlemaps.github.io/maps"
+)
+
+const (
+	SearchClientProviderGoogleMaps = "google_maps"
+	SearchClientProviderFixtures   = "fixtures"
+	SearchClientProviderOSM        = "osm"
+)
+
+// SearchFixtures are places of cities served by the FixtureSearchClient
+type SearchFixtures struct {
+	Cities []CityFixture `json:"cities"`
+}
+
+// CityFixture is the geocode of a city and its places
+// places are in the same JSON format as places cached in Redis
+type CityFixture struct {
+	City    string      `json:"city"`
+	Country string      `json:"country"`
+	Lat     float64     `json:"lat"`
+	Lng     float64     `json:"lng"`
+	Places  []POI.Place `json:"places"`
+}
+
+// FixtureSearchClient serves geocodes, nearby search and place details from fixtures without network access
+type FixtureSearchClient struct {
+	cities []CityFixture
+	places map[string]POI.Place // place ID to place
+}
+
+// CreateFixtureSearchClient loads fixtures from a JSON file
+func CreateFixtureSearchClient(fixturesFile string) (*FixtureSearchClient, error) {
+	logErr(CreateLogger(), utils.LogError)
+	data, err := ioutil.ReadFile(fixturesFile)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := SearchFixtures{}
+	if err = json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures file %s: %w", fixturesFile, err)
+	}
+	return CreateFixtureSearchClientFromFixtures(fixtures), nil
+}
+
+func CreateFixtureSearchClientFromFixtures(fixtures SearchFixtures) *FixtureSearchClient {
+	client := &FixtureSearchClient{cities: fixtures.Cities, places: make(map[string]POI.Place)}
+	for _, city := range fixtures.Cities {
+		for _, place := range city.Places {
+			client.places[place.ID] = place
+		}
+	}
+	return client
+}
+
+// GetGeocode finds the city of the query ignoring cases and corrects the city name in the query like Google Maps
+func (client *FixtureSearchClient) GetGeocode(context context.Context, query *GeocodeQuery) (lat float64, lng float64, err error) {
+	for _, city := range client.cities {
+		if strings.EqualFold(city.City, query.City) && strings.EqualFold(city.Country, query.Country) {
+			query.City = city.City
+			return city.Lat, city.Lng, nil
+		}
+	}
+	err = fmt.Errorf("no fixture for location %s, %s", query.City, query.Country)
+	return
+}
+
+// NearbySearch returns places of the category within the search radius of the location in the format of "lat,lng"
+func (client *FixtureSearchClient) NearbySearch(context context.Context, request *PlaceSearchRequest) ([]POI.Place, error) {
+	latLng, err := maps.ParseLatLng(request.Location)
+	if err != nil {
+		return nil, err
+	}
+	places := make([]POI.Place, 0)
+	for _, city := range client.cities {
+		for _, place := range city.Places {
+			if POI.GetPlaceCategory(place.LocationType) != request.PlaceCat {
+				continue
+			}
+			location := place.GetLocation()
+			if utils.HaversineDist([]float64{location[1], location[0]}, []float64{latLng.Lat, latLng.Lng}) <= float64(request.Radius) {
+				places = append(places, place)
+			}
+		}
+	}
+	return places, nil
+}
+
+func (client *FixtureSearchClient) PlaceDetailsSearch(context context.Context, placeId string) (place POI.Place, err error) {
+	place, exists := client.places[placeId]
+	if !exists {
+		err = errors.New("no fixture for place " + placeId)
+	}
+	return
+}
@@ -15,6 +15,9 @@ func (mapsClient MapsClient) GetGeocode(ctx context.Context, query *GeocodeQuery
 			maps.ComponentCountry:  query.Country,
 		}}
 
+	if err = mapsClient.meter.Acquire(ctx, MapsSKUGeocoding); err != nil {
+		return
+	}
 	resp, err := mapsClient.client.Geocode(ctx, req)
 	if err != nil {
 		utils.LogErrorWithLevel(err, utils.LogError)
@@ -0,0 +1,179 @@
+package iowrappers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"googlemaps.github.io/maps"
+)
+
+const (
+	MapsRecordingModeRecord = "record"
+	MapsRecordingModeReplay = "replay"
+	// API key of clients of replay servers, which never see real API keys
+	replayApiKey = "replay"
+)
+
+// credentials are scrubbed from recorded queries and ignored when matching requests with cassettes
+var scrubbedQueryParams = []string{"key", "signature", "client"}
+
+// MapsCassette is one recorded HTTP exchange with Google Maps
+type MapsCassette struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Query       string `json:"query"` // sorted query parameters without credentials
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+// MapsRecorder is an http.RoundTripper saving every exchange with Google Maps to a cassette file of the cassette directory
+type MapsRecorder struct {
+	CassetteDir string
+	Transport   http.RoundTripper // http.DefaultTransport if nil
+	mutex       sync.Mutex
+}
+
+func (recorder *MapsRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := recorder.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// transport errors such as timeouts are not recorded
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	cassette := MapsCassette{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       scrubQuery(req.URL),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if err = writeMapsCassette(recorder.CassetteDir, cassette); err != nil {
+		Logger.Errorf("failed to record Google Maps exchange of %s: %v", req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+func scrubQuery(u *url.URL) string {
+	query := u.Query()
+	for _, param := range scrubbedQueryParams {
+		query.Del(param)
+	}
+	// geocoding components are joined in the random order of a map
+	if components := query.Get("components"); components != "" {
+		parts := strings.Split(components, "|")
+		sort.Strings(parts)
+		query.Set("components", strings.Join(parts, "|"))
+	}
+	return query.Encode() // sorted by key
+}
+
+// cassette files are named by the API and the hash of the request, e.g. nearbysearch-0123456789abcdef.json
+func mapsCassetteFileName(method string, requestPath string, query string) string {
+	digest := sha256.Sum256([]byte(method + " " + requestPath + "?" + query))
+	api := path.Base(path.Dir(requestPath))
+	return fmt.Sprintf("%s-%s.json", api, hex.EncodeToString(digest[:8]))
+}
+
+func writeMapsCassette(cassetteDir string, cassette MapsCassette) error {
+	if err := os.MkdirAll(cassetteDir, 0755); err != nil {
+		return err
+	}
+	// keep queries and bodies readable in cassettes
+	data := &bytes.Buffer{}
+	encoder := json.NewEncoder(data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cassette); err != nil {
+		return err
+	}
+	fileName := mapsCassetteFileName(cassette.Method, cassette.Path, cassette.Query)
+	return ioutil.WriteFile(filepath.Join(cassetteDir, fileName), data.Bytes(), 0644)
+}
+
+// MapsReplayHandler serves the cassettes of the cassette directory
+// requests without cassettes get a NOT_FOUND response, which maps.Client returns as an error
+func MapsReplayHandler(cassetteDir string) (http.Handler, error) {
+	files, err := filepath.Glob(filepath.Join(cassetteDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no cassettes in " + cassetteDir)
+	}
+	cassettes := make(map[string]MapsCassette, len(files))
+	for _, file := range files {
+		data, readErr := ioutil.ReadFile(file)
+		if readErr != nil {
+			return nil, readErr
+		}
+		cassette := MapsCassette{}
+		if err = json.Unmarshal(data, &cassette); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", file, err)
+		}
+		cassettes[mapsCassetteFileName(cassette.Method, cassette.Path, cassette.Query)] = cassette
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		cassette, exists := cassettes[mapsCassetteFileName(req.Method, req.URL.Path, scrubQuery(req.URL))]
+		if !exists {
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(writer, `{"status": "NOT_FOUND", "error_message": "no cassette for %s %s"}`,
+				req.Method, strings.ReplaceAll(req.URL.Path, `"`, ""))
+			return
+		}
+		writer.Header().Set("Content-Type", cassette.ContentType)
+		writer.WriteHeader(cassette.StatusCode)
+		_, _ = writer.Write([]byte(cassette.Body))
+	}), nil
+}
+
+// CreateMapsReplayServer starts a local server replaying the cassettes of the cassette directory
+func CreateMapsReplayServer(cassetteDir string) (*httptest.Server, error) {
+	handler, err := MapsReplayHandler(cassetteDir)
+	if err != nil {
+		return nil, err
+	}
+	return httptest.NewServer(handler), nil
+}
+
+// CreateRecordingMapsClient creates a MapsClient recording its exchanges with Google Maps to the cassette directory
+func CreateRecordingMapsClient(apiKey string, cassetteDir string) MapsClient {
+	return createMapsClient(apiKey, maps.WithHTTPClient(&http.Client{Transport: &mapsStatusTransport{Transport: &MapsRecorder{CassetteDir: cassetteDir}}}))
+}
+
+// CreateReplayMapsClient creates a MapsClient sending requests to a replay server of recorded exchanges
+// replayed next page tokens take effect at once, so the client does not wait for them
+func CreateReplayMapsClient(replayServerURL string) MapsClient {
+	mapsClient := createMapsClient(replayApiKey, maps.WithBaseURL(replayServerURL))
+	mapsClient.nextPageDelay = 0
+	return mapsClient
+}
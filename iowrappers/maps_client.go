@@ -7,9 +7,11 @@ import (
 	"github.com/weihesdlegend/Vacation-planner/utils"
 	"go.uber.org/zap"
 	"googlemaps.github.io/maps"
+	"net/http"
 	"os"
 	"reflect"
 	"strings"
+	"time"
 )
 
 // abstraction of a client that performs location-based operations such as nearby search
@@ -23,6 +25,9 @@ type MapsClient struct {
 	client               *maps.Client
 	apiKey               string
 	DetailedSearchFields []string
+	meter                *MapsMeter
+	nextPageDelay        time.Duration // wait for next page tokens to take effect
+	retryBaseDelay       time.Duration // delay before the first retry of failed calls
 }
 
 func (mapsClient *MapsClient) SetDetailedSearchFields(fields []string) {
@@ -31,17 +36,33 @@ func (mapsClient *MapsClient) SetDetailedSearchFields(fields []string) {
 		strings.Join(mapsClient.DetailedSearchFields, ", "))
 }
 
+// SetRetryBaseDelay sets the delay before the first retry of failed calls, which is MapsRetryBaseDelay by default
+func (mapsClient *MapsClient) SetRetryBaseDelay(delay time.Duration) {
+	mapsClient.retryBaseDelay = delay
+}
+
+// SetMeter meters calls of the client, which are not metered if the meter is nil
+func (mapsClient *MapsClient) SetMeter(meter *MapsMeter) {
+	mapsClient.meter = meter
+}
+
 // factory method for MapsClient
 func CreateMapsClient(apiKey string) MapsClient {
+	return createMapsClient(apiKey)
+}
+
+func createMapsClient(apiKey string, options ...maps.ClientOption) MapsClient {
 	logErr(CreateLogger(), utils.LogError)
-	mapsClient, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	// options may replace the HTTP client, which then converts status codes itself
+	defaultOptions := []maps.ClientOption{maps.WithAPIKey(apiKey), maps.WithHTTPClient(&http.Client{Transport: &mapsStatusTransport{}})}
+	mapsClient, err := maps.NewClient(append(defaultOptions, options...)...)
 	if err != nil {
 		Logger.Fatal(err)
 	}
 	if reflect.ValueOf(mapsClient).IsNil() {
 		Logger.Fatal(errors.New("maps client does not exist"))
 	}
-	return MapsClient{client: mapsClient, apiKey: apiKey}
+	return MapsClient{client: mapsClient, apiKey: apiKey, nextPageDelay: GoogleNearbySearchDelay, retryBaseDelay: MapsRetryBaseDelay}
 }
 
 func CreateLogger() error {
@@ -73,6 +94,9 @@ func (mapsClient *MapsClient) ReverseGeocoding(context context.Context, latitude
 		ResultType: []string{"country", "locality"},
 	}
 	Logger.Debugf("reverse geocoding for latitude/longitude: %.2f/%.2f", latitude, longitude)
+	if err := mapsClient.meter.Acquire(context, MapsSKUGeocoding); err != nil {
+		return GeocodeQuery{}, err
+	}
 	geocodingResults, err := mapsClient.client.ReverseGeocode(context, request)
 	if err != nil {
 		return GeocodeQuery{}, err
@@ -0,0 +1,218 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Google Maps APIs are billed by SKU
+const (
+	MapsSKUGeocoding    = "geocoding" // geocoding and reverse geocoding
+	MapsSKUNearbySearch = "nearby_search"
+	MapsSKUPlaceDetails = "place_details"
+
+	MapsQuotaStatsRetention = 30 * 24 * time.Hour
+	MapsQuotaDateLayout     = "2006-01-02"
+
+	mapsQuotaKeyPrefix = "maps_quota"
+	microDollars       = 1e6
+)
+
+// MapsSKUCosts are list prices in USD per call of SKUs
+var MapsSKUCosts = map[string]float64{
+	MapsSKUGeocoding:    0.005,
+	MapsSKUNearbySearch: 0.032,
+	MapsSKUPlaceDetails: 0.017,
+}
+
+var ErrMapsBudgetExceeded = errors.New("daily Google Maps budget exceeded")
+
+// MapsQuotaConfig limits Google Maps calls of all servers sharing Redis
+type MapsQuotaConfig struct {
+	RateLimit   float64 // calls per second refilling the token bucket, unlimited if not positive
+	Burst       int     // size of the token bucket, at least 1
+	DailyBudget float64 // spend ceiling in USD of a UTC day, unlimited if not positive
+}
+
+// MapsMeter counts Google Maps calls per SKU per day, per user and per request in Redis,
+// and makes calls wait for the token bucket and fail once the daily budget is spent.
+// The methods of a nil MapsMeter allow every call.
+type MapsMeter struct {
+	redisClient *RedisClient
+	config      MapsQuotaConfig
+}
+
+// MapsQuotaStats are the calls per SKU of a day and of a user and a request if given
+type MapsQuotaStats struct {
+	Date         string           `json:"date"`
+	Calls        map[string]int64 `json:"calls"`
+	Spend        float64          `json:"spend"`
+	DailyBudget  float64          `json:"daily_budget"`
+	CacheOnly    bool             `json:"cache_only"`
+	UserCalls    map[string]int64 `json:"user_calls,omitempty"`
+	RequestCalls map[string]int64 `json:"request_calls,omitempty"`
+}
+
+func CreateMapsMeter(redisClient *RedisClient, config MapsQuotaConfig) *MapsMeter {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &MapsMeter{redisClient: redisClient, config: config}
+}
+
+// tokenBucketScript takes a token from the bucket of KEYS[1] with the rate per second ARGV[1], the size ARGV[2]
+// and the current time ARGV[3] in milliseconds, and returns 0 or the milliseconds to wait for the next token
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "timestamp")
+local tokens = tonumber(bucket[1])
+local timestamp = tonumber(bucket[2])
+if tokens == nil or timestamp == nil then
+	tokens = burst
+	timestamp = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - timestamp) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "timestamp", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// Acquire is called before each Google Maps call of the SKU
+// it returns ErrMapsBudgetExceeded over the daily budget, or the context error if the context is done before a token is available.
+// Calls in flight can exceed the budget by their costs, and Redis errors never block calls.
+func (meter *MapsMeter) Acquire(context context.Context, sku string) error {
+	if meter == nil {
+		return nil
+	}
+	if meter.CacheOnly(context) {
+		return ErrMapsBudgetExceeded
+	}
+	if err := meter.waitForToken(context); err != nil {
+		return err
+	}
+	meter.count(context, sku)
+	return nil
+}
+
+func (meter *MapsMeter) waitForToken(context context.Context) error {
+	if meter.config.RateLimit <= 0 {
+		return nil
+	}
+	for {
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		wait, err := tokenBucketScript.Run(context, &meter.redisClient.client, []string{mapsQuotaKey("token_bucket")},
+			meter.config.RateLimit, meter.config.Burst, now).Int64()
+		if err != nil {
+			Logger.Errorf("Google Maps rate limiter failure: %v", err)
+			return nil
+		}
+		if wait == 0 {
+			return nil
+		}
+		timer := time.NewTimer(time.Duration(wait) * time.Millisecond)
+		select {
+		case <-context.Done():
+			timer.Stop()
+			return context.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (meter *MapsMeter) count(context context.Context, sku string) {
+	date := time.Now().UTC().Format(MapsQuotaDateLayout)
+	keys := []string{mapsQuotaKey("calls", date)}
+	if username, _ := context.Value(UsernameKey).(string); username != "" {
+		keys = append(keys, mapsQuotaKey("user_calls", username, date))
+	}
+	if requestId, _ := context.Value(RequestIdKey).(string); requestId != "" {
+		keys = append(keys, mapsQuotaKey("request_calls", requestId))
+	}
+
+	pipeline := meter.redisClient.client.Pipeline()
+	for _, key := range keys {
+		pipeline.HIncrBy(context, key, sku, 1)
+		pipeline.Expire(context, key, MapsQuotaStatsRetention)
+	}
+	spendKey := mapsQuotaKey("spend", date)
+	pipeline.IncrBy(context, spendKey, int64(math.Round(MapsSKUCosts[sku]*microDollars)))
+	pipeline.Expire(context, spendKey, MapsQuotaStatsRetention)
+	if _, err := pipeline.Exec(context); err != nil {
+		Logger.Errorf("failed to count Google Maps calls: %v", err)
+	}
+}
+
+// CacheOnly returns true once the spend of the day reaches the daily budget
+// places are then only served from cache until the next UTC day
+func (meter *MapsMeter) CacheOnly(context context.Context) bool {
+	if meter == nil || meter.config.DailyBudget <= 0 {
+		return false
+	}
+	spend, err := meter.spend(context, time.Now().UTC().Format(MapsQuotaDateLayout))
+	if err != nil {
+		Logger.Errorf("failed to get Google Maps spend: %v", err)
+		return false
+	}
+	return spend >= meter.config.DailyBudget
+}
+
+func (meter *MapsMeter) spend(context context.Context, date string) (float64, error) {
+	microSpend, err := meter.redisClient.client.Get(context, mapsQuotaKey("spend", date)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return float64(microSpend) / microDollars, err
+}
+
+// Stats returns calls of the date in the format of MapsQuotaDateLayout, calls of the user on the date and calls of the request
+// the username and the request ID are optional
+func (meter *MapsMeter) Stats(context context.Context, date string, username string, requestId string) (stats MapsQuotaStats, err error) {
+	stats = MapsQuotaStats{Date: date, DailyBudget: meter.config.DailyBudget}
+	if stats.Calls, err = meter.calls(context, mapsQuotaKey("calls", date)); err != nil {
+		return
+	}
+	if stats.Spend, err = meter.spend(context, date); err != nil {
+		return
+	}
+	stats.CacheOnly = meter.config.DailyBudget > 0 && stats.Spend >= meter.config.DailyBudget &&
+		date == time.Now().UTC().Format(MapsQuotaDateLayout)
+	if username != "" {
+		if stats.UserCalls, err = meter.calls(context, mapsQuotaKey("user_calls", username, date)); err != nil {
+			return
+		}
+	}
+	if requestId != "" {
+		stats.RequestCalls, err = meter.calls(context, mapsQuotaKey("request_calls", requestId))
+	}
+	return
+}
+
+func (meter *MapsMeter) calls(context context.Context, key string) (map[string]int64, error) {
+	counts, err := meter.redisClient.client.HGetAll(context, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	calls := make(map[string]int64, len(counts))
+	for sku, count := range counts {
+		calls[sku], _ = strconv.ParseInt(count, 10, 64)
+	}
+	return calls, nil
+}
+
+func mapsQuotaKey(parts ...string) string {
+	return strings.Join(append([]string{mapsQuotaKeyPrefix}, parts...), ":")
+}
@@ -0,0 +1,105 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MapsMaxAttempts is the number of attempts of each Google Maps call, including the first one
+const MapsMaxAttempts = 3
+
+// delays before retries double from the base delay up to the maximum delay, and each delay is jittered by up to half of it
+// the base delay of a MapsClient can be changed with SetRetryBaseDelay
+const (
+	MapsRetryBaseDelay = 200 * time.Millisecond
+	MapsRetryMaxDelay  = 2 * time.Second
+)
+
+// Google Maps API statuses of transient failures
+var retryableMapsStatuses = []string{"OVER_QUERY_LIMIT", "UNKNOWN_ERROR"}
+
+// MapsStatusCodeError is a Google Maps response with an HTTP status code of throttling or server errors
+type MapsStatusCodeError struct {
+	StatusCode int
+}
+
+func (err *MapsStatusCodeError) Error() string {
+	return fmt.Sprintf("maps: HTTP status %d %s", err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// mapsStatusTransport turns responses with status codes 429 and 5xx into MapsStatusCodeError
+// the maps library decodes responses regardless of status codes
+type mapsStatusTransport struct {
+	Transport http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (statusTransport *mapsStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := statusTransport.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return nil, &MapsStatusCodeError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// isRetryableMapsError returns true for throttling, server errors, network timeouts and transient API statuses
+// searches of next page tokens are also retried on INVALID_REQUEST, as tokens take a while to become valid
+func isRetryableMapsError(err error, pageToken string) bool {
+	if err == nil || errors.Is(err, ErrMapsBudgetExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusCodeErr *MapsStatusCodeError
+	if errors.As(err, &statusCodeErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, status := range retryableMapsStatuses {
+		if strings.HasPrefix(err.Error(), "maps: "+status) {
+			return true
+		}
+	}
+	return pageToken != "" && strings.HasPrefix(err.Error(), "maps: INVALID_REQUEST")
+}
+
+// retryMapsCall makes the call up to MapsMaxAttempts times with jittered exponential backoff from the base delay while errors are retryable
+// it returns the last error of the call, or the context error if the context is done while waiting for a retry
+func retryMapsCall(context context.Context, baseDelay time.Duration, description string, retryable func(error) bool, call func() error) error {
+	delay := baseDelay
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt == MapsMaxAttempts || context.Err() != nil || !retryable(err) {
+			return err
+		}
+		jitteredDelay := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		Logger.Debugf("[%s] retrying %s in %s after attempt %d: %v", context.Value(RequestIdKey), description, jitteredDelay, attempt, err)
+		timer := time.NewTimer(jitteredDelay)
+		select {
+		case <-context.Done():
+			timer.Stop()
+			return context.Err()
+		case <-timer.C:
+		}
+		if delay *= 2; delay > MapsRetryMaxDelay {
+			delay = MapsRetryMaxDelay
+		}
+	}
+}
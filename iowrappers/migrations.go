@@ -0,0 +1,318 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// states of migration runs
+const (
+	MigrationStateNotStarted = "not_started"
+	MigrationStateRunning    = "running"
+	MigrationStatePaused     = "paused"
+	MigrationStateCompleted  = "completed"
+	MigrationStateFailed     = "failed"
+	// the run is running, but the server running it stopped before the run completed
+	MigrationStateInterrupted = "interrupted"
+)
+
+const (
+	MigrationLockTTL = 5 * time.Minute // a batch is expected to finish within the TTL
+	// changes and errors of a run kept in its status
+	MigrationMaxReportedItems = 100
+
+	migrationRunKeyPrefix      = "migration_runs:"
+	migrationLockKeyPrefix     = "migration_locks:"
+	migrationStatusMaxAttempts = 10
+)
+
+var (
+	ErrMigrationNotFound     = errors.New("migration not found")
+	ErrMigrationRunning      = errors.New("migration is running")
+	ErrMigrationNotRunning   = errors.New("migration is not running")
+	ErrMigrationNotResumable = errors.New("migration cannot be resumed")
+)
+
+// Migration migrates data in batches, and runs of a migration are resumed from the checkpoint after the last batch
+type Migration interface {
+	Name() string
+	// Version is increased when the changes made by the migration change, and runs of other versions cannot be resumed
+	Version() int
+	// Batch migrates the batch after the checkpoint, where an empty checkpoint is the start of the data.
+	// Dry runs report the changes of the batch without writing them.
+	Batch(context context.Context, checkpoint string, dryRun bool) (MigrationBatch, error)
+}
+
+// MigrationBatch is the result of a batch of a migration
+type MigrationBatch struct {
+	Checkpoint string   // where the next batch starts
+	Done       bool     // no batch after this batch
+	Processed  int      // number of items in the batch
+	Changes    []string // changes of items, not written in dry runs
+	Errors     []string // items failed to migrate, which do not stop the run
+}
+
+// MigrationStatus is the progress of the last run of a migration, which is persisted in Redis
+type MigrationStatus struct {
+	Name       string    `json:"name"`
+	Version    int       `json:"version"`
+	State      string    `json:"state"`
+	DryRun     bool      `json:"dry_run"`
+	Checkpoint string    `json:"checkpoint"`
+	Batches    int       `json:"batches"`
+	Processed  int       `json:"processed"`
+	Changed    int       `json:"changed"`
+	Failed     int       `json:"failed"`
+	Changes    []string  `json:"changes,omitempty"` // the first changes of the run
+	Errors     []string  `json:"errors,omitempty"`  // the last errors of items of the run
+	Error      string    `json:"error,omitempty"`   // the error failing the run
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// MigrationRegistry runs registered migrations in the background with progress in Redis,
+// so that runs can be paused and resumed by any server sharing Redis
+type MigrationRegistry struct {
+	redisClient *RedisClient
+	migrations  map[string]Migration
+	names       []string // in the order of registration
+}
+
+func CreateMigrationRegistry(redisClient *RedisClient) *MigrationRegistry {
+	return &MigrationRegistry{redisClient: redisClient, migrations: make(map[string]Migration)}
+}
+
+// Register adds the migration to the registry, migrations are registered before the server starts
+func (registry *MigrationRegistry) Register(migration Migration) error {
+	if _, exists := registry.migrations[migration.Name()]; exists {
+		return fmt.Errorf("migration %s is already registered", migration.Name())
+	}
+	registry.migrations[migration.Name()] = migration
+	registry.names = append(registry.names, migration.Name())
+	return nil
+}
+
+// Statuses returns the status of the last run of every migration in the order of registration
+func (registry *MigrationRegistry) Statuses(context context.Context) ([]MigrationStatus, error) {
+	statuses := make([]MigrationStatus, 0, len(registry.names))
+	for _, name := range registry.names {
+		status, err := registry.Status(context, name)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Status returns the status of the last run of the migration
+func (registry *MigrationRegistry) Status(context context.Context, name string) (MigrationStatus, error) {
+	migration, exists := registry.migrations[name]
+	if !exists {
+		return MigrationStatus{}, ErrMigrationNotFound
+	}
+	status, err := registry.loadStatus(context, &registry.redisClient.client, migration)
+	if err != nil || status.State != MigrationStateRunning {
+		return status, err
+	}
+	locked, err := registry.redisClient.client.Exists(context, migrationLockKeyPrefix+name).Result()
+	if err == nil && locked == 0 {
+		status.State = MigrationStateInterrupted
+	}
+	return status, err
+}
+
+// Start runs the migration from the start of the data in the background
+func (registry *MigrationRegistry) Start(context context.Context, name string, dryRun bool) (MigrationStatus, error) {
+	migration, exists := registry.migrations[name]
+	if !exists {
+		return MigrationStatus{}, ErrMigrationNotFound
+	}
+	lockToken, err := registry.lock(context, name)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	now := time.Now()
+	status := MigrationStatus{Name: name, Version: migration.Version(), State: MigrationStateRunning, DryRun: dryRun, StartedAt: now, UpdatedAt: now}
+	if err = registry.saveStatus(context, &registry.redisClient.client, status); err != nil {
+		registry.unlock(context, name, lockToken)
+		return MigrationStatus{}, err
+	}
+	go registry.run(migration, lockToken)
+	return status, nil
+}
+
+// Pause stops a running migration after its current batch
+func (registry *MigrationRegistry) Pause(context context.Context, name string) (MigrationStatus, error) {
+	migration, exists := registry.migrations[name]
+	if !exists {
+		return MigrationStatus{}, ErrMigrationNotFound
+	}
+	return registry.updateStatus(context, migration, func(status *MigrationStatus) error {
+		if status.State != MigrationStateRunning {
+			return ErrMigrationNotRunning
+		}
+		status.State = MigrationStatePaused
+		return nil
+	})
+}
+
+// Resume runs a paused, failed or interrupted migration from its checkpoint in the background
+func (registry *MigrationRegistry) Resume(context context.Context, name string) (MigrationStatus, error) {
+	migration, exists := registry.migrations[name]
+	if !exists {
+		return MigrationStatus{}, ErrMigrationNotFound
+	}
+	lockToken, err := registry.lock(context, name)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	// runs holding no lock are interrupted
+	status, err := registry.updateStatus(context, migration, func(status *MigrationStatus) error {
+		if status.State == MigrationStateNotStarted || status.State == MigrationStateCompleted {
+			return fmt.Errorf("%w: the migration is %s", ErrMigrationNotResumable, status.State)
+		}
+		if status.Version != migration.Version() {
+			return fmt.Errorf("%w: the run is of version %d, and the migration is of version %d", ErrMigrationNotResumable, status.Version, migration.Version())
+		}
+		status.State = MigrationStateRunning
+		status.Error = ""
+		return nil
+	})
+	if err != nil {
+		registry.unlock(context, name, lockToken)
+		return status, err
+	}
+	go registry.run(migration, lockToken)
+	return status, nil
+}
+
+// run migrates batches until the migration is done, fails or is paused
+func (registry *MigrationRegistry) run(migration Migration, lockToken string) {
+	ctx := context.WithValue(context.Background(), RequestIdKey, "migration:"+migration.Name())
+	defer registry.unlock(ctx, migration.Name(), lockToken)
+	Logger.Infof("[data migration] running migration %s", migration.Name())
+
+	for {
+		// pauses of other servers are in the persisted status
+		status, err := registry.loadStatus(ctx, &registry.redisClient.client, migration)
+		if err != nil {
+			Logger.Errorf("[data migration] failed to load the status of migration %s: %v", migration.Name(), err)
+			return
+		}
+		if status.State != MigrationStateRunning {
+			Logger.Infof("[data migration] migration %s is %s at checkpoint %q", migration.Name(), status.State, status.Checkpoint)
+			return
+		}
+
+		batch, batchErr := migration.Batch(ctx, status.Checkpoint, status.DryRun)
+		status, err = registry.updateStatus(ctx, migration, func(status *MigrationStatus) error {
+			if batchErr != nil {
+				status.State = MigrationStateFailed
+				status.Error = batchErr.Error()
+				return nil
+			}
+			status.addBatch(batch)
+			if batch.Done {
+				status.State = MigrationStateCompleted
+			}
+			return nil
+		})
+		if err != nil {
+			Logger.Errorf("[data migration] failed to save the progress of migration %s: %v", migration.Name(), err)
+			return
+		}
+		if status.State == MigrationStateCompleted || status.State == MigrationStateFailed {
+			Logger.Infof("[data migration] migration %s is %s after %d batches, %d items changed, %d items failed %s",
+				migration.Name(), status.State, status.Batches, status.Changed, status.Failed, status.Error)
+			return
+		}
+		registry.redisClient.client.Expire(ctx, migrationLockKeyPrefix+migration.Name(), MigrationLockTTL)
+	}
+}
+
+func (status *MigrationStatus) addBatch(batch MigrationBatch) {
+	status.Checkpoint = batch.Checkpoint
+	status.Batches++
+	status.Processed += batch.Processed
+	status.Changed += len(batch.Changes)
+	status.Failed += len(batch.Errors)
+	for _, change := range batch.Changes {
+		if len(status.Changes) == MigrationMaxReportedItems {
+			break
+		}
+		status.Changes = append(status.Changes, change)
+	}
+	status.Errors = append(status.Errors, batch.Errors...)
+	if len(status.Errors) > MigrationMaxReportedItems {
+		status.Errors = status.Errors[len(status.Errors)-MigrationMaxReportedItems:]
+	}
+}
+
+// lock makes sure a migration is run by one server at a time
+func (registry *MigrationRegistry) lock(context context.Context, name string) (string, error) {
+	lockToken, acquired, err := registry.redisClient.AcquireLock(context, migrationLockKeyPrefix+name, MigrationLockTTL)
+	if err != nil {
+		return "", err
+	}
+	if !acquired {
+		return "", ErrMigrationRunning
+	}
+	return lockToken, nil
+}
+
+func (registry *MigrationRegistry) unlock(context context.Context, name string, lockToken string) {
+	if err := registry.redisClient.ReleaseLock(context, migrationLockKeyPrefix+name, lockToken); err != nil {
+		Logger.Errorf("[data migration] failed to release the lock of migration %s: %v", name, err)
+	}
+}
+
+func (registry *MigrationRegistry) loadStatus(context context.Context, client redis.Cmdable, migration Migration) (status MigrationStatus, err error) {
+	json_, err := client.Get(context, migrationRunKeyPrefix+migration.Name()).Result()
+	if err == redis.Nil {
+		return MigrationStatus{Name: migration.Name(), Version: migration.Version(), State: MigrationStateNotStarted}, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(json_), &status)
+	return
+}
+
+func (registry *MigrationRegistry) saveStatus(context context.Context, client redis.Cmdable, status MigrationStatus) error {
+	json_, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return client.Set(context, migrationRunKeyPrefix+status.Name, json_, 0).Err()
+}
+
+// updateStatus changes the persisted status with optimistic locking, so that pauses are not overwritten by the progress of batches
+func (registry *MigrationRegistry) updateStatus(context context.Context, migration Migration, update func(status *MigrationStatus) error) (status MigrationStatus, err error) {
+	key := migrationRunKeyPrefix + migration.Name()
+	for attempt := 0; attempt < migrationStatusMaxAttempts; attempt++ {
+		err = registry.redisClient.client.Watch(context, func(tx *redis.Tx) error {
+			var txErr error
+			if status, txErr = registry.loadStatus(context, tx, migration); txErr != nil {
+				return txErr
+			}
+			if txErr = update(&status); txErr != nil {
+				return txErr
+			}
+			status.UpdatedAt = time.Now()
+			_, txErr = tx.TxPipelined(context, func(pipeliner redis.Pipeliner) error {
+				return registry.saveStatus(context, pipeliner, status)
+			})
+			return txErr
+		}, key)
+		if err != redis.TxFailedErr {
+			return
+		}
+	}
+	return
+}
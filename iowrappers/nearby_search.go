@@ -8,6 +8,7 @@ import (
 	"github.com/weihesdlegend/Vacation-planner/utils"
 	"googlemaps.github.io/maps"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,7 +32,76 @@ type PlaceSearchRequest struct {
 	MinNumResults uint
 }
 
-func GoogleMapsNearbySearchWrapper(c MapsClient, location string, placeType string, radius uint,
+// PlaceTypeSearchResult reports the pages of a place type searched by an extensive nearby search
+type PlaceTypeSearchResult struct {
+	Pages    int   // number of pages of results received
+	Complete bool  // true if there are no more pages
+	Err      error // error of the page that failed after retries, pages are not searched after a failure
+}
+
+// NearbySearchResult is the outcome of an extensive nearby search
+// places of pages received are kept when other place types, pages or details searches fail
+type NearbySearchResult struct {
+	Places        []POI.Place
+	PlaceTypes    map[POI.LocationType]*PlaceTypeSearchResult
+	FailedDetails []string // IDs of places without details as details searches failed
+	Err           error    // context error if the search stopped before it finished
+}
+
+// Partial returns true if any search of the result failed
+func (result *NearbySearchResult) Partial() bool {
+	if result.Err != nil || len(result.FailedDetails) > 0 {
+		return true
+	}
+	for _, placeTypeResult := range result.PlaceTypes {
+		if placeTypeResult.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PartialNearbySearchError is returned with the places of a nearby search if any search of the result failed,
+// so that callers can decide whether the places are good enough
+type PartialNearbySearchError struct {
+	Result *NearbySearchResult
+}
+
+func (err *PartialNearbySearchError) Error() string {
+	failedPlaceTypes := make([]string, 0)
+	for placeType, placeTypeResult := range err.Result.PlaceTypes {
+		if placeTypeResult.Err != nil {
+			failedPlaceTypes = append(failedPlaceTypes, string(placeType))
+		}
+	}
+	sort.Strings(failedPlaceTypes)
+	message := fmt.Sprintf("partial nearby search results: %d places, failed place types [%s], %d failed details searches",
+		len(err.Result.Places), strings.Join(failedPlaceTypes, ", "), len(err.Result.FailedDetails))
+	if cause := err.Unwrap(); cause != nil {
+		message += ": " + cause.Error()
+	}
+	return message
+}
+
+// Unwrap returns the context error of the search, or the error of the first failed place type
+func (err *PartialNearbySearchError) Unwrap() error {
+	if err.Result.Err != nil {
+		return err.Result.Err
+	}
+	placeTypes := make([]string, 0, len(err.Result.PlaceTypes))
+	for placeType := range err.Result.PlaceTypes {
+		placeTypes = append(placeTypes, string(placeType))
+	}
+	sort.Strings(placeTypes)
+	for _, placeType := range placeTypes {
+		if placeTypeErr := err.Result.PlaceTypes[POI.LocationType(placeType)].Err; placeTypeErr != nil {
+			return placeTypeErr
+		}
+	}
+	return nil
+}
+
+func GoogleMapsNearbySearchWrapper(context context.Context, c MapsClient, location string, placeType string, radius uint,
 	pageToken string) (resp maps.PlacesSearchResponse, err error) {
 	latLng, err := maps.ParseLatLng(location)
 	// since we try to use Redis and database before calling nearby search,
@@ -47,43 +117,94 @@ func GoogleMapsNearbySearchWrapper(c MapsClient, location string, placeType stri
 		PageToken: pageToken,
 		RankBy:    maps.RankBy("prominence"),
 	}
-	resp, err = c.client.NearbySearch(context.Background(), &mapsReq)
+	retryable := func(err error) bool { return isRetryableMapsError(err, pageToken) }
+	err = retryMapsCall(context, c.retryBaseDelay, "nearby search of "+placeType, retryable, func() (callErr error) {
+		if callErr = c.meter.Acquire(context, MapsSKUNearbySearch); callErr != nil {
+			return
+		}
+		resp, callErr = c.client.NearbySearch(context, &mapsReq)
+		return
+	})
 	logErr(err, utils.LogError)
 	return
 }
 
+// NearbySearch returns places found before the search timeout or the cancellation of the context,
+// and a PartialNearbySearchError if any search failed
 func (mapsClient *MapsClient) NearbySearch(c context.Context, request *PlaceSearchRequest) ([]POI.Place, error) {
 	var maxReqTimes uint = 5
-	var places = make([]POI.Place, 0)
-	var searchDone = make(chan bool)
 	ctx, cancelFunc := context.WithTimeout(c, GoogleMapsSearchTimeout)
 	defer cancelFunc()
 
-	go mapsClient.ExtensiveNearbySearch(ctx, maxReqTimes, request, &places, searchDone)
-
-	select {
-	case <-searchDone:
-		return places, nil
-	case <-ctx.Done():
-		return places, errors.New("maps search time out")
+	result := mapsClient.ExtensiveNearbySearch(ctx, maxReqTimes, request)
+	if result.Partial() {
+		return result.Places, &PartialNearbySearchError{Result: result}
 	}
+	return result.Places, nil
 }
 
-func (mapsClient *MapsClient) PlaceDetailsSearch(context.Context, string) (place POI.Place, err error) {
+// placeDetailsFields are the fields of places found by PlaceDetailsSearch
+var placeDetailsFields = []string{"place_id", "name", "geometry", "formatted_address", "adr_address", "business_status",
+	"types", "opening_hours", "price_level", "rating", "user_ratings_total", "url", "photos"}
+
+// PlaceDetailsSearch finds a place with its Google Maps place ID
+func (mapsClient *MapsClient) PlaceDetailsSearch(context context.Context, placeId string) (place POI.Place, err error) {
+	details, err := PlaceDetailedSearch(context, mapsClient, placeId, placeDetailsFields)
+	if err != nil {
+		return
+	}
+	if details.PlaceID == "" {
+		err = errors.New("no details of place " + placeId)
+		return
+	}
+	location := fmt.Sprintf("%f,%f", details.Geometry.Location.Lat, details.Geometry.Location.Lng)
+	hours := &POI.OpeningHours{}
+	if details.OpeningHours != nil {
+		hours.Hours = append(hours.Hours, details.OpeningHours.WeekdayText...)
+	}
+	var photo *maps.Photo
+	if len(details.Photos) > 0 {
+		photo = &details.Photos[0]
+	}
+	place = POI.CreatePlace(details.Name, location, details.AdrAddress, details.FormattedAddress, details.BusinessStatus,
+		detailsLocationType(details.Types), hours, details.PlaceID, details.PriceLevel, details.Rating, details.URL, photo, details.UserRatingsTotal)
 	return
 }
 
+// detailsLocationType returns the first type of a place which is a location type of the planner
+func detailsLocationType(types []string) POI.LocationType {
+	for _, placeType := range types {
+		for _, placeCat := range []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery} {
+			for _, locationType := range POI.GetPlaceTypes(placeCat) {
+				if POI.LocationType(placeType) == locationType {
+					return locationType
+				}
+			}
+		}
+	}
+	if len(types) > 0 {
+		return POI.LocationType(types[0])
+	}
+	return ""
+}
+
 // ExtensiveNearbySearch attempts to find a specified number of places satisfy the request
-// within the maxRequestTime times of calling external APIs
-func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, maxRequestTimes uint, request *PlaceSearchRequest, places *[]POI.Place, done chan bool) {
+// within the maxRequestTime times of calling external APIs for each place type,
+// and stops with the places found so far when the context is done
+func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, maxRequestTimes uint, request *PlaceSearchRequest) *NearbySearchResult {
 	placeTypes := POI.GetPlaceTypes(request.PlaceCat) // get place types in a category
 
+	result := &NearbySearchResult{
+		Places:        make([]POI.Place, 0),
+		PlaceTypes:    make(map[POI.LocationType]*PlaceTypeSearchResult),
+		FailedDetails: make([]string, 0),
+	}
 	nextPageTokenMap := make(map[POI.LocationType]string) // map for place type to search token
 	for _, placeType := range placeTypes {
 		nextPageTokenMap[placeType] = ""
+		result.PlaceTypes[placeType] = &PlaceTypeSearchResult{}
 	}
 
-	var reqTimes uint = 0    // number of queries for each location type
 	var totalResult uint = 0 // number of results so far, keep this number low
 
 	microAddrMap := make(map[string]string) // map place ID to its micro-address
@@ -92,23 +213,36 @@ func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, max
 
 	searchStartTime := time.Now()
 
-	var err error
-	for totalResult < request.MinNumResults {
-		// if error, return regardless of number of results obtained
-		if err != nil {
-			done <- true
-			return
+search:
+	for reqTimes := uint(0); reqTimes < maxRequestTimes && totalResult < request.MinNumResults; reqTimes++ {
+		if reqTimes > 0 {
+			// sleep to make sure new next page token comes to effect
+			timer := time.NewTimer(mapsClient.nextPageDelay)
+			select {
+			case <-context.Done():
+				timer.Stop()
+				result.Err = context.Err()
+				break search
+			case <-timer.C:
+			}
 		}
+
+		searching := false
 		for _, placeType := range placeTypes {
-			if reqTimes > 0 && nextPageTokenMap[placeType] == "" { // no more result for this location type
+			placeTypeResult := result.PlaceTypes[placeType]
+			if placeTypeResult.Complete || placeTypeResult.Err != nil {
 				continue
 			}
+			searching = true
 
 			nextPageToken := nextPageTokenMap[placeType]
-			searchResp, error_ := GoogleMapsNearbySearchWrapper(*mapsClient, request.Location, string(placeType), request.Radius, nextPageToken)
-			if error_ != nil {
-				err = error_
-				Logger.Error(err)
+			searchResp, err := GoogleMapsNearbySearchWrapper(context, *mapsClient, request.Location, string(placeType), request.Radius, nextPageToken)
+			if context.Err() != nil {
+				result.Err = context.Err()
+				break search
+			}
+			if err != nil {
+				placeTypeResult.Err = err
 				continue
 			}
 
@@ -119,7 +253,8 @@ func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, max
 				}
 			}
 
-			detailSearchResults := make([]PlaceDetailSearchResult, len(placeIdMap))
+			// indexed by the index in search response, places with opening hours have no result
+			detailSearchResults := make([]PlaceDetailSearchResult, len(searchResp.Results))
 			var wg sync.WaitGroup
 			wg.Add(len(placeIdMap))
 			for idx, placeId := range placeIdMap {
@@ -128,7 +263,15 @@ func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, max
 			wg.Wait()
 
 			// fill fields from detail search results to nearby search results
-			for _, placeDetails := range detailSearchResults {
+			for idx := range detailSearchResults {
+				placeDetails := detailSearchResults[idx]
+				if placeDetails.Err != nil {
+					result.FailedDetails = append(result.FailedDetails, placeIdMap[idx])
+					continue
+				}
+				if placeDetails.Res == nil {
+					continue
+				}
 				searchRespIdx := placeDetails.RespIdx
 				placeId := searchResp.Results[searchRespIdx].PlaceID
 				searchResp.Results[searchRespIdx].OpeningHours = placeDetails.Res.OpeningHours
@@ -137,34 +280,36 @@ func (mapsClient *MapsClient) ExtensiveNearbySearch(context context.Context, max
 				urlMap[placeId] = placeDetails.Res.URL
 			}
 
-			*places = append(*places, parsePlacesSearchResponse(searchResp, placeType, microAddrMap, placeMap, urlMap)...)
+			result.Places = append(result.Places, parsePlacesSearchResponse(searchResp, placeType, microAddrMap, placeMap, urlMap)...)
 			totalResult += uint(len(searchResp.Results))
 			nextPageTokenMap[placeType] = searchResp.NextPageToken
+			placeTypeResult.Pages++
+			placeTypeResult.Complete = searchResp.NextPageToken == ""
 		}
-		reqTimes++
-		if reqTimes == maxRequestTimes {
+		if !searching { // no more result for any location type
 			break
 		}
-		time.Sleep(GoogleNearbySearchDelay) // sleep to make sure new next page token comes to effect
 	}
 
 	searchDuration := time.Since(searchStartTime)
 
 	// logging
-	requestId := context.Value(RequestIdKey).(string)
+	requestId, _ := context.Value(RequestIdKey).(string)
 	Logger.Infow("request:", requestId, "Logging nearby search",
 		"Maps API call time", searchDuration,
 		"center location (lat,lng)", request.Location,
 		"place category", request.PlaceCat,
 		"total results", totalResult,
+		"partial results", result.Partial(),
 	)
-	done <- true
-	return
+	return result
 }
 
+// PlaceDetailSearchResult is the result of the details search of a place, Res is nil if the search failed with Err
 type PlaceDetailSearchResult struct {
 	Res     *maps.PlaceDetailsResult
 	RespIdx int
+	Err     error
 }
 
 func PlaceDetailsSearchWrapper(context context.Context, mapsClient *MapsClient, idx int, placeId string, fields []string, detailSearchRes *PlaceDetailSearchResult, wg *sync.WaitGroup) {
@@ -172,6 +317,7 @@ func PlaceDetailsSearchWrapper(context context.Context, mapsClient *MapsClient,
 	searchRes, err := PlaceDetailedSearch(context, mapsClient, placeId, fields)
 	if err != nil {
 		Logger.Error(err)
+		*detailSearchRes = PlaceDetailSearchResult{RespIdx: idx, Err: err}
 		return
 	}
 	*detailSearchRes = PlaceDetailSearchResult{Res: &searchRes, RespIdx: idx}
@@ -195,13 +341,21 @@ func PlaceDetailedSearch(context context.Context, mapsClient *MapsClient, placeI
 	}
 
 	startSearchTime := time.Now()
-	resp, err := mapsClient.client.PlaceDetails(context, req)
+	var resp maps.PlaceDetailsResult
+	retryable := func(err error) bool { return isRetryableMapsError(err, "") }
+	err := retryMapsCall(context, mapsClient.retryBaseDelay, "details search of place "+placeId, retryable, func() (callErr error) {
+		if callErr = mapsClient.meter.Acquire(context, MapsSKUPlaceDetails); callErr != nil {
+			return
+		}
+		resp, callErr = mapsClient.client.PlaceDetails(context, req)
+		return
+	})
 	utils.LogErrorWithLevel(err, utils.LogError)
 
 	searchDuration := time.Since(startSearchTime)
 
 	// logging
-	requestId := context.Value(RequestIdKey).(string)
+	requestId, _ := context.Value(RequestIdKey).(string)
 	Logger.Debugw("request:", requestId, "Logging place details search",
 		"Maps API call time", searchDuration,
 		"place ID", resp.PlaceID,
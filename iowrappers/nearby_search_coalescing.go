@@ -0,0 +1,196 @@
+package iowrappers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+const (
+	// the lock of a server expires if the server stops before releasing it, which is longer than external searches
+	NearbySearchLockTTL          = 45 * time.Second
+	NearbySearchLockPollInterval = 100 * time.Millisecond
+	// locks are released after the searches even if the requests are canceled
+	NearbySearchLockReleaseTimeout = 5 * time.Second
+
+	nearbySearchLockKeyPrefix = "nearby_search_lock"
+)
+
+// releaseLockScript deletes the lock of KEYS[1] only if it is still held with the token ARGV[1]
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// nearbySearchCall is an external search that requests of the same location and category wait for
+type nearbySearchCall struct {
+	done   chan struct{}
+	places []POI.Place
+	err    error
+}
+
+// nearbySearchGroup runs one external search at a time for each location and category in the process
+type nearbySearchGroup struct {
+	mutex sync.Mutex
+	calls map[string]*nearbySearchCall
+}
+
+// do runs the search unless the search of the key is running, and returns the places of the running search otherwise
+// requests waiting for the running search stop waiting when their contexts are done,
+// and run the search themselves if the running search stops because its own request is canceled
+func (group *nearbySearchGroup) do(context context.Context, key string, search func() ([]POI.Place, error)) ([]POI.Place, error) {
+	group.mutex.Lock()
+	if group.calls == nil {
+		group.calls = make(map[string]*nearbySearchCall)
+	}
+	for {
+		call, exists := group.calls[key]
+		if !exists {
+			break
+		}
+		group.mutex.Unlock()
+		Logger.Debugf("[%s] waiting for the nearby search of %s in the process", context.Value(RequestIdKey), key)
+		select {
+		case <-call.done:
+			if !isContextErr(call.err) {
+				return call.places, call.err
+			}
+		case <-context.Done():
+			return nil, context.Err()
+		}
+		group.mutex.Lock()
+	}
+	call := &nearbySearchCall{done: make(chan struct{})}
+	group.calls[key] = call
+	group.mutex.Unlock()
+
+	call.places, call.err = search()
+
+	group.mutex.Lock()
+	delete(group.calls, key)
+	group.mutex.Unlock()
+	close(call.done)
+	return call.places, call.err
+}
+
+// isContextErr returns true if the error comes from a canceled or expired context
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// nearbySearchLockKey is keyed on the normalized location, e.g. "nearby_search_lock:usa:chicago:visit" for "Chicago,USA"
+func nearbySearchLockKey(location string, category POI.PlaceCategory) string {
+	parts := strings.Split(location, ",")
+	keyParts := []string{nearbySearchLockKeyPrefix}
+	for idx := len(parts) - 1; idx >= 0; idx-- {
+		keyParts = append(keyParts, strings.TrimSpace(parts[idx]))
+	}
+	return strings.ToLower(strings.Join(append(keyParts, string(category)), ":"))
+}
+
+// externalNearbySearch searches places of the location in the format of "city,country" with the search client and caches them.
+// Concurrent searches of the same location and category are coalesced by single-flight in the process and a Redis lock across servers,
+// so the requests sharing a search get places of the first request within the maximum search radius,
+// and requests waiting for the lock of another server read the places cached by that server.
+func (poiSearcher PoiSearcher) externalNearbySearch(context context.Context, location string, request *PlaceSearchRequest, searchTime time.Time) ([]POI.Place, error) {
+	searchRequest := *request
+	searchRequest.Radius = MaxSearchRadius // use a large search radius whenever we call external maps services
+	lockKey := nearbySearchLockKey(location, request.PlaceCat)
+	search := func() ([]POI.Place, error) {
+		return poiSearcher.lockedNearbySearch(context, lockKey, location, &searchRequest, searchTime)
+	}
+	if poiSearcher.searchGroup == nil {
+		return search()
+	}
+	return poiSearcher.searchGroup.do(context, lockKey, search)
+}
+
+func (poiSearcher PoiSearcher) lockedNearbySearch(context context.Context, lockKey string, location string, request *PlaceSearchRequest, searchTime time.Time) ([]POI.Place, error) {
+	redisClient := &poiSearcher.redisClient
+	token, acquired, err := redisClient.AcquireLock(context, lockKey, NearbySearchLockTTL)
+	if err != nil {
+		// search without the lock if Redis fails
+		Logger.Errorf("failed to acquire lock %s: %v", lockKey, err)
+	} else if !acquired {
+		Logger.Debugf("[%s] waiting for the nearby search of %s of another server", context.Value(RequestIdKey), lockKey)
+		if err = redisClient.WaitForLock(context, lockKey, NearbySearchLockPollInterval); err != nil {
+			return nil, err
+		}
+		return redisClient.NearbySearch(context, request)
+	} else {
+		defer releaseNearbySearchLock(redisClient, lockKey, token)
+		// another server may have searched between the cache lookup of the request and the lock
+		lastSearchTime, cacheMiss := redisClient.GetMapsLastSearchTime(context, location, request.PlaceCat)
+		if cacheMiss == nil && !lastSearchTime.Before(searchTime.Truncate(time.Second)) {
+			return redisClient.NearbySearch(context, request)
+		}
+	}
+
+	newPlaces, err := poiSearcher.searchClient.NearbySearch(context, request)
+	// update Redis with all the new places obtained before releasing the lock
+	poiSearcher.UpdateRedis(context, newPlaces)
+	// the last search time is only recorded for complete searches, and partial and failed searches are searched again after a backoff,
+	// canceled requests do not back off since the next request searches again without waiting
+	if err == nil {
+		if setErr := redisClient.SetMapsLastSearchTime(context, location, request.PlaceCat, searchTime.Format(time.RFC3339)); setErr != nil {
+			Logger.Error(setErr)
+		}
+	} else if context.Err() == nil {
+		if setErr := redisClient.SetMapsSearchBackoff(context, location, request.PlaceCat, MapsPartialSearchBackoff); setErr != nil {
+			Logger.Error(setErr)
+		}
+	}
+	return newPlaces, err
+}
+
+// releaseNearbySearchLock releases the lock with a context of its own, so the lock of a canceled request
+// is released without waiting for it to expire
+func releaseNearbySearchLock(redisClient *RedisClient, lockKey string, token string) {
+	releaseContext, cancel := context.WithTimeout(context.Background(), NearbySearchLockReleaseTimeout)
+	defer cancel()
+	if err := redisClient.ReleaseLock(releaseContext, lockKey, token); err != nil {
+		Logger.Errorf("failed to release lock %s: %v", lockKey, err)
+	}
+}
+
+// AcquireLock sets the lock key with a random token if the lock is not held, and the lock expires after the TTL
+func (redisClient *RedisClient) AcquireLock(context context.Context, key string, ttl time.Duration) (token string, acquired bool, err error) {
+	tokenBytes := make([]byte, 16)
+	if _, err = rand.Read(tokenBytes); err != nil {
+		return
+	}
+	token = hex.EncodeToString(tokenBytes)
+	acquired, err = redisClient.client.SetNX(context, key, token, ttl).Result()
+	return
+}
+
+// ReleaseLock deletes the lock key if the lock is held with the token
+func (redisClient *RedisClient) ReleaseLock(context context.Context, key string, token string) error {
+	return releaseLockScript.Run(context, &redisClient.client, []string{key}, token).Err()
+}
+
+// WaitForLock polls the lock key until the lock is released or expires
+func (redisClient *RedisClient) WaitForLock(context context.Context, key string, pollInterval time.Duration) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		exists, err := redisClient.client.Exists(context, key).Result()
+		if err != nil || exists == 0 {
+			return err
+		}
+		select {
+		case <-context.Done():
+			return context.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package iowrappers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+	"googlemaps.github.io/maps"
+)
+
+const (
+	OverpassSearchTimeout = time.Second * 30
+	// nearby search returns places closest to the search location, places with Wikidata entries first
+	OSMMaxSearchResults = 100
+	// Overpass searches are capped to a smaller radius than Google Maps searches, and return at most osmMaxQueryResults elements
+	// to sort by Wikidata entries and distances, since the public endpoints are slow and rate-limited for large areas
+	OSMMaxSearchRadius = 5000
+	osmMaxQueryResults = 10 * OSMMaxSearchResults
+
+	// places of OpenStreetMap have no ratings, they get the average rating of places on Google Maps and a number of ratings
+	// growing with the tags documenting them, so that plans with them are not ranked by distances only
+	OSMDefaultRating           = 3.0
+	osmBaseUserRatingsTotal    = 10
+	osmNotabilityRatingsFactor = 10
+
+	osmPlacesKeyPrefix   = "osm:placeIDs:"
+	osmGeocodeKey        = "osm:geocode:cities"
+	osmPlaceIdPrefix     = "osm-"
+	osmPlaceURLPrefix    = "https://www.openstreetmap.org/"
+	overpassQueryHeader  = "[out:json][timeout:25];"
+	overpassResultFooter = "out center;"
+)
+
+// osmTag maps an OSM tag to a location type, places with several tags take the first one in osmTags
+type osmTag struct {
+	key          string
+	value        string
+	locationType POI.LocationType
+}
+
+var osmTags = []osmTag{
+	{key: "tourism", value: "museum", locationType: POI.LocationTypeMuseum},
+	{key: "tourism", value: "gallery", locationType: POI.LocationTypeGallery},
+	{key: "amenity", value: "arts_centre", locationType: POI.LocationTypeGallery},
+	{key: "tourism", value: "theme_park", locationType: POI.LocationTypeAmusementPark},
+	{key: "leisure", value: "water_park", locationType: POI.LocationTypeAmusementPark},
+	{key: "leisure", value: "park", locationType: POI.LocationTypePark},
+	{key: "leisure", value: "garden", locationType: POI.LocationTypePark},
+	{key: "amenity", value: "restaurant", locationType: POI.LocationTypeRestaurant},
+	{key: "amenity", value: "fast_food", locationType: POI.LocationTypeRestaurant},
+	{key: "amenity", value: "cafe", locationType: POI.LocationTypeCafe},
+}
+
+// OSMLocationType returns the location type of OSM tags such as tourism=museum, amenity=restaurant and leisure=park
+func OSMLocationType(tags map[string]string) (POI.LocationType, bool) {
+	for _, tag := range osmTags {
+		if tags[tag.key] == tag.value {
+			return tag.locationType, true
+		}
+	}
+	return "", false
+}
+
+// OSMData is the JSON output format of Overpass API, which is also the format of local extracts
+type OSMData struct {
+	Elements []OSMElement `json:"elements"`
+}
+
+// OSMElement is a node, or a way or relation with its center
+type OSMElement struct {
+	Type   string            `json:"type"`
+	ID     int64             `json:"id"`
+	Lat    float64           `json:"lat"`
+	Lon    float64           `json:"lon"`
+	Center *OSMCenter        `json:"center,omitempty"`
+	Tags   map[string]string `json:"tags"`
+}
+
+type OSMCenter struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (element OSMElement) latLng() (float64, float64) {
+	if element.Center != nil {
+		return element.Center.Lat, element.Center.Lon
+	}
+	return element.Lat, element.Lon
+}
+
+// ToPlace converts a named element with tags of a location type to a place, with IDs such as osm-node-123
+// places without parsable opening hours get the default hours of POI.CreatePlace
+func (element OSMElement) ToPlace() (place POI.Place, ok bool) {
+	locationType, ok := OSMLocationType(element.Tags)
+	if !ok || element.Tags["name"] == "" {
+		return place, false
+	}
+	hours, err := POI.ParseOSMOpeningHours(element.Tags["opening_hours"])
+	if err != nil {
+		Logger.Debugf("OSM %s %d: %v", element.Type, element.ID, err)
+	}
+	placeURL := element.Tags["website"]
+	if placeURL == "" {
+		placeURL = osmPlaceURLPrefix + element.Type + "/" + strconv.FormatInt(element.ID, 10)
+	}
+	lat, lng := element.latLng()
+	place = POI.CreatePlace(element.Tags["name"], fmt.Sprintf("%f,%f", lat, lng), "", osmFormattedAddress(element.Tags),
+		string(POI.Operational), locationType, &POI.OpeningHours{Hours: hours[:]}, osmPlaceId(element.Type, element.ID),
+		0, OSMDefaultRating, placeURL, nil, osmUserRatingsTotal(element.Tags))
+	place.Address = POI.Address{
+		StreetAddr: strings.TrimSpace(element.Tags["addr:housenumber"] + " " + element.Tags["addr:street"]),
+		Locality:   element.Tags["addr:city"],
+		PostalCode: element.Tags["addr:postcode"],
+		Country:    element.Tags["addr:country"],
+	}
+	return place, true
+}
+
+// osmNotabilityTags are tags of places well known beyond OpenStreetMap
+var osmNotabilityTags = []string{"wikidata", "wikipedia", "website"}
+
+// osmUserRatingsTotal estimates the number of ratings of a place from its notability tags
+func osmUserRatingsTotal(tags map[string]string) int {
+	userRatingsTotal := osmBaseUserRatingsTotal
+	for _, tag := range osmNotabilityTags {
+		if tags[tag] != "" {
+			userRatingsTotal *= osmNotabilityRatingsFactor
+		}
+	}
+	return userRatingsTotal
+}
+
+func osmFormattedAddress(tags map[string]string) string {
+	parts := make([]string, 0)
+	for _, part := range []string{strings.TrimSpace(tags["addr:housenumber"] + " " + tags["addr:street"]),
+		strings.TrimSpace(tags["addr:city"] + " " + tags["addr:postcode"]), tags["addr:country"]} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func osmPlaceId(elementType string, id int64) string {
+	return osmPlaceIdPrefix + elementType + "-" + strconv.FormatInt(id, 10)
+}
+
+func parseOSMPlaceId(placeId string) (elementType string, id int64, err error) {
+	parts := strings.Split(strings.TrimPrefix(placeId, osmPlaceIdPrefix), "-")
+	if !strings.HasPrefix(placeId, osmPlaceIdPrefix) || len(parts) != 2 {
+		return "", 0, errors.New("not an OSM place ID: " + placeId)
+	}
+	id, err = strconv.ParseInt(parts[1], 10, 64)
+	return parts[0], id, err
+}
+
+// OSMSearchClient searches places of OpenStreetMap with an Overpass API endpoint,
+// or in an extract imported into Redis with ImportOSMExtract if the endpoint is empty
+type OSMSearchClient struct {
+	overpassURL string
+	httpClient  *http.Client
+	redisClient *RedisClient
+}
+
+// CreateOverpassSearchClient creates an OSMSearchClient with an Overpass API endpoint such as https://overpass-api.de/api/interpreter
+func CreateOverpassSearchClient(overpassURL string) *OSMSearchClient {
+	logErr(CreateLogger(), utils.LogError)
+	return &OSMSearchClient{overpassURL: overpassURL, httpClient: &http.Client{Timeout: OverpassSearchTimeout}}
+}
+
+// CreateOSMExtractSearchClient creates an OSMSearchClient searching extracts imported into Redis
+func CreateOSMExtractSearchClient(redisClient *RedisClient) *OSMSearchClient {
+	logErr(CreateLogger(), utils.LogError)
+	return &OSMSearchClient{redisClient: redisClient}
+}
+
+func (client *OSMSearchClient) GetGeocode(context context.Context, query *GeocodeQuery) (lat float64, lng float64, err error) {
+	if client.overpassURL == "" {
+		return client.redisClient.GetOSMGeocode(context, query)
+	}
+
+	countryPattern := overpassRegex(query.Country)
+	cityPattern := overpassRegex(query.City)
+	var overpassQuery strings.Builder
+	overpassQuery.WriteString(overpassQueryHeader + "(")
+	for _, countryKey := range []string{"name", "name:en", "ISO3166-1", "ISO3166-1:alpha3"} {
+		fmt.Fprintf(&overpassQuery, `area["admin_level"="2"]["%s"~"%s",i];`, countryKey, countryPattern)
+	}
+	overpassQuery.WriteString(")->.country;(")
+	for _, cityKey := range []string{"name", "name:en"} {
+		fmt.Fprintf(&overpassQuery, `node["place"~"^(city|town)$"]["%s"~"%s",i](area.country);`, cityKey, cityPattern)
+	}
+	overpassQuery.WriteString(");out 1;")
+
+	data, err := client.overpass(context, overpassQuery.String())
+	if err != nil {
+		return
+	}
+	if len(data.Elements) == 0 {
+		err = fmt.Errorf("no OSM city for location %s, %s", query.City, query.Country)
+		return
+	}
+	city := data.Elements[0]
+	// correct the city name like Google Maps
+	if name := city.Tags["name:en"]; name != "" {
+		query.City = name
+	} else if name = city.Tags["name"]; name != "" {
+		query.City = name
+	}
+	lat, lng = city.latLng()
+	return
+}
+
+// NearbySearch returns places of the category within the search radius of the location in the format of "lat,lng"
+func (client *OSMSearchClient) NearbySearch(context context.Context, request *PlaceSearchRequest) ([]POI.Place, error) {
+	if client.overpassURL == "" {
+		return client.redisClient.nearbySearch(context, osmPlacesKeyPrefix, request)
+	}
+
+	latLng, err := maps.ParseLatLng(request.Location)
+	if err != nil {
+		return nil, err
+	}
+	radius := request.Radius
+	if radius > OSMMaxSearchRadius {
+		radius = OSMMaxSearchRadius
+	}
+	locationTypes := make(map[POI.LocationType]bool)
+	for _, locationType := range POI.GetPlaceTypes(request.PlaceCat) {
+		locationTypes[locationType] = true
+	}
+	var overpassQuery strings.Builder
+	overpassQuery.WriteString(overpassQueryHeader + "(")
+	for _, tag := range osmTags {
+		if locationTypes[tag.locationType] {
+			fmt.Fprintf(&overpassQuery, `nwr["%s"="%s"]["name"](around:%d,%f,%f);`, tag.key, tag.value, radius, latLng.Lat, latLng.Lng)
+		}
+	}
+	fmt.Fprintf(&overpassQuery, ");out center %d;", osmMaxQueryResults)
+
+	data, err := client.overpass(context, overpassQuery.String())
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(data.Elements, func(i, j int) bool {
+		_, iWikidata := data.Elements[i].Tags["wikidata"]
+		_, jWikidata := data.Elements[j].Tags["wikidata"]
+		if iWikidata != jWikidata {
+			return iWikidata
+		}
+		return osmDistance(data.Elements[i], latLng) < osmDistance(data.Elements[j], latLng)
+	})
+
+	places := make([]POI.Place, 0)
+	for _, element := range data.Elements {
+		if len(places) == OSMMaxSearchResults {
+			break
+		}
+		if place, ok := element.ToPlace(); ok && POI.GetPlaceCategory(place.LocationType) == request.PlaceCat {
+			places = append(places, place)
+		}
+	}
+	return places, nil
+}
+
+func osmDistance(element OSMElement, latLng maps.LatLng) float64 {
+	lat, lng := element.latLng()
+	return utils.HaversineDist([]float64{lat, lng}, []float64{latLng.Lat, latLng.Lng})
+}
+
+func (client *OSMSearchClient) PlaceDetailsSearch(context context.Context, placeId string) (place POI.Place, err error) {
+	if client.overpassURL == "" {
+		return client.redisClient.getPlace(context, placeId)
+	}
+
+	elementType, id, err := parseOSMPlaceId(placeId)
+	if err != nil {
+		return
+	}
+	data, err := client.overpass(context, fmt.Sprintf("%s%s(%d);%s", overpassQueryHeader, elementType, id, overpassResultFooter))
+	if err != nil {
+		return
+	}
+	for _, element := range data.Elements {
+		if p, ok := element.ToPlace(); ok {
+			return p, nil
+		}
+	}
+	err = errors.New("no OSM place for place ID " + placeId)
+	return
+}
+
+// overpass sends a query in Overpass QL to the endpoint
+func (client *OSMSearchClient) overpass(context context.Context, query string) (data OSMData, err error) {
+	req, err := http.NewRequestWithContext(context, http.MethodPost, client.overpassURL, strings.NewReader(url.Values{"data": {query}}.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Vacation-planner")
+
+	startTime := time.Now()
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	requestId, _ := context.Value(RequestIdKey).(string)
+	Logger.Debugw("request:", requestId, "Logging Overpass query", "Overpass API call time", time.Since(startTime), "status", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("overpass API status %d: %.200s", resp.StatusCode, body)
+		return
+	}
+	err = json.Unmarshal(body, &data)
+	return
+}
+
+var overpassRegexSpecialChars = regexp.MustCompile(`[\\^$.|?*+()\[\]{}"]`)
+
+// overpassRegex matches a value exactly ignoring cases
+func overpassRegex(value string) string {
+	return "^" + overpassRegexSpecialChars.ReplaceAllString(strings.TrimSpace(value), ".") + "$"
+}
+
+// ImportOSMExtract imports places of an extract file in the JSON format of Overpass API into Redis for OSMSearchClient
+// the geocode of the location is the city or town node of the extract with the location name,
+// or the center of the places if the extract has no such node
+func ImportOSMExtract(context context.Context, redisClient *RedisClient, extractFile string, location GeocodeQuery) (numPlaces int, err error) {
+	fileData, err := ioutil.ReadFile(extractFile)
+	if err != nil {
+		return
+	}
+	data := OSMData{}
+	if err = json.Unmarshal(fileData, &data); err != nil {
+		return 0, fmt.Errorf("invalid OSM extract %s: %w", extractFile, err)
+	}
+
+	places := make([]POI.Place, 0)
+	var cityFound bool
+	var lat, lng, sumLat, sumLng float64
+	for _, element := range data.Elements {
+		if place, ok := element.ToPlace(); ok {
+			places = append(places, place)
+			sumLat, sumLng = sumLat+place.Location.Coordinates[1], sumLng+place.Location.Coordinates[0]
+			continue
+		}
+		isCity := element.Tags["place"] == "city" || element.Tags["place"] == "town"
+		if isCity && !cityFound && (strings.EqualFold(element.Tags["name"], location.City) || strings.EqualFold(element.Tags["name:en"], location.City)) {
+			lat, lng = element.latLng()
+			cityFound = true
+		}
+	}
+	if len(places) == 0 {
+		return 0, fmt.Errorf("no places in OSM extract %s", extractFile)
+	}
+	if !cityFound {
+		lat, lng = sumLat/float64(len(places)), sumLng/float64(len(places))
+	}
+
+	redisClient.setPlacesOnCategory(context, osmPlacesKeyPrefix, places)
+	if err = redisClient.SetOSMGeocode(context, location, lat, lng); err != nil {
+		return
+	}
+	return len(places), nil
+}
+
+func (redisClient *RedisClient) SetOSMGeocode(context context.Context, query GeocodeQuery, lat float64, lng float64) error {
+	redisField := strings.ToLower(strings.Join([]string{query.City, query.Country}, "_"))
+	redisVal := strings.Join([]string{fmt.Sprintf("%.6f", lat), fmt.Sprintf("%.6f", lng)}, ",")
+	return redisClient.client.HSet(context, osmGeocodeKey, redisField, redisVal).Err()
+}
+
+// GetOSMGeocode gets the geocode of a location of imported OSM extracts
+func (redisClient *RedisClient) GetOSMGeocode(context context.Context, query *GeocodeQuery) (lat float64, lng float64, err error) {
+	redisField := strings.ToLower(strings.Join([]string{query.City, query.Country}, "_"))
+	geocode, err := redisClient.client.HGet(context, osmGeocodeKey, redisField).Result()
+	if err != nil {
+		err = fmt.Errorf("no OSM extract for location %s, %s", query.City, query.Country)
+		return
+	}
+	latLng, err := utils.ParseLocation(geocode)
+	if err != nil {
+		return
+	}
+	return latLng[0], latLng[1], nil
+}
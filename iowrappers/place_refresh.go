@@ -0,0 +1,258 @@
+package iowrappers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+const (
+	DefaultPlaceRefreshBatchSize = 20
+	DefaultPlaceRefreshInterval  = 10 * time.Minute
+	DefaultPlaceRefreshMinAge    = 7 * 24 * time.Hour
+	// places whose refreshes fail with transient errors are refreshed again after this duration, or the minimum age if shorter
+	PlaceRefreshRetryBackoff = 24 * time.Hour
+
+	PlaceLastRefreshedKey   = "place_refresh:last_refreshed" // sorted set of place IDs scored by Unix time of last refresh
+	PlaceUsageKey           = "place_refresh:usage"          // sorted set of place IDs scored by the number of plans with the place
+	placeRefreshCallsPrefix = "place_refresh:calls:"         // number of place details calls of a UTC day
+	// candidates of a batch are the stalest places of this many times the batch size, ordered by usage
+	placeRefreshCandidateFactor = 4
+)
+
+// fields of places updated by refreshes
+var placeRefreshFields = []string{"business_status", "opening_hours", "rating", "user_ratings_total"}
+
+// PlaceRefreshConfig limits the place details calls refreshing places of all servers sharing Redis
+type PlaceRefreshConfig struct {
+	DailyQuota int           // place details calls of a UTC day, refreshes are disabled if not positive
+	BatchSize  int           // places refreshed by each run
+	Interval   time.Duration // time between runs
+	MinAge     time.Duration // places refreshed more recently are not refreshed
+}
+
+// PlaceRefreshStats counts places of a refresh run
+type PlaceRefreshStats struct {
+	Refreshed int `json:"refreshed"`
+	Closed    int `json:"closed"` // places that no longer exist and are marked as closed
+	Failed    int `json:"failed"`
+}
+
+// PlaceRefresher refreshes opening hours, ratings, review counts and business status of the stalest and most-used places
+// with Google Maps place details searches, and marks places that disappear as permanently closed
+type PlaceRefresher struct {
+	mapsClient  *MapsClient
+	redisClient *RedisClient
+	config      PlaceRefreshConfig
+}
+
+func CreatePlaceRefresher(mapsClient *MapsClient, redisClient *RedisClient, config PlaceRefreshConfig) *PlaceRefresher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultPlaceRefreshBatchSize
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultPlaceRefreshInterval
+	}
+	if config.MinAge <= 0 {
+		config.MinAge = DefaultPlaceRefreshMinAge
+	}
+	return &PlaceRefresher{mapsClient: mapsClient, redisClient: redisClient, config: config}
+}
+
+// Run refreshes a batch of places at every interval until the context is done
+func (refresher *PlaceRefresher) Run(context context.Context) {
+	if refresher.config.DailyQuota <= 0 {
+		return
+	}
+	if err := refresher.redisClient.BackfillPlaceRefreshTimes(context); err != nil {
+		Logger.Errorf("[place refresh] failed to backfill refresh times: %v", err)
+	}
+	ticker := time.NewTicker(refresher.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-context.Done():
+			return
+		case <-ticker.C:
+		}
+		stats, err := refresher.RefreshStalePlaces(context)
+		if err != nil {
+			Logger.Errorf("[place refresh] %v", err)
+		}
+		Logger.Infof("[place refresh] refreshed %d places, %d places closed, %d refreshes failed", stats.Refreshed, stats.Closed, stats.Failed)
+	}
+}
+
+// RefreshStalePlaces refreshes a batch of places not refreshed within the minimum age within the daily quota,
+// preferring places in more plans and then places refreshed earlier
+func (refresher *PlaceRefresher) RefreshStalePlaces(ctx context.Context) (stats PlaceRefreshStats, err error) {
+	ctx = context.WithValue(ctx, RequestIdKey, "place_refresh")
+	placeIds, err := refresher.redisClient.stalePlaces(ctx, time.Now().Add(-refresher.config.MinAge), refresher.config.BatchSize)
+	if err != nil {
+		return
+	}
+	for _, placeId := range placeIds {
+		var withinQuota bool
+		if withinQuota, err = refresher.redisClient.reservePlaceRefresh(ctx, refresher.config.DailyQuota); err != nil || !withinQuota {
+			return
+		}
+		if err = refresher.refreshPlace(ctx, placeId, &stats); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// refreshPlace returns an error only if refreshes should stop
+func (refresher *PlaceRefresher) refreshPlace(context context.Context, placeId string, stats *PlaceRefreshStats) error {
+	place, err := refresher.redisClient.getPlace(context, placeId)
+	if err != nil {
+		// the place is no longer cached
+		refresher.redisClient.client.ZRem(context, PlaceLastRefreshedKey, placeId)
+		return nil
+	}
+
+	details, err := PlaceDetailedSearch(context, refresher.mapsClient, placeId, placeRefreshFields)
+	switch {
+	case err == nil:
+		if details.OpeningHours != nil && len(details.OpeningHours.WeekdayText) == len(place.Hours) {
+			copy(place.Hours[:], details.OpeningHours.WeekdayText)
+		}
+		if details.BusinessStatus != "" {
+			place.SetStatus(details.BusinessStatus)
+		}
+		// details without ratings keep the cached ratings
+		if details.UserRatingsTotal > 0 {
+			place.SetRating(details.Rating)
+			place.SetUserRatingsTotal(details.UserRatingsTotal)
+		}
+		stats.Refreshed++
+	case strings.HasPrefix(err.Error(), "maps: NOT_FOUND"):
+		place.SetStatus(string(POI.ClosedPermanently))
+		stats.Closed++
+	case errors.Is(err, ErrMapsBudgetExceeded) || context.Err() != nil:
+		return err
+	case strings.HasPrefix(err.Error(), "maps: INVALID_REQUEST"):
+		// not a Google Maps place ID
+		refresher.redisClient.client.ZRem(context, PlaceLastRefreshedKey, placeId)
+		stats.Failed++
+		return nil
+	default:
+		// transient errors such as OVER_QUERY_LIMIT and timeouts back off the place so that it does not take the quota of every run
+		retryTime := time.Now()
+		if PlaceRefreshRetryBackoff < refresher.config.MinAge {
+			retryTime = retryTime.Add(PlaceRefreshRetryBackoff - refresher.config.MinAge)
+		}
+		refresher.redisClient.markPlaceRefreshed(context, placeId, retryTime)
+		stats.Failed++
+		return nil
+	}
+	// caching the place marks it as refreshed, and cached plans with the place are invalidated if the place changes
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	refresher.redisClient.setPlace(context, place, wg)
+	return nil
+}
+
+// GetPlaceLastRefreshed returns the time of the last refresh of the place
+func (redisClient *RedisClient) GetPlaceLastRefreshed(context context.Context, placeId string) (time.Time, error) {
+	timestamp, err := redisClient.client.ZScore(context, PlaceLastRefreshedKey, placeId).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(timestamp), 0), nil
+}
+
+// markPlaceRefreshed is called whenever the data of a place is cached, places of OpenStreetMap are refreshed by imports
+func (redisClient *RedisClient) markPlaceRefreshed(context context.Context, placeId string, refreshTime time.Time) {
+	if strings.HasPrefix(placeId, osmPlaceIdPrefix) {
+		return
+	}
+	redisClient.client.ZAdd(context, PlaceLastRefreshedKey, &redis.Z{Score: float64(refreshTime.Unix()), Member: placeId})
+}
+
+// BackfillPlaceRefreshTimes adds cached places without refresh times as the stalest places
+func (redisClient *RedisClient) BackfillPlaceRefreshTimes(context context.Context) error {
+	placeKeys, _, err := redisClient.GetPlaceCountInRedis(context)
+	if err != nil {
+		return err
+	}
+	pipeline := redisClient.client.Pipeline()
+	for _, placeKey := range placeKeys {
+		placeId := strings.TrimPrefix(placeKey, PlaceDetailsKeyPrefix+":place_ID:")
+		if !strings.HasPrefix(placeId, osmPlaceIdPrefix) {
+			pipeline.ZAddNX(context, PlaceLastRefreshedKey, &redis.Z{Score: 0, Member: placeId})
+		}
+	}
+	_, err = pipeline.Exec(context)
+	return err
+}
+
+// recordPlaceUsage counts the plans served with the places
+func (redisClient *RedisClient) recordPlaceUsage(context context.Context, placeIds []string) {
+	if len(placeIds) == 0 {
+		return
+	}
+	pipeline := redisClient.client.Pipeline()
+	for _, placeId := range placeIds {
+		pipeline.ZIncrBy(context, PlaceUsageKey, 1, placeId)
+	}
+	if _, err := pipeline.Exec(context); err != nil {
+		Logger.Errorf("failed to record usage of places: %v", err)
+	}
+}
+
+// stalePlaces returns up to count places refreshed before the time, the most used first
+func (redisClient *RedisClient) stalePlaces(context context.Context, refreshedBefore time.Time, count int) ([]string, error) {
+	candidates, err := redisClient.client.ZRangeByScoreWithScores(context, PlaceLastRefreshedKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(refreshedBefore.Unix(), 10),
+		Count: int64(count * placeRefreshCandidateFactor),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := redisClient.client.Pipeline()
+	usageCmds := make([]*redis.FloatCmd, len(candidates))
+	for idx, candidate := range candidates {
+		usageCmds[idx] = pipeline.ZScore(context, PlaceUsageKey, candidate.Member.(string))
+	}
+	if _, err = pipeline.Exec(context); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	usage := make(map[string]float64, len(candidates))
+	placeIds := make([]string, len(candidates))
+	for idx, candidate := range candidates {
+		placeIds[idx] = candidate.Member.(string)
+		usage[placeIds[idx]] = usageCmds[idx].Val()
+	}
+	// candidates are in the order of refresh times
+	sort.SliceStable(placeIds, func(i, j int) bool { return usage[placeIds[i]] > usage[placeIds[j]] })
+	if len(placeIds) > count {
+		placeIds = placeIds[:count]
+	}
+	return placeIds, nil
+}
+
+// reservePlaceRefresh counts a place details call of a refresh, and returns false once the daily quota is used up
+func (redisClient *RedisClient) reservePlaceRefresh(context context.Context, dailyQuota int) (bool, error) {
+	key := placeRefreshCallsPrefix + time.Now().UTC().Format(MapsQuotaDateLayout)
+	calls, err := redisClient.client.Incr(context, key).Result()
+	if err != nil {
+		return false, err
+	}
+	redisClient.client.Expire(context, key, MapsQuotaStatsRetention)
+	if calls > int64(dailyQuota) {
+		redisClient.client.Decr(context, key)
+		return false, nil
+	}
+	return true, nil
+}
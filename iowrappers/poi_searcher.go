@@ -15,13 +15,17 @@ import (
 const (
 	MaxSearchRadius              = 16000               // 10 miles
 	MinMapsResultRefreshDuration = time.Hour * 24 * 14 // 14 days
+	MapsPartialSearchBackoff     = time.Hour           // partial or failed searches of a location are not repeated within this duration
 	GoogleSearchHomePageURL      = "https://www.google.com/"
 	RequestIdKey                 = "request_id"
+	UsernameKey                  = "username"
 )
 
 type PoiSearcher struct {
-	mapsClient  MapsClient
-	redisClient RedisClient
+	searchClient SearchClient
+	redisClient  RedisClient
+	mapsMeter    *MapsMeter
+	searchGroup  *nearbySearchGroup
 }
 
 // can also be used as the result of reverse geocoding
@@ -33,15 +37,45 @@ type GeocodeQuery struct {
 var Logger *zap.SugaredLogger
 
 func CreatePoiSearcher(mapsApiKey string, redisUrl *url.URL) *PoiSearcher {
+	mapsClient := CreateMapsClient(mapsApiKey)
+	return CreatePoiSearcherWithSearchClient(&mapsClient, redisUrl)
+}
+
+// CreatePoiSearcherWithSearchClient creates a PoiSearcher searching places missing in Redis with the search client
+func CreatePoiSearcherWithSearchClient(searchClient SearchClient, redisUrl *url.URL) *PoiSearcher {
 	poiSearcher := PoiSearcher{
-		mapsClient:  CreateMapsClient(mapsApiKey),
-		redisClient: CreateRedisClient(redisUrl),
+		searchClient: searchClient,
+		redisClient:  CreateRedisClient(redisUrl),
+		searchGroup:  &nearbySearchGroup{},
 	}
 	return &poiSearcher
 }
 
+func (poiSearcher PoiSearcher) GetSearchClient() SearchClient {
+	return poiSearcher.searchClient
+}
+
+// GetMapsClient returns nil if places are not searched with Google Maps
 func (poiSearcher PoiSearcher) GetMapsClient() *MapsClient {
-	return &poiSearcher.mapsClient
+	switch searchClient := poiSearcher.searchClient.(type) {
+	case *MapsClient:
+		return searchClient
+	case *FallbackSearchClient:
+		return searchClient.GetMapsClient()
+	}
+	return nil
+}
+
+// SetMapsMeter meters calls of the Google Maps client, and places are only served from cache over the daily budget of the meter
+func (poiSearcher *PoiSearcher) SetMapsMeter(meter *MapsMeter) {
+	poiSearcher.mapsMeter = meter
+	if mapsClient := poiSearcher.GetMapsClient(); mapsClient != nil {
+		mapsClient.SetMeter(meter)
+	}
+}
+
+func (poiSearcher PoiSearcher) GetMapsMeter() *MapsMeter {
+	return poiSearcher.mapsMeter
 }
 
 func (poiSearcher PoiSearcher) GetRedisClient() *RedisClient {
@@ -60,7 +94,7 @@ func (poiSearcher PoiSearcher) GetGeocode(context context.Context, query *Geocod
 	var geocodeMissingErr error
 	lat, lng, geocodeMissingErr = poiSearcher.redisClient.GetGeocode(context, query)
 	if geocodeMissingErr != nil {
-		lat, lng, err = poiSearcher.mapsClient.GetGeocode(context, query)
+		lat, lng, err = poiSearcher.searchClient.GetGeocode(context, query)
 		if err != nil {
 			return
 		}
@@ -72,11 +106,16 @@ func (poiSearcher PoiSearcher) GetGeocode(context context.Context, query *Geocod
 	return
 }
 
+// NearbySearch returns places of the request from Redis if they are fresh and sufficient, and searches them with the search client otherwise
+// places of failed external searches are returned with the error, such as a PartialNearbySearchError, for callers to decide whether to use them
 func (poiSearcher PoiSearcher) NearbySearch(context context.Context, request *PlaceSearchRequest) ([]POI.Place, error) {
 	location := request.Location
 	cityAndCountry := strings.Split(location, ",")
 
 	places := make([]POI.Place, 0)
+	if len(cityAndCountry) != 2 {
+		return places, fmt.Errorf("location %s is not in the format of city,country", location)
+	}
 	lat, lng, err := poiSearcher.GetGeocode(context, &GeocodeQuery{
 		City:    cityAndCountry[0],
 		Country: cityAndCountry[1],
@@ -107,25 +146,30 @@ func (poiSearcher PoiSearcher) NearbySearch(context context.Context, request *Pl
 		return places, nil
 	}
 
-	cacheMiss = poiSearcher.redisClient.SetMapsLastSearchTime(context, location, request.PlaceCat, currentTime.Format(time.RFC3339))
-	utils.LogErrorWithLevel(cacheMiss, utils.LogError)
-
-	originalSearchRadius := request.Radius
-
-	request.Radius = MaxSearchRadius // use a large search radius whenever we call external maps services
-
-	// initiate a new external search
-	newPlaces, mapsNearbySearchErr := poiSearcher.mapsClient.NearbySearch(context, request)
-	utils.LogErrorWithLevel(mapsNearbySearchErr, utils.LogError)
+	// places in Redis are used until the backoff of a partial or failed search expires, which bounds the cost of failing searches
+	if poiSearcher.redisClient.InMapsSearchBackoff(context, location, request.PlaceCat) {
+		Logger.Infof("[%s] last search of %s failed, using %d places in Redis. Place Type: %s", context.Value(RequestIdKey), location, len(cachedPlaces), request.PlaceCat)
+		places = append(places, cachedPlaces...)
+		return places, nil
+	}
 
-	request.Radius = originalSearchRadius // restore search radius
+	// cache-only mode keeps the last search time so that the location is searched once the budget allows,
+	// and search clients falling back from Google Maps to other providers are still used
+	if _, isMapsClient := poiSearcher.searchClient.(*MapsClient); isMapsClient && poiSearcher.mapsMeter.CacheOnly(context) {
+		Logger.Infof("[%s] Google Maps budget exceeded, using %d places in Redis. Place Type: %s", context.Value(RequestIdKey), len(cachedPlaces), request.PlaceCat)
+		places = append(places, cachedPlaces...)
+		return places, nil
+	}
 
-	// update Redis with all the new places obtained
-	poiSearcher.UpdateRedis(context, newPlaces)
+	// initiate a new external search, or wait for the same search of other requests
+	newPlaces, externalSearchErr := poiSearcher.externalNearbySearch(context, location, request, currentTime)
+	utils.LogErrorWithLevel(externalSearchErr, utils.LogError)
 
-	// safe-guard on accessing elements in a nil slice
-	if len(newPlaces) > 0 {
-		places = append(places, newPlaces...)
+	// closed places are cached but not returned like places from Redis
+	for _, place := range newPlaces {
+		if !place.IsClosed() {
+			places = append(places, place)
+		}
 	}
 
 	if uint(len(places)) < request.MinNumResults {
@@ -137,7 +181,7 @@ func (poiSearcher PoiSearcher) NearbySearch(context context.Context, request *Pl
 			request.Location, request.Radius, request.PlaceCat)
 		Logger.Debug("location may be invalid")
 	}
-	return places, nil
+	return places, externalSearchErr
 }
 
 func (poiSearcher PoiSearcher) PlaceDetailsSearch(context context.Context, placeId string) (place POI.Place, err error) {
@@ -5,8 +5,9 @@ package iowrappers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	log "github.com/sirupsen/logrus"
@@ -23,6 +24,12 @@ const (
 	SlotSolutionExpirationTime = 24 * time.Hour
 	PlanningStatExpirationTime = 24 * time.Hour
 
+	// SlotSolutionCacheVersion is the version of slot solution and multi-day solution cache keys,
+	// keys of other versions are removed by RemoveOutdatedSlotSolutions
+	SlotSolutionCacheVersion  = "v2"
+	SlotSolutionKeyPrefix     = "slot_solution"
+	MultiDaySolutionKeyPrefix = "multi_day_solution"
+
 	NumVisitorsPlanningAPI = "visitor_count:planning_APIs"
 	NumVisitorsPrefix      = "visitor_count"
 )
@@ -81,14 +88,29 @@ func (redisClient *RedisClient) RemoveKeys(context context.Context, keys []strin
 }
 
 // serialize place using JSON and store in Redis with key place_details:place_ID:placeID
+// cached solutions with the place are invalidated if the place changes
 func (redisClient *RedisClient) setPlace(context context.Context, place POI.Place, wg *sync.WaitGroup) {
 	defer wg.Done()
 	json_, err := json.Marshal(place)
 	utils.LogErrorWithLevel(err, utils.LogError)
 
-	_, err = redisClient.client.Set(context, "place_details:place_ID:"+place.ID, json_, 0).Result()
+	redisKey := "place_details:place_ID:" + place.ID
+	cachedJson, cacheErr := redisClient.client.Get(context, redisKey).Result()
+	_, err = redisClient.client.Set(context, redisKey, json_, 0).Result()
 	if err != nil {
 		Logger.Error(err)
+		return
+	}
+	redisClient.markPlaceRefreshed(context, place.ID, time.Now())
+	// new places are not in any solution
+	var cachedPlace POI.Place
+	if cacheErr != nil || json.Unmarshal([]byte(cachedJson), &cachedPlace) != nil {
+		return
+	}
+	if reason := placeChangeInvalidationReason(cachedPlace, place); reason != "" {
+		if _, err = redisClient.InvalidatePlaceSolutions(context, reason, place.ID); err != nil {
+			Logger.Errorf("failed to invalidate solutions of place %s: %v", place.ID, err)
+		}
 	}
 }
 
@@ -120,6 +142,24 @@ func (redisClient *RedisClient) SetMapsLastSearchTime(context context.Context, l
 	return
 }
 
+// SetMapsSearchBackoff records a partial or failed search of the location,
+// which is not searched again with the search client until the backoff expires
+func (redisClient *RedisClient) SetMapsSearchBackoff(context context.Context, location string, category POI.PlaceCategory, backoff time.Duration) error {
+	return redisClient.client.Set(context, mapsSearchBackoffKey(location, category), time.Now().Format(time.RFC3339), backoff).Err()
+}
+
+// InMapsSearchBackoff returns true if a search of the location failed or returned partial results within its backoff
+func (redisClient *RedisClient) InMapsSearchBackoff(context context.Context, location string, category POI.PlaceCategory) bool {
+	exists, err := redisClient.client.Exists(context, mapsSearchBackoffKey(location, category)).Result()
+	return err == nil && exists == 1
+}
+
+func mapsSearchBackoffKey(location string, category POI.PlaceCategory) string {
+	cityCountry := strings.Split(location, ",")
+	city, country := cityCountry[0], cityCountry[len(cityCountry)-1]
+	return strings.ToLower(strings.Join([]string{"MapsSearchBackoff", country, city, string(category)}, ":"))
+}
+
 // currently not used, but it is still a primitive implementation that might have faster search time compared
 // with all places stored under one key
 // store places obtained from database or external API in Redis
@@ -144,6 +184,11 @@ func (redisClient *RedisClient) StorePlacesForLocation(context context.Context,
 }
 
 func (redisClient *RedisClient) SetPlacesOnCategory(context context.Context, places []POI.Place) {
+	redisClient.setPlacesOnCategory(context, "placeIDs:", places)
+}
+
+// setPlacesOnCategory adds places to the geo index of their categories with the key prefix and stores place details
+func (redisClient *RedisClient) setPlacesOnCategory(context context.Context, keyPrefix string, places []POI.Place) {
 	wg := &sync.WaitGroup{}
 	wg.Add(len(places))
 	for _, place := range places {
@@ -153,7 +198,7 @@ func (redisClient *RedisClient) SetPlacesOnCategory(context context.Context, pla
 			Longitude: place.Location.Coordinates[0],
 			Latitude:  place.Location.Coordinates[1],
 		}
-		redisKey := "placeIDs:" + strings.ToLower(string(placeCategory))
+		redisKey := keyPrefix + strings.ToLower(string(placeCategory))
 		_, cmdErr := redisClient.client.GeoAdd(context, redisKey, geolocation).Result()
 
 		utils.LogErrorWithLevel(cmdErr, utils.LogError)
@@ -203,8 +248,13 @@ func (redisClient *RedisClient) NearbySearchNotUsed(context context.Context, req
 }
 
 func (redisClient *RedisClient) NearbySearch(context context.Context, request *PlaceSearchRequest) (places []POI.Place, err error) {
+	return redisClient.nearbySearch(context, "placeIDs:", request)
+}
+
+// nearbySearch finds places in the geo index of the request category with the key prefix
+func (redisClient *RedisClient) nearbySearch(context context.Context, keyPrefix string, request *PlaceSearchRequest) (places []POI.Place, err error) {
 	requestCategory := strings.ToLower(string(request.PlaceCat))
-	redisKey := "placeIDs:" + requestCategory
+	redisKey := keyPrefix + requestCategory
 
 	latLng, _ := utils.ParseLocation(request.Location)
 	requestLat, requestLng := latLng[0], latLng[1]
@@ -230,10 +280,11 @@ func (redisClient *RedisClient) NearbySearch(context context.Context, request *P
 
 	request.Radius = searchRadius
 
+	// closed places are kept in the cache to detect status changes, but are not candidates of plans
 	places = make([]POI.Place, 0)
 	for _, placeInfo := range cachedQualifiedPlaces {
 		place, err := redisClient.getPlace(context, placeInfo.Name)
-		if err == nil {
+		if err == nil && !place.IsClosed() {
 			places = append(places, place)
 		}
 	}
@@ -326,12 +377,25 @@ func (redisClient *RedisClient) StreamsLogging(streamName string, data map[strin
 }
 
 type SlotSolutionCandidateCache struct {
-	PlaceIds       []string     `json:"place_ids"`
-	Score          float64      `json:"score"`
-	PlaceNames     []string     `json:"place_names"`
-	PlaceLocations [][2]float64 `json:"place_locations"`
-	PlaceAddresses []string     `json:"place_addresses"`
-	PlaceURLs      []string     `json:"place_urls"`
+	PlaceIds       []string            `json:"place_ids"`
+	Score          float64             `json:"score"`
+	PlaceNames     []string            `json:"place_names"`
+	PlaceLocations [][2]float64        `json:"place_locations"`
+	PlaceAddresses []string            `json:"place_addresses"`
+	PlaceURLs      []string            `json:"place_urls"`
+	PlaceTypes     []POI.LocationType  `json:"place_types"`
+	ScoreBreakdown ScoreBreakdownCache `json:"score_breakdown"`
+}
+
+// ScoreBreakdownCache has the same fields as matching.ScoreBreakdown
+type ScoreBreakdownCache struct {
+	Rating      float64 `json:"rating"`
+	ReviewCount float64 `json:"review_count"`
+	Price       float64 `json:"price"`
+	Quality     float64 `json:"quality"`
+	Distance    float64 `json:"distance"`
+	Diversity   float64 `json:"diversity"`
+	Total       float64 `json:"total"`
 }
 
 type SlotSolutionCacheResponse struct {
@@ -339,63 +403,88 @@ type SlotSolutionCacheResponse struct {
 	Err                   error
 }
 
+// SlotSolutionCacheRequest consists of every field of a planning request that changes its solutions
 type SlotSolutionCacheRequest struct {
-	Country   string
-	City      string
-	Radius    uint64
-	EVTags    []string
-	Intervals []POI.TimeInterval
-	Weekday   POI.Weekday
-}
-
-// convert time intervals and an EV tag to an integer
-// each time interval and E/V pair has 23 * 24 * 2 = 1104 possibilities
-// treat each pair as one digit in 1104-ary number and we have maximum 4 digits
-func encodeTimeCatIdx(eVTag []string, intervals []POI.TimeInterval) (res int64, err error) {
-	if len(eVTag) != len(intervals) {
-		err = errors.New("wrong inputs")
-		res = -1
-		return
-	}
-	for idx, tagVal := range eVTag {
-		res *= 1104
-		interval := intervals[idx]
-		if strings.ToLower(tagVal) == "e" {
-			res += int64(interval.Start) * int64(interval.End)
-		} else if strings.ToLower(tagVal) == "v" {
-			res += int64(interval.Start) * int64(interval.End) * 2
-		} else {
-			err = errors.New("wrong input EV tag")
-			res = -1
-			return
-		}
-	}
-	return
+	Country          string
+	City             string
+	Radius           uint64
+	EVTags           []string
+	Intervals        []POI.TimeInterval
+	Weekday          POI.Weekday
+	OptimizedRoute   bool
+	PlaceConstraints string // serialized must-include and must-exclude places, empty without constraints
+	ScoreWeights     string // serialized score weights, empty for the default weights
+	Diversity        string // diversity of the selected plans, empty without diversification
+	ScorerVersion    string // version of the scores of plans, solutions of older scorers are not reused
+	TravelMode       string // travel mode of transit between places, empty if plans are not scheduled with transit
 }
 
-func genSlotSolutionCacheKey(req SlotSolutionCacheRequest) string {
-	country, city := req.Country, req.City
-	timeCatIdx, err := encodeTimeCatIdx(req.EVTags, req.Intervals)
-	utils.LogErrorWithLevel(err, utils.LogError)
+// slotSolutionCacheKeyRequest is the normalized slot solution cache request hashed into cache keys
+// a new field must have the omitempty option, so that requests without it keep their keys and cached solutions,
+// otherwise SlotSolutionCacheVersion must be bumped since the keys of all requests change
+type slotSolutionCacheKeyRequest struct {
+	Country          string             `json:"country"`
+	City             string             `json:"city"`
+	Radius           uint64             `json:"radius"`
+	EVTags           []string           `json:"ev_tags"`
+	Intervals        []POI.TimeInterval `json:"intervals"`
+	Weekday          POI.Weekday        `json:"weekday"`
+	OptimizedRoute   bool               `json:"optimized_route"`
+	PlaceConstraints string             `json:"place_constraints"`
+	ScoreWeights     string             `json:"score_weights"`
+	Diversity        string             `json:"diversity"`
+	ScorerVersion    string             `json:"scorer_version"`
+	TravelMode       string             `json:"travel_mode,omitempty"`
+}
 
-	radius := strconv.FormatUint(req.Radius, 10)
-	timeCatIdxStr := strconv.FormatInt(timeCatIdx, 10)
+// SlotSolutionCacheKey returns the versioned key of a slot solution cache request, "slot_solution:v2:country:city:hash",
+// where the hash is the SHA-256 digest of the normalized request, so keys of different requests never collide
+// locations and EV tags are case-insensitive, and place constraints are case-sensitive
+func SlotSolutionCacheKey(req SlotSolutionCacheRequest) string {
+	country, city := strings.ToLower(strings.TrimSpace(req.Country)), strings.ToLower(strings.TrimSpace(req.City))
+	keyRequest := slotSolutionCacheKeyRequest{
+		Country:          country,
+		City:             city,
+		Radius:           req.Radius,
+		EVTags:           make([]string, len(req.EVTags)),
+		Intervals:        make([]POI.TimeInterval, len(req.Intervals)),
+		Weekday:          req.Weekday,
+		OptimizedRoute:   req.OptimizedRoute,
+		PlaceConstraints: req.PlaceConstraints,
+		ScoreWeights:     req.ScoreWeights,
+		Diversity:        req.Diversity,
+		ScorerVersion:    req.ScorerVersion,
+		TravelMode:       req.TravelMode,
+	}
+	for idx, evTag := range req.EVTags {
+		keyRequest.EVTags[idx] = strings.ToLower(evTag)
+	}
+	copy(keyRequest.Intervals, req.Intervals)
 
-	redisFieldKey := strings.ToLower(strings.Join([]string{"slot_solution", country, city, radius, string(req.Weekday), timeCatIdxStr}, ":"))
-	return redisFieldKey
+	json_, err := json.Marshal(keyRequest)
+	utils.LogErrorWithLevel(err, utils.LogError)
+	digest := sha256.Sum256(json_)
+	return strings.Join([]string{SlotSolutionKeyPrefix, SlotSolutionCacheVersion, country, city, hex.EncodeToString(digest[:])}, ":")
 }
 
 // cache iowrapper level version of slot solution
 func (redisClient *RedisClient) CacheSlotSolution(context context.Context, req SlotSolutionCacheRequest, solution SlotSolutionCacheResponse) {
-	redisKey := genSlotSolutionCacheKey(req)
+	redisKey := SlotSolutionCacheKey(req)
 	json_, err := json.Marshal(solution)
 	utils.LogErrorWithLevel(err, utils.LogError)
 
 	if err != nil {
 		Logger.Errorf("cache slot solution failure for request with key: %s", redisKey)
-	} else {
-		redisClient.client.Set(context, redisKey, json_, SlotSolutionExpirationTime)
+		return
+	}
+	redisClient.client.Set(context, redisKey, json_, SlotSolutionExpirationTime)
+
+	placeIds := make([]string, 0)
+	for _, candidate := range solution.SlotSolutionCandidate {
+		placeIds = append(placeIds, candidate.PlaceIds...)
 	}
+	redisClient.indexSolutionPlaces(context, redisKey, placeIds)
+	redisClient.recordPlaceUsage(context, placeIds)
 }
 
 func (redisClient *RedisClient) GetSlotSolution(context context.Context, redisKey string, cacheResponses []SlotSolutionCacheResponse, wg *sync.WaitGroup, idx int) {
@@ -414,6 +503,11 @@ func (redisClient *RedisClient) GetSlotSolution(context context.Context, redisKe
 		cacheResponses[idx].Err = err
 		return
 	}
+	placeIds := make([]string, 0)
+	for _, candidate := range cacheResponses[idx].SlotSolutionCandidate {
+		placeIds = append(placeIds, candidate.PlaceIds...)
+	}
+	redisClient.recordPlaceUsage(context, placeIds)
 }
 
 func (redisClient *RedisClient) GetMultiSlotSolutions(context context.Context, requests []SlotSolutionCacheRequest) (responses []SlotSolutionCacheResponse) {
@@ -423,9 +517,137 @@ func (redisClient *RedisClient) GetMultiSlotSolutions(context context.Context, r
 	responses = make([]SlotSolutionCacheResponse, len(requests))
 
 	for idx, request := range requests {
-		redisKey := genSlotSolutionCacheKey(request)
+		redisKey := SlotSolutionCacheKey(request)
 		go redisClient.GetSlotSolution(context, redisKey, responses, &wg, idx)
 	}
 	wg.Wait()
 	return
 }
+
+// SlotPlacesCacheRequest identifies the places of a category open during a time slot of a day
+type SlotPlacesCacheRequest struct {
+	Country  string
+	City     string
+	Radius   uint64
+	Weekday  POI.Weekday
+	Interval POI.TimeInterval
+	Category POI.PlaceCategory
+}
+
+func genSlotPlacesCacheKey(req SlotPlacesCacheRequest) string {
+	keyParts := []string{"slot_places", req.Country, req.City, strconv.FormatUint(req.Radius, 10),
+		strconv.Itoa(int(req.Weekday)), req.Interval.Serialize(), string(req.Category)}
+	return strings.ToLower(strings.Join(keyParts, ":"))
+}
+
+// CacheSlotPlaces stores the IDs of places open during a time slot, details of the places are stored by place ID
+func (redisClient *RedisClient) CacheSlotPlaces(context context.Context, req SlotPlacesCacheRequest, placeIds []string) {
+	redisKey := genSlotPlacesCacheKey(req)
+	json_, err := json.Marshal(placeIds)
+	if err != nil {
+		Logger.Errorf("cache slot places failure for request with key: %s", redisKey)
+		return
+	}
+	redisClient.client.Set(context, redisKey, json_, SlotSolutionExpirationTime)
+}
+
+// GetSlotPlaces returns places open during a time slot in the cached order, places without cached details are skipped
+func (redisClient *RedisClient) GetSlotPlaces(context context.Context, req SlotPlacesCacheRequest) (places []POI.Place, err error) {
+	redisKey := genSlotPlacesCacheKey(req)
+	json_, err := redisClient.client.Get(context, redisKey).Result()
+	if err != nil {
+		Logger.Debugf("[%s] redis server find no result for key: %s", context.Value(RequestIdKey), redisKey)
+		return
+	}
+
+	var placeIds []string
+	if err = json.Unmarshal([]byte(json_), &placeIds); err != nil {
+		Logger.Error(err)
+		return
+	}
+	places = make([]POI.Place, 0, len(placeIds))
+	for _, placeId := range placeIds {
+		if place, placeErr := redisClient.getPlace(context, placeId); placeErr == nil {
+			places = append(places, place)
+		}
+	}
+	return
+}
+
+type TripSolutionCache struct {
+	Days  []SlotSolutionCandidateCache `json:"days"`
+	Score float64                      `json:"score"`
+}
+
+type MultiDaySolutionCacheResponse struct {
+	Trips []TripSolutionCache `json:"trips"`
+	Err   error
+}
+
+// MultiDaySolutionCacheRequest consists of the slot solution cache request of every day in a trip
+// and the number of trips requested, which decides the number of solutions searched for each day
+type MultiDaySolutionCacheRequest struct {
+	Days     []SlotSolutionCacheRequest
+	NumPlans int64
+}
+
+// a trip is cached as a unit with a key derived from the slot solution keys of all its days
+func genMultiDaySolutionCacheKey(req MultiDaySolutionCacheRequest) string {
+	dayKeys := make([]string, len(req.Days))
+	for idx, dayReq := range req.Days {
+		dayKeys[idx] = SlotSolutionCacheKey(dayReq)
+	}
+	numDays := strconv.FormatInt(int64(len(req.Days)), 10)
+	numPlans := strconv.FormatInt(req.NumPlans, 10)
+	digest := sha256.Sum256([]byte(strings.Join(dayKeys, "#")))
+	return strings.Join([]string{MultiDaySolutionKeyPrefix, SlotSolutionCacheVersion, numDays, numPlans, hex.EncodeToString(digest[:])}, ":")
+}
+
+func (redisClient *RedisClient) CacheMultiDaySolution(context context.Context, req MultiDaySolutionCacheRequest, solution MultiDaySolutionCacheResponse) {
+	redisKey := genMultiDaySolutionCacheKey(req)
+	json_, err := json.Marshal(solution)
+	utils.LogErrorWithLevel(err, utils.LogError)
+
+	if err != nil {
+		Logger.Errorf("cache multi-day solution failure for request with key: %s", redisKey)
+		return
+	}
+	redisClient.client.Set(context, redisKey, json_, SlotSolutionExpirationTime)
+
+	placeIds := make([]string, 0)
+	for _, trip := range solution.Trips {
+		for _, day := range trip.Days {
+			placeIds = append(placeIds, day.PlaceIds...)
+		}
+	}
+	redisClient.indexSolutionPlaces(context, redisKey, placeIds)
+	redisClient.recordPlaceUsage(context, placeIds)
+}
+
+func (redisClient *RedisClient) GetMultiDaySolution(context context.Context, req MultiDaySolutionCacheRequest) (response MultiDaySolutionCacheResponse) {
+	redisKey := genMultiDaySolutionCacheKey(req)
+	json_, err := redisClient.client.Get(context, redisKey).Result()
+	if err != nil {
+		Logger.Debugf("[%s] redis server find no result for key: %s", context.Value(RequestIdKey), redisKey)
+		response.Err = err
+		return
+	}
+
+	if err = json.Unmarshal([]byte(json_), &response); err != nil {
+		Logger.Error(err)
+		response.Err = err
+		return
+	}
+	placeIds := make([]string, 0)
+	for _, trip := range response.Trips {
+		for _, day := range trip.Days {
+			placeIds = append(placeIds, day.PlaceIds...)
+		}
+	}
+	redisClient.recordPlaceUsage(context, placeIds)
+	return
+}
+
+func (redisClient *RedisClient) RemoveMultiDaySolution(context context.Context, req MultiDaySolutionCacheRequest) {
+	redisClient.RemoveKeys(context, []string{genMultiDaySolutionCacheKey(req)})
+}
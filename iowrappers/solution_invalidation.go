@@ -0,0 +1,132 @@
+package iowrappers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
+
+// reasons of invalidating cached solutions
+const (
+	InvalidationReasonPlaceUpdate = "place_update" // places in solutions changed
+	InvalidationReasonPlaceClosed = "place_closed" // business status of places changed to CLOSED_*
+	InvalidationReasonAdmin       = "admin"        // admins invalidated solutions of places
+)
+
+const (
+	// PlaceSolutionsKeyPrefix is the prefix of the sets of slot solution and multi-day solution keys using a place
+	PlaceSolutionsKeyPrefix      = "place_solutions:"
+	solutionInvalidationStatsKey = "stats:solution_invalidations"
+)
+
+// SolutionInvalidationStats counts places whose solutions were invalidated and solutions removed for a reason
+type SolutionInvalidationStats struct {
+	Places    int64 `json:"places"`
+	Solutions int64 `json:"solutions"`
+}
+
+// indexSolutionPlaces adds the solution key to the sets of solution keys of places
+// the sets expire with the solutions, and keys of expired solutions in the sets are ignored by invalidation
+func (redisClient *RedisClient) indexSolutionPlaces(context context.Context, solutionKey string, placeIds []string) {
+	pipeline := redisClient.client.Pipeline()
+	indexed := make(map[string]bool)
+	for _, placeId := range placeIds {
+		if placeId == "" || indexed[placeId] {
+			continue
+		}
+		indexed[placeId] = true
+		pipeline.SAdd(context, PlaceSolutionsKeyPrefix+placeId, solutionKey)
+		pipeline.Expire(context, PlaceSolutionsKeyPrefix+placeId, SlotSolutionExpirationTime)
+	}
+	if len(indexed) == 0 {
+		return
+	}
+	if _, err := pipeline.Exec(context); err != nil {
+		Logger.Errorf("failed to index places of solution %s: %v", solutionKey, err)
+	}
+}
+
+// InvalidatePlaceSolutions removes cached slot solutions and multi-day solutions with any of the places,
+// and returns the number of solutions removed
+func (redisClient *RedisClient) InvalidatePlaceSolutions(context context.Context, reason string, placeIds ...string) (invalidatedCount int, err error) {
+	if len(placeIds) == 0 {
+		return
+	}
+	indexKeys := make([]string, len(placeIds))
+	for idx, placeId := range placeIds {
+		indexKeys[idx] = PlaceSolutionsKeyPrefix + placeId
+	}
+	solutionKeys, err := redisClient.client.SUnion(context, indexKeys...).Result()
+	if err != nil {
+		return
+	}
+
+	var removed *redis.IntCmd
+	_, err = redisClient.client.TxPipelined(context, func(pipeliner redis.Pipeliner) error {
+		if len(solutionKeys) > 0 {
+			removed = pipeliner.Del(context, solutionKeys...)
+		}
+		pipeliner.Del(context, indexKeys...)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if removed != nil {
+		invalidatedCount = int(removed.Val())
+	}
+
+	pipeline := redisClient.client.Pipeline()
+	pipeline.HIncrBy(context, solutionInvalidationStatsKey, reason+":places", int64(len(placeIds)))
+	pipeline.HIncrBy(context, solutionInvalidationStatsKey, reason+":solutions", int64(invalidatedCount))
+	if _, statsErr := pipeline.Exec(context); statsErr != nil {
+		Logger.Errorf("failed to count solution invalidations: %v", statsErr)
+	}
+	Logger.Debugf("[%s] invalidated %d solutions of %d places, reason: %s", context.Value(RequestIdKey), invalidatedCount, len(placeIds), reason)
+	return
+}
+
+// GetSolutionInvalidationStats returns the invalidation stats of each reason since the stats were created
+func (redisClient *RedisClient) GetSolutionInvalidationStats(context context.Context) (map[string]SolutionInvalidationStats, error) {
+	counts, err := redisClient.client.HGetAll(context, solutionInvalidationStatsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]SolutionInvalidationStats)
+	for field, count := range counts {
+		separatorIdx := strings.LastIndex(field, ":")
+		if separatorIdx < 0 {
+			continue
+		}
+		reason := field[:separatorIdx]
+		value, _ := strconv.ParseInt(count, 10, 64)
+		reasonStats := stats[reason]
+		switch field[separatorIdx+1:] {
+		case "places":
+			reasonStats.Places = value
+		case "solutions":
+			reasonStats.Solutions = value
+		}
+		stats[reason] = reasonStats
+	}
+	return stats, nil
+}
+
+// placeChangeInvalidationReason returns the reason of invalidating solutions with the cached place updated to the place,
+// or an empty string if plans with the place do not change. Ratings and review counts change often and only change scores slightly,
+// and photo references of the same photo differ between searches.
+func placeChangeInvalidationReason(cachedPlace POI.Place, place POI.Place) string {
+	if place.IsClosed() && place.Status != cachedPlace.Status {
+		return InvalidationReasonPlaceClosed
+	}
+	if cachedPlace.Name != place.Name || cachedPlace.Status != place.Status || cachedPlace.LocationType != place.LocationType ||
+		cachedPlace.Address != place.Address || cachedPlace.FormattedAddress != place.FormattedAddress ||
+		cachedPlace.Location.Coordinates != place.Location.Coordinates || cachedPlace.PriceLevel != place.PriceLevel ||
+		cachedPlace.Hours != place.Hours || cachedPlace.URL != place.URL {
+		return InvalidationReasonPlaceUpdate
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package iowrappers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+type TravelMode string
+
+const (
+	TravelModeWalking = TravelMode("walking")
+	TravelModeDriving = TravelMode("driving")
+	TravelModeTransit = TravelMode("transit")
+)
+
+// TravelEstimate is the estimated route between two places
+type TravelEstimate struct {
+	Mode             TravelMode `json:"mode"`
+	DistanceInMeters float64    `json:"distance_meters"`
+	Minutes          int        `json:"minutes"`
+}
+
+// TravelTimeProvider estimates travel between two locations in the [lng, lat] order of POI.Location
+// a distance matrix service can implement this interface to replace speed profiles
+type TravelTimeProvider interface {
+	EstimateTravel(context context.Context, origin [2]float64, destination [2]float64, mode TravelMode) (TravelEstimate, error)
+}
+
+// SpeedProfile describes how fast a travel mode covers the straight-line distance between places
+type SpeedProfile struct {
+	SpeedKmPerHour  float64
+	DetourFactor    float64 // ratio of route distance to straight-line distance
+	OverheadMinutes float64 // time spent on waiting for transit or parking
+}
+
+var DefaultSpeedProfiles = map[TravelMode]SpeedProfile{
+	TravelModeWalking: {SpeedKmPerHour: 4.8, DetourFactor: 1.3},
+	TravelModeDriving: {SpeedKmPerHour: 30, DetourFactor: 1.4, OverheadMinutes: 5},
+	TravelModeTransit: {SpeedKmPerHour: 18, DetourFactor: 1.3, OverheadMinutes: 10},
+}
+
+// SpeedProfileTravelTimeProvider estimates travel with Haversine distances and speed profiles of travel modes
+type SpeedProfileTravelTimeProvider struct {
+	Profiles map[TravelMode]SpeedProfile
+}
+
+func CreateSpeedProfileTravelTimeProvider() *SpeedProfileTravelTimeProvider {
+	return &SpeedProfileTravelTimeProvider{Profiles: DefaultSpeedProfiles}
+}
+
+func (provider *SpeedProfileTravelTimeProvider) EstimateTravel(context context.Context, origin [2]float64, destination [2]float64, mode TravelMode) (estimate TravelEstimate, err error) {
+	profile, exists := provider.Profiles[mode]
+	if !exists || profile.SpeedKmPerHour <= 0 {
+		err = fmt.Errorf("travel mode %s is not supported", mode)
+		return
+	}
+	estimate.Mode = mode
+	if origin == destination {
+		return
+	}
+	straightLineDistance := utils.HaversineDist([]float64{origin[1], origin[0]}, []float64{destination[1], destination[0]})
+	estimate.DistanceInMeters = math.Round(straightLineDistance * profile.DetourFactor)
+	estimate.Minutes = int(math.Ceil(profile.OverheadMinutes + estimate.DistanceInMeters/1000/profile.SpeedKmPerHour*60))
+	return
+}
@@ -26,9 +26,19 @@ func (redisClient *RedisClient) FindUser(context context.Context, username strin
 	usr.Email = u["email"]
 	usr.UserLevel = u["user_level"]
 	usr.Password = u["password"]
+	usr.ScoreWeights = u["score_weights"]
 	return usr, nil
 }
 
+// update the weights for scoring plans of an existing user
+func (redisClient *RedisClient) SetUserScoreWeights(context context.Context, username string, scoreWeights string) error {
+	redisKey := strings.Join([]string{UserKeyPrefix, username}, ":")
+	if redisClient.client.Exists(context, redisKey).Val() == 0 {
+		return errors.New("user does not exist")
+	}
+	return redisClient.client.HSet(context, redisKey, "score_weights", scoreWeights).Err()
+}
+
 // create a new user
 func (redisClient *RedisClient) CreateUser(context context.Context, usr user.User) error {
 	redisKey := strings.Join([]string{UserKeyPrefix, usr.Username}, ":")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/braintree/manners"
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"time"
 )
 
 const numWorkers = 5
@@ -22,7 +24,7 @@ type Config struct {
 		RedisUrl        string `envconfig:"REDISCLOUD_URL" required:"true"`
 		RedisStreamName string `default:"stream:planning_api_usage"`
 	}
-	MapsClientApiKey string `required:"true" split_words:"true"`
+	MapsClientApiKey string `split_words:"true"` // required by the google_maps search client
 }
 
 type Configurations struct {
@@ -30,6 +32,32 @@ type Configurations struct {
 		GoogleMaps struct {
 			DetailedSearchFields []string `yaml:"detailed_search_fields"`
 		} `yaml:"google_maps"`
+		SearchClient struct {
+			Provider          string   `yaml:"provider"`
+			FallbackProviders []string `yaml:"fallback_providers"`
+			FixturesFile      string   `yaml:"fixtures_file"`
+			MapsRecording     struct {
+				Mode        string `yaml:"mode"`
+				CassetteDir string `yaml:"cassette_dir"`
+			} `yaml:"maps_recording"`
+			OSM struct {
+				OverpassURL    string `yaml:"overpass_url"`
+				ExtractFile    string `yaml:"extract_file"`
+				ExtractCity    string `yaml:"extract_city"`
+				ExtractCountry string `yaml:"extract_country"`
+			} `yaml:"osm"`
+		} `yaml:"search_client"`
+		MapsQuota struct {
+			RateLimit   float64 `yaml:"rate_limit"`
+			Burst       int     `yaml:"burst"`
+			DailyBudget float64 `yaml:"daily_budget"`
+		} `yaml:"maps_quota"`
+		PlaceRefresh struct {
+			DailyQuota int           `yaml:"daily_quota"`
+			BatchSize  int           `yaml:"batch_size"`
+			Interval   time.Duration `yaml:"interval"`
+			MinAge     time.Duration `yaml:"min_age"`
+		} `yaml:"place_refresh"`
 	} `yaml:"server"`
 }
 
@@ -37,6 +65,22 @@ type Configurations struct {
 func flattenConfig(configs *Configurations) map[string]interface{} {
 	flattenedConfigs := make(map[string]interface{})
 	flattenedConfigs["server:google_maps:detailed_search_fields"] = configs.Server.GoogleMaps.DetailedSearchFields
+	flattenedConfigs["server:search_client:provider"] = configs.Server.SearchClient.Provider
+	flattenedConfigs["server:search_client:fallback_providers"] = configs.Server.SearchClient.FallbackProviders
+	flattenedConfigs["server:search_client:fixtures_file"] = configs.Server.SearchClient.FixturesFile
+	flattenedConfigs["server:search_client:maps_recording_mode"] = configs.Server.SearchClient.MapsRecording.Mode
+	flattenedConfigs["server:search_client:cassette_dir"] = configs.Server.SearchClient.MapsRecording.CassetteDir
+	flattenedConfigs["server:search_client:osm:overpass_url"] = configs.Server.SearchClient.OSM.OverpassURL
+	flattenedConfigs["server:search_client:osm:extract_file"] = configs.Server.SearchClient.OSM.ExtractFile
+	flattenedConfigs["server:search_client:osm:extract_city"] = configs.Server.SearchClient.OSM.ExtractCity
+	flattenedConfigs["server:search_client:osm:extract_country"] = configs.Server.SearchClient.OSM.ExtractCountry
+	flattenedConfigs["server:maps_quota:rate_limit"] = configs.Server.MapsQuota.RateLimit
+	flattenedConfigs["server:maps_quota:burst"] = configs.Server.MapsQuota.Burst
+	flattenedConfigs["server:maps_quota:daily_budget"] = configs.Server.MapsQuota.DailyBudget
+	flattenedConfigs["server:place_refresh:daily_quota"] = configs.Server.PlaceRefresh.DailyQuota
+	flattenedConfigs["server:place_refresh:batch_size"] = configs.Server.PlaceRefresh.BatchSize
+	flattenedConfigs["server:place_refresh:interval"] = configs.Server.PlaceRefresh.Interval
+	flattenedConfigs["server:place_refresh:min_age"] = configs.Server.PlaceRefresh.MinAge
 	return flattenedConfigs
 }
 
@@ -89,19 +133,22 @@ func main() {
 
 func listenForShutDownServer(ch <-chan os.Signal, svr *manners.GracefulServer, myPlanner *planner.MyPlanner) {
 	wg := &sync.WaitGroup{}
-	wg.Add(numWorkers)
+	wg.Add(numWorkers + 1)
 	// dispatch workers
 	for worker := 0; worker < numWorkers; worker++ {
 		go myPlanner.ProcessPlanningEvent(worker, wg)
 	}
+	refreshCtx, stopRefreshing := context.WithCancel(context.Background())
+	go myPlanner.RefreshPlaces(refreshCtx, wg)
 
 	// block and wait for shut-down signal
 	<-ch
 
 	// destroy zap logger
 	defer myPlanner.Destroy()
-	// close worker channels
+	// close worker channels and stop refreshing places
 	close(myPlanner.PlanningEvents)
+	stopRefreshing()
 	wg.Wait()
 
 	svr.Close()
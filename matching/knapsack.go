@@ -30,17 +30,26 @@ func (recordTable *knapsackRecordTable) Init(timeLimit uint8, budget uint) {
 	recordTable.SavedRecord[recordTable.getKey(0, 0)] = start
 }
 
+// costs range from 0 to budget, so each time spent has budget+1 keys
 func (recordTable *knapsackRecordTable) getKey(timeLimit uint8, budget uint) (key uint) {
-	key = uint(timeLimit)*recordTable.budget + budget
+	key = uint(timeLimit)*(recordTable.budget+1) + budget
 	return
 }
 
 func (recordTable *knapsackRecordTable) getTimeLimitAndCost(key uint) (timeLimit uint8, budget uint) {
-	budget = key % recordTable.budget
-	timeLimit = uint8((key - budget) / recordTable.budget)
+	budget = key % (recordTable.budget + 1)
+	timeLimit = uint8((key - budget) / (recordTable.budget + 1))
 	return
 }
 
+// placeCost rounds up the price of a place, places without price level are assumed to be free
+func placeCost(place Place) uint {
+	if place.Price <= 0 {
+		return 0
+	}
+	return uint(math.Ceil(place.Price))
+}
+
 func (recordTable *knapsackRecordTable) update() {
 	for key, record := range recordTable.NewRecord {
 		if oldRecord, ok := recordTable.SavedRecord[key]; ok {
@@ -59,6 +68,14 @@ func (recordTable *knapsackRecordTable) update() {
 	KnapsackV1 v1 is migrated to knapsack_old_test_only.go
 */
 func Knapsack(places []Place, interval QueryTimeInterval, budget uint) (results []Place, totalCost uint, totalTimeSpent uint8) {
+	return KnapsackWithObjective(places, interval, budget, Score)
+}
+
+// Objective evaluates places visited in order, and knapsack maximizes the objective
+type Objective func(places []Place) float64
+
+// KnapsackWithObjective selects places in the time interval within the budget which maximize the objective
+func KnapsackWithObjective(places []Place, interval QueryTimeInterval, budget uint, objective Objective) (results []Place, totalCost uint, totalTimeSpent uint8) {
 	//Initialize knapsack data structures
 	var recordTable knapsackRecordTable
 	timeLimit := interval.EndHour - interval.StartHour
@@ -74,13 +91,13 @@ func Knapsack(places []Place, interval QueryTimeInterval, budget uint) (results
 			currentTimeSpent, curCost := rt.getTimeLimitAndCost(key)
 			currentQueryStartTime, _ := interval.AddOffsetHours(currentTimeSpent)
 			newTimeSpent := currentTimeSpent + uint8(stayTime)
-			newCost := curCost + uint(math.Ceil(place.Price))
+			newCost := curCost + placeCost(place)
 			if newTimeSpent <= rt.timeLimit && newCost <= budget && place.IsOpenBetween(currentQueryStartTime, uint8(stayTime)) {
 				newKey := rt.getKey(newTimeSpent, newCost)
 				newSolution := make([]Place, len(record.Solution))
 				copy(newSolution, record.Solution)
 				newSolution = append(newSolution, place)
-				newScore := Score(newSolution)
+				newScore := objective(newSolution)
 				newRecord := knapsackNodeRecord{newTimeSpent, newCost, newScore, newSolution}
 				if alreadyRecord, ok := rt.NewRecord[newKey]; ok {
 					if alreadyRecord.score < newRecord.score {
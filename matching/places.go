@@ -1,13 +1,16 @@
 package matching
 
-import "github.com/weihesdlegend/Vacation-planner/POI"
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+)
 
 type Place struct {
-	Place    *POI.Place
-	Category POI.PlaceCategory `json:"category"`
-	Address  string            `json:"address"`
-	Price    float64           `json:"price"`
-	Location [2]float64        `json:"geolocation"`
+	Place        *POI.Place
+	openingHours *POI.WeeklyOpeningHours
+	Category     POI.PlaceCategory `json:"category"`
+	Address      string            `json:"address"`
+	Price        float64           `json:"price"`
+	Location     [2]float64        `json:"geolocation"`
 }
 
 func (place Place) GetHours() [7]string {
@@ -58,11 +61,25 @@ func (place Place) SetURL(url string) {
 	place.Place.SetURL(url)
 }
 
+// GetOpeningHours returns opening hours of the place parsed with minute precision
+func (place Place) GetOpeningHours() *POI.WeeklyOpeningHours {
+	if place.openingHours != nil {
+		return place.openingHours
+	}
+	openingHours, _ := place.Place.GetOpeningHours()
+	return &openingHours
+}
+
+// IsOpenBetween checks if the place is open for the entire stay starting from interval.StartHour
+// and the stay ends no later than interval.EndHour
+// places without opening hours data for the day are assumed to be open
 func (place Place) IsOpenBetween(interval QueryTimeInterval, stayingDurationInHour uint8) bool {
-	//TODO: Query whither this place is open at this period in the future after POI.PLACE contains open hour.
-	//Dummy implementation, only checks if the time period is valid
+	if interval.StartHour+stayingDurationInHour > interval.EndHour {
+		return false
+	}
 
-	return interval.StartHour+stayingDurationInHour <= interval.EndHour
+	stay := POI.TimeInterval{Start: POI.Hour(interval.StartHour), End: POI.Hour(interval.StartHour + stayingDurationInHour)}
+	return place.GetOpeningHours().IsOpenDuring(interval.Day, stay.ToMinuteInterval())
 }
 
 func CreatePlace(place POI.Place, category POI.PlaceCategory) Place {
@@ -72,5 +89,7 @@ func CreatePlace(place POI.Place, category POI.PlaceCategory) Place {
 	Place_.Price = Pricing(place.GetPriceLevel())
 	Place_.Location = place.GetLocation()
 	Place_.Category = category
+	// opening hours are parsed once since they are checked many times during matching
+	Place_.openingHours = Place_.GetOpeningHours()
 	return Place_
 }
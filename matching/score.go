@@ -4,26 +4,41 @@ package matching
 import (
 	"github.com/weihesdlegend/Vacation-planner/utils"
 	"gonum.org/v1/gonum/floats"
-	"gonum.org/v1/gonum/stat"
 	"math"
 )
 
 const (
 	AvgRating  = 3.0
 	AvgPricing = PriceLevel2
+	// score deducted for each kilometer between consecutive places by TotalScore
+	DistancePenaltyPerKm = 0.05
 )
 
+// Score is the score of places with the default weights
 func Score(places []Place) float64 {
-	if len(places) == 1 {
-		return singlePlaceScore(places[0])
-	}
-	distances := calDistances(places)                     // Haversine distances
-	maxDist := math.Max(0.001, calMaxDistance(distances)) // protect against maximum distance being zero
-	avgDistance := stat.Mean(distances, nil) / maxDist    // normalized average distance
+	return DefaultScorer.Score(places)
+}
 
-	avgScore := avgPlacesScore(places)
+// TotalScore adds up scores of places and deducts a penalty for the distance between consecutive places
+// unlike Score, it grows with the number of places, which lets the knapsack decide how many places to visit
+func TotalScore(places []Place) float64 {
+	var totalScore float64
+	for _, place := range places {
+		if place.GetPrice() < 0 { // places without price level are treated as free places
+			place.Price = 0
+		}
+		totalScore += singlePlaceScore(place)
+	}
+	if len(places) > 1 {
+		totalScore -= floats.Sum(calDistances(places)) / 1000 * DistancePenaltyPerKm
+	}
+	return totalScore
+}
 
-	return avgScore - avgDistance
+// SinglePlaceScore is the score of a place visited alone
+// Score of multiple places never exceeds the average of their single place scores
+func SinglePlaceScore(place Place) float64 {
+	return DefaultScorer.PlaceScore(place)
 }
 
 func singlePlaceScore(place Place) float64 {
@@ -51,13 +66,3 @@ func calDistances(places []Place) []float64 {
 func calMaxDistance(distances []float64) float64 {
 	return floats.Max(distances)
 }
-
-// calculate normalized average rating to price ratio
-func avgPlacesScore(places []Place) float64 {
-	numPlaces := len(places)
-	placeScores := make([]float64, numPlaces)
-	for k, place := range places {
-		placeScores[k] = singlePlaceScore(place)
-	}
-	return stat.Mean(placeScores, nil)
-}
@@ -0,0 +1,147 @@
+package matching
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	MaxScoreWeight = 5.0
+	// ScorerVersion is bumped whenever scores of plans change, so that cached plans of older scorers are not reused
+	ScorerVersion = "1"
+	// distances are normalized by the maximum distance between consecutive places, which is at least this many meters
+	minNormalizingDistance = 0.001
+)
+
+// ScoreWeights tune how much each factor counts in scores of plans
+// rating, review count and price weights are exponents of the factors multiplied into place scores,
+// and distance and diversity weights scale the plan-level penalty and bonus.
+type ScoreWeights struct {
+	Rating      float64 `json:"rating"`
+	ReviewCount float64 `json:"review_count"`
+	Price       float64 `json:"price"`
+	Distance    float64 `json:"distance"`
+	Diversity   float64 `json:"diversity"`
+}
+
+// DefaultScoreWeights give the score in the score design doc
+var DefaultScoreWeights = ScoreWeights{Rating: 1, ReviewCount: 1, Price: 1, Distance: 1}
+
+func (weights ScoreWeights) Validate() error {
+	values := []float64{weights.Rating, weights.ReviewCount, weights.Price, weights.Distance, weights.Diversity}
+	for idx, name := range []string{"rating", "review_count", "price", "distance", "diversity"} {
+		if math.IsNaN(values[idx]) || values[idx] < 0 || values[idx] > MaxScoreWeight {
+			return fmt.Errorf("%s weight must be between 0 and %.0f", name, MaxScoreWeight)
+		}
+	}
+	return nil
+}
+
+// Serialize returns an empty string for the default weights
+func (weights ScoreWeights) Serialize() string {
+	if weights == DefaultScoreWeights {
+		return ""
+	}
+	values := []float64{weights.Rating, weights.ReviewCount, weights.Price, weights.Distance, weights.Diversity}
+	parts := make([]string, len(values))
+	for idx, value := range values {
+		parts[idx] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return strings.Join(parts, "_")
+}
+
+// ScoreBreakdown explains the score of a plan
+type ScoreBreakdown struct {
+	Rating      float64 `json:"rating"`       // average rating factor of places
+	ReviewCount float64 `json:"review_count"` // average review count factor of places
+	Price       float64 `json:"price"`        // average price factor of places, higher for cheaper places
+	Quality     float64 `json:"quality"`      // average place score, the product of the factors of each place
+	Distance    float64 `json:"distance"`     // penalty for the distance between consecutive places
+	Diversity   float64 `json:"diversity"`    // bonus for visiting places of different location types
+	Total       float64 `json:"total"`        // quality - distance + diversity
+}
+
+// Scorer scores plans of places visited in order
+type Scorer interface {
+	// PlaceScore is the score of a place visited alone
+	PlaceScore(place Place) float64
+	Score(places []Place) float64
+	Breakdown(places []Place) ScoreBreakdown
+	// UpperBound is the maximum score of plans of numPlaces places with the average place score,
+	// apart tells if any two consecutive places are at least minNormalizingDistance apart
+	UpperBound(averagePlaceScore float64, numPlaces int, apart bool) float64
+}
+
+// WeightedScorer multiplies weighted rating, review count and price factors into place scores,
+// and adds the weighted distance penalty and diversity bonus to the average place score of plans
+type WeightedScorer struct {
+	Weights ScoreWeights
+}
+
+var DefaultScorer Scorer = CreateWeightedScorer(DefaultScoreWeights)
+
+func CreateWeightedScorer(weights ScoreWeights) *WeightedScorer {
+	return &WeightedScorer{Weights: weights}
+}
+
+// factors of a place, places without price are scored with the average rating to price ratio
+func (scorer *WeightedScorer) factors(place Place) (rating float64, reviewCount float64, price float64) {
+	reviewCount = math.Pow(math.Log10(float64(1+place.GetUserRatingsCount())), scorer.Weights.ReviewCount)
+	if place.GetPrice() == 0 {
+		return math.Pow(AvgRating, scorer.Weights.Rating), reviewCount, math.Pow(AvgPricing, scorer.Weights.Price)
+	}
+	return math.Pow(float64(place.GetRating()), scorer.Weights.Rating), reviewCount, math.Pow(place.GetPrice(), scorer.Weights.Price)
+}
+
+func (scorer *WeightedScorer) PlaceScore(place Place) float64 {
+	rating, reviewCount, price := scorer.factors(place)
+	return reviewCount * (rating / price)
+}
+
+func (scorer *WeightedScorer) Score(places []Place) float64 {
+	return scorer.Breakdown(places).Total
+}
+
+func (scorer *WeightedScorer) Breakdown(places []Place) (breakdown ScoreBreakdown) {
+	if len(places) == 0 {
+		return
+	}
+	placeScores := make([]float64, len(places))
+	locationTypes := make(map[string]bool)
+	for idx, place := range places {
+		rating, reviewCount, price := scorer.factors(place)
+		breakdown.Rating += rating / float64(len(places))
+		breakdown.ReviewCount += reviewCount / float64(len(places))
+		breakdown.Price += 1 / price / float64(len(places))
+		placeScores[idx] = reviewCount * (rating / price)
+		locationTypes[string(place.GetPlaceType())] = true
+	}
+	breakdown.Quality = stat.Mean(placeScores, nil)
+	breakdown.Total = breakdown.Quality
+	if len(places) == 1 {
+		return
+	}
+
+	distances := calDistances(places) // Haversine distances
+	maxDist := math.Max(minNormalizingDistance, calMaxDistance(distances))
+	breakdown.Distance = scorer.Weights.Distance * (stat.Mean(distances, nil) / maxDist)
+	breakdown.Diversity = scorer.Weights.Diversity * float64(len(locationTypes)-1) / float64(len(places)-1)
+	breakdown.Total = breakdown.Quality - breakdown.Distance + breakdown.Diversity
+	return
+}
+
+// UpperBound uses the normalized average distance being at least 1/(n-1) for n places once two consecutive places are apart
+func (scorer *WeightedScorer) UpperBound(averagePlaceScore float64, numPlaces int, apart bool) float64 {
+	if numPlaces <= 1 {
+		return averagePlaceScore
+	}
+	bound := averagePlaceScore + scorer.Weights.Diversity
+	if apart {
+		bound -= scorer.Weights.Distance / float64(numPlaces-1)
+	}
+	return bound
+}
@@ -15,10 +15,9 @@ type Matcher interface {
 	Matching(context context.Context, req *TimeMatchingRequest) (clusters []TimePlacesCluster)
 }
 
+// TimeMatcher is safe for concurrent use, clusters of places are created for each request
 type TimeMatcher struct {
 	PoiSearcher *iowrappers.PoiSearcher
-	CateringMgr *graph.TimeClustersManager
-	TouringMgr  *graph.TimeClustersManager
 }
 
 type TimeSlot struct {
@@ -45,7 +44,7 @@ type TimePlacesCluster struct {
 
 func (interval *QueryTimeInterval) AddOffsetHours(offsethour uint8) (intervalOut QueryTimeInterval, valid bool) {
 	//If a stay time after the start time exceeds the end of day, return false
-	if intervalOut.StartHour+offsethour > interval.EndHour {
+	if interval.StartHour+offsethour > interval.EndHour {
 		valid = false
 		return
 	}
@@ -61,19 +60,18 @@ func (matcher *TimeMatcher) Init(poiSearcher *iowrappers.PoiSearcher) {
 		log.Fatal("PoiSearcher does not exist")
 	}
 	matcher.PoiSearcher = poiSearcher
-	matcher.CateringMgr = &graph.TimeClustersManager{PlaceCat: POI.PlaceCategoryEatery}
-	matcher.TouringMgr = &graph.TimeClustersManager{PlaceCat: POI.PlaceCategoryVisit}
 }
 
 func (matcher *TimeMatcher) Matching(context context.Context, req *TimeMatchingRequest) (clusters []TimePlacesCluster) {
 	// Place search and time clustering
-	matcher.placeSearch(context, req, POI.PlaceCategoryEatery) // search catering
-	matcher.placeSearch(context, req, POI.PlaceCategoryVisit)  // search visit locations
+	// cluster managers are local to the request so that concurrent requests do not share clusters
+	cateringMgr := matcher.placeSearch(context, req, POI.PlaceCategoryEatery) // search catering
+	touringMgr := matcher.placeSearch(context, req, POI.PlaceCategoryVisit)   // search visit locations
 
 	clusterMap := make(map[string]*TimePlacesCluster)
 
-	matcher.timeClustering(POI.PlaceCategoryEatery, clusterMap)
-	matcher.timeClustering(POI.PlaceCategoryVisit, clusterMap)
+	timeClustering(cateringMgr, clusterMap)
+	timeClustering(touringMgr, clusterMap)
 
 	clusters = make([]TimePlacesCluster, len(clusterMap))
 	timeIntervals := make([]POI.TimeInterval, 0)
@@ -91,18 +89,7 @@ func (matcher *TimeMatcher) Matching(context context.Context, req *TimeMatchingR
 	return
 }
 
-func (matcher *TimeMatcher) timeClustering(placeCat POI.PlaceCategory, clusterMap map[string]*TimePlacesCluster) {
-	var mgr *graph.TimeClustersManager
-
-	switch placeCat {
-	case POI.PlaceCategoryVisit:
-		mgr = matcher.TouringMgr
-	case POI.PlaceCategoryEatery:
-		mgr = matcher.CateringMgr
-	default:
-		mgr = &graph.TimeClustersManager{PlaceCat: POI.PlaceCategoryVisit}
-	}
-
+func timeClustering(mgr *graph.TimeClustersManager, clusterMap map[string]*TimePlacesCluster) {
 	for _, timeInterval := range *mgr.TimeClusters.TimeIntervals.GetAllIntervals() {
 		clusterKey := timeInterval.Serialize()
 		if _, exist := clusterMap[clusterKey]; !exist {
@@ -110,20 +97,16 @@ func (matcher *TimeMatcher) timeClustering(placeCat POI.PlaceCategory, clusterMa
 		}
 		cluster := mgr.TimeClusters.Clusters[clusterKey]
 		for _, place := range cluster.Places {
-			(*clusterMap[clusterKey]).Places = append((*clusterMap[clusterKey]).Places, CreatePlace(place, placeCat))
+			(*clusterMap[clusterKey]).Places = append((*clusterMap[clusterKey]).Places, CreatePlace(place, mgr.PlaceCat))
 		}
 	}
 }
-func (matcher *TimeMatcher) placeSearch(context context.Context, req *TimeMatchingRequest, placeCat POI.PlaceCategory) {
-	var mgr *graph.TimeClustersManager
-	switch placeCat {
-	case POI.PlaceCategoryVisit:
-		mgr = matcher.TouringMgr
-	case POI.PlaceCategoryEatery:
-		mgr = matcher.CateringMgr
-	default:
-		mgr = &graph.TimeClustersManager{PlaceCat: POI.PlaceCategoryVisit}
+
+func (matcher *TimeMatcher) placeSearch(context context.Context, req *TimeMatchingRequest, placeCat POI.PlaceCategory) *graph.TimeClustersManager {
+	if placeCat != POI.PlaceCategoryEatery {
+		placeCat = POI.PlaceCategoryVisit
 	}
+	mgr := &graph.TimeClustersManager{PlaceCat: placeCat}
 
 	intervals := make([]POI.TimeInterval, 0)
 	for _, slot := range req.TimeSlots {
@@ -134,4 +117,5 @@ func (matcher *TimeMatcher) placeSearch(context context.Context, req *TimeMatchi
 	mgr.Init(matcher.PoiSearcher, placeCat, intervals, req.Weekday)
 	mgr.PlaceSearch(context, req.Location, req.Radius)
 	mgr.Clustering(req.Weekday)
+	return mgr
 }
@@ -3,6 +3,7 @@ package planner
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
@@ -15,9 +16,11 @@ import (
 	"github.com/weihesdlegend/Vacation-planner/utils"
 	"html/template"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,9 +28,18 @@ import (
 )
 
 const (
-	MaxPlacesPerDay    = 12
-	ServerTimeout      = time.Second * 15
-	jobQueueBufferSize = 1000
+	TripDateLayout      = "2006-01-02"
+	MaxPlacesPerDay     = 12
+	MaxRouteStops       = 25
+	MaxSlotAlternatives = 50
+	ServerTimeout       = time.Second * 15
+	jobQueueBufferSize  = 1000
+)
+
+// planning modes of the POST planning API
+const (
+	PlanningModeSlots  = "slots"  // fill fixed time slots with places of given categories
+	PlanningModeBudget = "budget" // choose places fitting in a time window and a daily budget
 )
 
 type MyPlanner struct {
@@ -38,18 +50,30 @@ type MyPlanner struct {
 	PlanningEvents     chan iowrappers.PlanningEvent
 	Environment        string
 	Configs            map[string]interface{}
+	PlaceRefresher     *iowrappers.PlaceRefresher
+	Migrations         *iowrappers.MigrationRegistry
+	TemplatesDir       string // "templates" of the working directory by default
 }
 
 type TimeSectionPlace struct {
-	PlaceName string   `json:"place_name"`
-	StartTime POI.Hour `json:"start_time"`
-	EndTime   POI.Hour `json:"end_time"`
-	Address   string   `json:"address"`
-	URL       string   `json:"url"`
+	PlaceId    string                     `json:"place_id"`
+	PlaceName  string                     `json:"place_name"`
+	Category   POI.PlaceCategory          `json:"category"`
+	StartTime  POI.Hour                   `json:"start_time"`
+	EndTime    POI.Hour                   `json:"end_time"`
+	Address    string                     `json:"address"`
+	URL        string                     `json:"url"`
+	VisitStart string                     `json:"visit_start,omitempty"` // HH:MM, after the transit from the previous place
+	VisitEnd   string                     `json:"visit_end,omitempty"`   // HH:MM
+	Transit    *iowrappers.TravelEstimate `json:"transit,omitempty"`     // from the previous place
 }
 
 type TimeSectionPlaces struct {
-	Places []TimeSectionPlace `json:"places"`
+	Places         []TimeSectionPlace       `json:"places"`
+	TotalCost      uint                     `json:"total_cost,omitempty"`      // budget mode only
+	TotalTime      POI.Hour                 `json:"total_time,omitempty"`      // budget mode only, in hours
+	Score          float64                  `json:"score"`                     // total score of places in the budget mode
+	ScoreBreakdown *matching.ScoreBreakdown `json:"score_breakdown,omitempty"` // slots mode only, why the plan is ranked where it is
 }
 
 type PlanningResponse struct {
@@ -59,6 +83,43 @@ type PlanningResponse struct {
 	StatusCode        uint                `json:"status_code"`
 }
 
+// TripDay is the itinerary of one day in a multi-day trip
+type TripDay struct {
+	Date    string             `json:"date"`
+	Weekday POI.Weekday        `json:"weekday"`
+	Places  []TimeSectionPlace `json:"places"`
+}
+
+type TripPlan struct {
+	Days  []TripDay `json:"days"`
+	Score float64   `json:"score"`
+}
+
+// TripLegPlan is the itinerary of the days spent in one city of a multi-city trip
+type TripLegPlan struct {
+	City    string    `json:"city"`
+	Country string    `json:"country"`
+	Days    []TripDay `json:"days"`
+}
+
+type MultiCityTripPlan struct {
+	Legs  []TripLegPlan `json:"legs"`
+	Score float64       `json:"score"`
+}
+
+type MultiCityPlanningResponse struct {
+	Trips      []MultiCityTripPlan `json:"trips"`
+	Err        error               `json:"error"`
+	StatusCode uint                `json:"status_code"`
+}
+
+type MultiDayPlanningResponse struct {
+	TravelDestination string     `json:"travel_destination"`
+	Trips             []TripPlan `json:"trips"`
+	Err               error      `json:"error"`
+	StatusCode        uint       `json:"status_code"`
+}
+
 // validate REST API input
 func validateSearchRadius(searchRadius string) bool {
 	searchRadiusPattern := "^[1-9][0-9]{2,5}$" // limit range to 100 -- 99999
@@ -68,14 +129,98 @@ func validateSearchRadius(searchRadius string) bool {
 	return true
 }
 
+// SlotTemplate describes a time slot of a day and the category of place to visit in it
+type SlotTemplate struct {
+	Start    POI.Hour          `json:"start"`
+	End      POI.Hour          `json:"end"`
+	Category POI.PlaceCategory `json:"category"`
+}
+
+type MultiDayPlanningPostRequest struct {
+	Country   string           `json:"country"`
+	City      string           `json:"city"`
+	Radius    uint             `json:"radius"`
+	StartDate string           `json:"start_date"` // YYYY-MM-DD
+	NumDays   int              `json:"num_days"`
+	NumPlans  int64            `json:"num_plans"`
+	DaySlots  [][]SlotTemplate `json:"day_slots"` // optional, day i uses DaySlots[i % len(DaySlots)]
+}
+
+type TripLegPostRequest struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+	Radius  uint   `json:"radius"`
+	NumDays int    `json:"num_days"`
+}
+
+type MultiCityPlanningPostRequest struct {
+	Legs      []TripLegPostRequest `json:"legs"`
+	StartDate string               `json:"start_date"` // YYYY-MM-DD
+	NumPlans  int64                `json:"num_plans"`
+	DaySlots  [][]SlotTemplate     `json:"day_slots"` // optional, day i of the whole trip uses DaySlots[i % len(DaySlots)]
+}
+
 type PlanningPostRequest struct {
-	Country   string      `json:"country"`
-	City      string      `json:"city"`
-	Weekday   POI.Weekday `json:"weekday"`
-	StartTime POI.Hour    `json:"start_time"`
-	EndTime   POI.Hour    `json:"end_time"`
-	NumVisit  uint        `json:"num_visit"`
-	NumEatery uint        `json:"num_eatery"`
+	Country               string                   `json:"country"`
+	City                  string                   `json:"city"`
+	Radius                uint                     `json:"radius"`
+	Weekday               POI.Weekday              `json:"weekday"`
+	NumPlans              int64                    `json:"num_plans"`
+	StartTime             POI.Hour                 `json:"start_time"`
+	EndTime               POI.Hour                 `json:"end_time"`
+	NumVisit              uint                     `json:"num_visit"`
+	NumEatery             uint                     `json:"num_eatery"`
+	Slots                 []SlotTemplate           `json:"slots"`                   // optional, overrides start/end time and number of places if provided
+	Mode                  string                   `json:"mode"`                    // optional, "slots" by default or "budget"
+	Budget                uint                     `json:"budget"`                  // daily budget of the budget mode
+	TravelMode            string                   `json:"travel_mode"`             // optional, "walking" by default, "driving" or "transit"
+	OptimizeRoute         bool                     `json:"optimize_route"`          // optional, reorder places for a shorter route
+	PinnedPlaces          []PinnedPlacePostRequest `json:"pinned_places"`           // optional, places that must be in every plan
+	ExcludedPlaceIds      []string                 `json:"excluded_place_ids"`      // optional, places that cannot be in any plan
+	ExcludedLocationTypes []POI.LocationType       `json:"excluded_location_types"` // optional, e.g. "museum"
+	ScoreWeights          *matching.ScoreWeights   `json:"score_weights"`           // optional, weights of the user profile or the default weights if absent
+	Diversity             float64                  `json:"diversity"`               // optional, in [0, 1], 0 by default for the top plans
+}
+
+// PinnedPlacePostRequest is a place that must be in every plan
+type PinnedPlacePostRequest struct {
+	PlaceId string `json:"place_id"`
+	Slot    *int   `json:"slot"` // optional, index of the slot starting from 0, any slot if absent
+}
+
+// SlotAlternativesPostRequest asks for places replacing the place of a slot in a plan from the planning APIs
+type SlotAlternativesPostRequest struct {
+	Country         string                 `json:"country"`
+	City            string                 `json:"city"`
+	Radius          uint                   `json:"radius"`
+	Weekday         POI.Weekday            `json:"weekday"`
+	Slots           []SlotTemplate         `json:"slots"`            // slots of the plan
+	PlaceIds        []string               `json:"place_ids"`        // place of each slot of the plan
+	Slot            int                    `json:"slot"`             // index of the slot to replace starting from 0
+	NumAlternatives int                    `json:"num_alternatives"` // optional, 10 by default
+	ScoreWeights    *matching.ScoreWeights `json:"score_weights"`    // optional, weights of the user profile or the default weights if absent
+}
+
+type SlotAlternativesResponse struct {
+	Slot         int                        `json:"slot"`
+	Alternatives []solution.SlotAlternative `json:"alternatives"`
+}
+
+// RouteStop is a place to visit in the route optimization API
+type RouteStop struct {
+	Name     string            `json:"name"`
+	Lat      float64           `json:"lat"`
+	Lng      float64           `json:"lng"`
+	Category POI.PlaceCategory `json:"category"` // optional, eateries keep their positions
+}
+
+type RouteOptimizationRequest struct {
+	Stops []RouteStop `json:"stops"`
+}
+
+type RouteOptimizationResponse struct {
+	Stops         []RouteStop `json:"stops"`
+	TotalDistance float64     `json:"total_distance_meters"`
 }
 
 func (planner *MyPlanner) Init(mapsClientApiKey string, redisURL *url.URL, redisStreamName string, configs map[string]interface{}) {
@@ -86,15 +231,138 @@ func (planner *MyPlanner) Init(mapsClientApiKey string, redisURL *url.URL, redis
 		planner.RedisStreamName = "stream:planning_api_usage"
 	}
 
-	PoiSearcher := iowrappers.CreatePoiSearcher(mapsClientApiKey, redisURL)
+	searchClient, err := createSearchClient(mapsClientApiKey, &planner.RedisClient, configs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	PoiSearcher := iowrappers.CreatePoiSearcherWithSearchClient(searchClient, redisURL)
+	PoiSearcher.SetMapsMeter(iowrappers.CreateMapsMeter(&planner.RedisClient, mapsQuotaConfig(configs)))
 
 	planner.Solver.Init(PoiSearcher)
 
-	planner.ResultHTMLTemplate = template.Must(template.ParseFiles("templates/plan_layout.html"))
+	planner.TemplatesDir, _ = configs["server:templates_dir"].(string)
+	if planner.TemplatesDir == "" {
+		planner.TemplatesDir = "templates"
+	}
+	planner.ResultHTMLTemplate = template.Must(template.ParseFiles(filepath.Join(planner.TemplatesDir, "plan_layout.html")))
 	planner.Environment = strings.ToLower(os.Getenv("ENVIRONMENT"))
 	planner.Configs = configs
 	if v, exists := planner.Configs["server:google_maps:detailed_search_fields"]; exists {
-		planner.Solver.Matcher.PoiSearcher.GetMapsClient().SetDetailedSearchFields(v.([]string))
+		if mapsClient := planner.Solver.Matcher.PoiSearcher.GetMapsClient(); mapsClient != nil {
+			mapsClient.SetDetailedSearchFields(v.([]string))
+		}
+	}
+	if mapsClient := planner.Solver.Matcher.PoiSearcher.GetMapsClient(); mapsClient != nil {
+		planner.PlaceRefresher = iowrappers.CreatePlaceRefresher(mapsClient, &planner.RedisClient, placeRefreshConfig(configs))
+	}
+	planner.Migrations = createMigrationRegistry(&planner.RedisClient, planner.Solver.Matcher.PoiSearcher.GetMapsClient())
+}
+
+// createMigrationRegistry registers data migrations, migrations of place fields require the Google Maps search client
+func createMigrationRegistry(redisClient *iowrappers.RedisClient, mapsClient *iowrappers.MapsClient) *iowrappers.MigrationRegistry {
+	migrations := []iowrappers.Migration{iowrappers.CreateSlotSolutionCacheMigration(redisClient)}
+	if mapsClient != nil {
+		migrations = append(migrations, iowrappers.CreateUserRatingsTotalMigration(mapsClient, redisClient), iowrappers.CreateUrlMigration(mapsClient, redisClient))
+	}
+	registry := iowrappers.CreateMigrationRegistry(redisClient)
+	for _, migration := range migrations {
+		if err := registry.Register(migration); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return registry
+}
+
+// mapsQuotaConfig reads the rate limit and the daily budget of Google Maps calls, which are unlimited by default
+func mapsQuotaConfig(configs map[string]interface{}) iowrappers.MapsQuotaConfig {
+	config := iowrappers.MapsQuotaConfig{}
+	config.RateLimit, _ = configs["server:maps_quota:rate_limit"].(float64)
+	config.Burst, _ = configs["server:maps_quota:burst"].(int)
+	config.DailyBudget, _ = configs["server:maps_quota:daily_budget"].(float64)
+	return config
+}
+
+// placeRefreshConfig reads the daily quota of place refreshes, which are disabled by default, and the batches of refreshes
+func placeRefreshConfig(configs map[string]interface{}) iowrappers.PlaceRefreshConfig {
+	config := iowrappers.PlaceRefreshConfig{}
+	config.DailyQuota, _ = configs["server:place_refresh:daily_quota"].(int)
+	config.BatchSize, _ = configs["server:place_refresh:batch_size"].(int)
+	config.Interval, _ = configs["server:place_refresh:interval"].(time.Duration)
+	config.MinAge, _ = configs["server:place_refresh:min_age"].(time.Duration)
+	return config
+}
+
+// createSearchClient creates the search client of the provider in configs, Google Maps by default
+// the search client falls back to search clients of the fallback providers in order if it fails or finds too few places
+func createSearchClient(mapsClientApiKey string, redisClient *iowrappers.RedisClient, configs map[string]interface{}) (iowrappers.SearchClient, error) {
+	provider, _ := configs["server:search_client:provider"].(string)
+	fallbackProviders, _ := configs["server:search_client:fallback_providers"].([]string)
+	if len(fallbackProviders) == 0 {
+		return createProviderSearchClient(provider, mapsClientApiKey, redisClient, configs)
+	}
+	searchClients := make([]iowrappers.SearchClient, 0, 1+len(fallbackProviders))
+	for _, searchProvider := range append([]string{provider}, fallbackProviders...) {
+		searchClient, err := createProviderSearchClient(searchProvider, mapsClientApiKey, redisClient, configs)
+		if err != nil {
+			return nil, err
+		}
+		searchClients = append(searchClients, searchClient)
+	}
+	return iowrappers.CreateFallbackSearchClient(searchClients...), nil
+}
+
+// createProviderSearchClient creates the search client of a provider
+// the fixtures provider serves places from a fixtures file for development and tests without network access,
+// the Google Maps client can record its exchanges to a cassette directory or replay them without network access,
+// and the OSM provider searches an Overpass API endpoint or an extract imported into Redis when the server starts
+func createProviderSearchClient(provider string, mapsClientApiKey string, redisClient *iowrappers.RedisClient, configs map[string]interface{}) (iowrappers.SearchClient, error) {
+	switch provider {
+	case "", iowrappers.SearchClientProviderGoogleMaps:
+		recordingMode, _ := configs["server:search_client:maps_recording_mode"].(string)
+		cassetteDir, _ := configs["server:search_client:cassette_dir"].(string)
+		if recordingMode == iowrappers.MapsRecordingModeReplay {
+			replayServer, err := iowrappers.CreateMapsReplayServer(cassetteDir)
+			if err != nil {
+				return nil, err
+			}
+			mapsClient := iowrappers.CreateReplayMapsClient(replayServer.URL)
+			return &mapsClient, nil
+		}
+		if recordingMode != "" && recordingMode != iowrappers.MapsRecordingModeRecord {
+			return nil, fmt.Errorf("unknown Google Maps recording mode %s", recordingMode)
+		}
+		if mapsClientApiKey == "" {
+			return nil, errors.New("the Google Maps search client requires an API key")
+		}
+		if recordingMode == iowrappers.MapsRecordingModeRecord {
+			mapsClient := iowrappers.CreateRecordingMapsClient(mapsClientApiKey, cassetteDir)
+			return &mapsClient, nil
+		}
+		mapsClient := iowrappers.CreateMapsClient(mapsClientApiKey)
+		return &mapsClient, nil
+	case iowrappers.SearchClientProviderFixtures:
+		fixturesFile, _ := configs["server:search_client:fixtures_file"].(string)
+		return iowrappers.CreateFixtureSearchClient(fixturesFile)
+	case iowrappers.SearchClientProviderOSM:
+		extractFile, _ := configs["server:search_client:osm:extract_file"].(string)
+		if extractFile == "" {
+			overpassURL, _ := configs["server:search_client:osm:overpass_url"].(string)
+			if overpassURL == "" {
+				return nil, errors.New("the OSM search client requires an Overpass API URL or an extract file")
+			}
+			return iowrappers.CreateOverpassSearchClient(overpassURL), nil
+		}
+		extractCity, _ := configs["server:search_client:osm:extract_city"].(string)
+		extractCountry, _ := configs["server:search_client:osm:extract_country"].(string)
+		numPlaces, err := iowrappers.ImportOSMExtract(context.Background(), redisClient, extractFile,
+			iowrappers.GeocodeQuery{City: extractCity, Country: extractCountry})
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("imported %d places of %s, %s from OSM extract %s", numPlaces, extractCity, extractCountry, extractFile)
+		return iowrappers.CreateOSMExtractSearchClient(redisClient), nil
+	default:
+		return nil, fmt.Errorf("unknown search client provider %s", provider)
 	}
 }
 
@@ -140,7 +408,12 @@ func (planner *MyPlanner) Destroy() {
 func (planner *MyPlanner) ReverseGeocodingHandler(context *gin.Context) {
 	latitude, _ := strconv.ParseFloat(context.Query("lat"), 64)
 	longitude, _ := strconv.ParseFloat(context.Query("lng"), 64)
-	result, err := planner.Solver.Matcher.PoiSearcher.GetMapsClient().ReverseGeocoding(context, latitude, longitude)
+	mapsClient := planner.Solver.Matcher.PoiSearcher.GetMapsClient()
+	if mapsClient == nil {
+		context.JSON(http.StatusNotImplemented, "reverse geocoding requires the Google Maps search client")
+		return
+	}
+	result, err := mapsClient.ReverseGeocoding(context, latitude, longitude)
 	if err != nil {
 		log.Error(err)
 		context.JSON(http.StatusInternalServerError, err.Error())
@@ -151,26 +424,175 @@ func (planner *MyPlanner) ReverseGeocodingHandler(context *gin.Context) {
 	})
 }
 
-func (planner *MyPlanner) UserRatingsTotalMigrationHandler(context *gin.Context) {
+func (planner *MyPlanner) MigrationListHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	statuses, err := planner.Migrations.Statuses(context.Request.Context())
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"migrations": statuses})
+}
+
+// MigrationStatusHandler responds with the progress and errors of the last run of a migration
+func (planner *MyPlanner) MigrationStatusHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	status, err := planner.Migrations.Status(context.Request.Context(), context.Param("name"))
+	respondWithMigrationStatus(context, status, err)
+}
+
+// MigrationStartHandler runs a migration from the start in the background, and dry runs report changes without writing them
+func (planner *MyPlanner) MigrationStartHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	dryRun, err := strconv.ParseBool(context.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("dry_run", "dry_run must be true or false")))
+		return
+	}
+	status, err := planner.Migrations.Start(context.Request.Context(), context.Param("name"), dryRun)
+	respondWithMigrationStatus(context, status, err)
+}
+
+func (planner *MyPlanner) MigrationPauseHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	status, err := planner.Migrations.Pause(context.Request.Context(), context.Param("name"))
+	respondWithMigrationStatus(context, status, err)
+}
+
+func (planner *MyPlanner) MigrationResumeHandler(context *gin.Context) {
 	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
 	if authenticationErr != nil {
 		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
 		return
 	}
-	if err := planner.Solver.Matcher.PoiSearcher.AddUserRatingsTotal(context.Request.Context()); err != nil {
+	status, err := planner.Migrations.Resume(context.Request.Context(), context.Param("name"))
+	respondWithMigrationStatus(context, status, err)
+}
+
+func respondWithMigrationStatus(context *gin.Context, status iowrappers.MigrationStatus, err error) {
+	switch {
+	case err == nil:
+		context.JSON(http.StatusOK, status)
+	case errors.Is(err, iowrappers.ErrMigrationNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, iowrappers.ErrMigrationRunning) || errors.Is(err, iowrappers.ErrMigrationNotRunning) ||
+		errors.Is(err, iowrappers.ErrMigrationNotResumable):
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
 		log.Error(err)
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 }
 
-func (planner *MyPlanner) UrlMigrationHandler(context *gin.Context) {
+// CatalogueExportHandler streams the place catalogue, or the places within the radius of a city or a bounding box, as an NDJSON or GeoJSON bundle
+func (planner *MyPlanner) CatalogueExportHandler(context *gin.Context) {
 	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
 	if authenticationErr != nil {
 		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
 		return
 	}
-	if err := planner.Solver.Matcher.PoiSearcher.AddUrl(context.Request.Context()); err != nil {
+	format := context.DefaultQuery("format", iowrappers.CatalogueFormatNDJSON)
+	contentTypes := map[string]string{iowrappers.CatalogueFormatNDJSON: "application/x-ndjson", iowrappers.CatalogueFormatGeoJSON: "application/geo+json"}
+	if _, exists := contentTypes[format]; !exists {
+		context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("format", "format must be %s or %s", iowrappers.CatalogueFormatNDJSON, iowrappers.CatalogueFormatGeoJSON)))
+		return
+	}
+	filter := iowrappers.CatalogueFilter{City: context.Query("city"), Country: context.Query("country")}
+	if radius := context.Query("radius"); radius != "" {
+		var err error
+		if filter.Radius, err = strconv.ParseUint(radius, 10, 64); err != nil {
+			context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("radius", "radius must be a non-negative integer in meters")))
+			return
+		}
+	}
+	if bbox := context.Query("bbox"); bbox != "" {
+		if filter.City != "" {
+			context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("bbox", "bbox cannot be used with city")))
+			return
+		}
+		var err error
+		if filter.BoundingBox, err = iowrappers.ParseBoundingBox(bbox); err != nil {
+			context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("bbox", "%s", err.Error())))
+			return
+		}
+	}
+
+	context.Header("Content-Type", contentTypes[format])
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=catalogue-%s.%s", time.Now().UTC().Format(TripDateLayout), format))
+	stats, err := planner.RedisClient.ExportCatalogue(context.Request.Context(), context.Writer, format, filter)
+	if err != nil {
+		log.Error(err)
+		// the filter is checked before the bundle is written
+		if !context.Writer.Written() {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	log.Infof("exported %d places, %d cities and %d aliases of the catalogue", stats.Places, stats.Cities, stats.Aliases)
+}
+
+// CatalogueImportHandler imports an NDJSON or GeoJSON bundle of places in the request body and rebuilds their geo indexes
+func (planner *MyPlanner) CatalogueImportHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	stats, err := planner.RedisClient.ImportCatalogue(context.Request.Context(), context.Request.Body)
+	if errors.Is(err, iowrappers.ErrInvalidCatalogueBundle) {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "imported": stats})
+		return
+	}
+	if err != nil {
 		log.Error(err)
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "imported": stats})
+		return
 	}
+	context.JSON(http.StatusOK, gin.H{"imported": stats})
+}
+
+// SolutionInvalidationRequest lists places whose cached solutions are invalidated by admins
+type SolutionInvalidationRequest struct {
+	PlaceIds []string `json:"place_ids"`
+}
+
+func (planner *MyPlanner) SolutionInvalidationHandler(context *gin.Context) {
+	_, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+	req := SolutionInvalidationRequest{}
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.PlaceIds) == 0 {
+		context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("place_ids", "at least one place ID is required")))
+		return
+	}
+	invalidatedCount, err := planner.RedisClient.InvalidatePlaceSolutions(context.Request.Context(), iowrappers.InvalidationReasonAdmin, req.PlaceIds...)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"invalidated_solutions": invalidatedCount})
 }
 
 func (planner *MyPlanner) PlaceStatsHandler(context *gin.Context) {
@@ -199,6 +621,39 @@ func (planner *MyPlanner) PlaceStatsHandler(context *gin.Context) {
 	})
 }
 
+// MapsQuotaStatsHandler responds with Google Maps calls and spend of a UTC day, today by default,
+// and calls of a user on the day and of a request if username or request_id is given, calls of users are only for admins
+func (planner *MyPlanner) MapsQuotaStatsHandler(context *gin.Context) {
+	username := context.Query("username")
+	if username != "" {
+		if _, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelAdmin); authenticationErr != nil {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+	date := context.DefaultQuery("date", time.Now().UTC().Format(iowrappers.MapsQuotaDateLayout))
+	if _, err := time.Parse(iowrappers.MapsQuotaDateLayout, date); err != nil {
+		context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("date", "date must be in the format of %s", iowrappers.MapsQuotaDateLayout)))
+		return
+	}
+	stats, err := planner.Solver.Matcher.PoiSearcher.GetMapsMeter().Stats(context, date, username, context.Query("request_id"))
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, stats)
+}
+
+// SolutionInvalidationStatsHandler responds with the number of places and cached solutions invalidated for each reason
+func (planner *MyPlanner) SolutionInvalidationStatsHandler(context *gin.Context) {
+	stats, err := planner.RedisClient.GetSolutionInvalidationStats(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, stats)
+}
+
 type GeocodeCityView struct {
 	Count  int
 	Cities map[string]string
@@ -235,16 +690,7 @@ func (planner *MyPlanner) Planning(ctx context.Context, planningRequest *solutio
 	}
 
 	// logging planning API usage for valid requests
-	countryCity := planningRequest.Location
-	countryAndCity := strings.Split(countryCity, ",")
-	event := iowrappers.PlanningEvent{
-		User:      user,
-		Country:   countryAndCity[1],
-		City:      countryAndCity[0],
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-	planner.PlanningEvents <- event
-	planner.PlanningEventLogging(event)
+	planner.logPlanningEvent(planningRequest.Location, user)
 
 	if len(planningResponse.Solutions) == 0 {
 		resp.Err = errors.New("cannot find a valid solution")
@@ -255,31 +701,192 @@ func (planner *MyPlanner) Planning(ctx context.Context, planningRequest *solutio
 	topSolutions := planningResponse.Solutions
 	resp.Places = make([]TimeSectionPlaces, len(topSolutions))
 	for sIdx, topSolution := range topSolutions {
-		timeSectionPlaces := TimeSectionPlaces{
-			Places: make([]TimeSectionPlace, 0),
+		resp.Places[sIdx] = toTimeSectionPlaces(topSolution, planningRequest.Slots)
+	}
+
+	resp.StatusCode = solution.ValidSolutionFound
+	resp.TravelDestination = travelDestination(planningRequest.Location)
+	return
+}
+
+// BudgetPlanning solves the single-day planning task within a time window and a budget
+func (planner *MyPlanner) BudgetPlanning(ctx context.Context, planningRequest *solution.BudgetPlanningRequest, user string) (resp PlanningResponse) {
+	var planningResponse solution.BudgetPlanningResponse
+
+	planner.Solver.SolveWithBudget(ctx, planningRequest, &planningResponse)
+	if planningResponse.Err != nil {
+		resp.Err = planningResponse.Err
+		resp.StatusCode = planningResponse.ErrorCode
+		return
+	}
+
+	planner.logPlanningEvent(planningRequest.Location, user)
+
+	timeSectionPlaces := TimeSectionPlaces{
+		Places:    make([]TimeSectionPlace, len(planningResponse.Places)),
+		TotalCost: planningResponse.TotalCost,
+		TotalTime: planningResponse.TotalTime,
+		Score:     planningResponse.Score,
+	}
+	for idx, scheduledPlace := range planningResponse.Places {
+		timeSectionPlaces.Places[idx] = TimeSectionPlace{
+			PlaceId:   scheduledPlace.Place.GetPlaceId(),
+			PlaceName: scheduledPlace.Place.GetPlaceName(),
+			Category:  scheduledPlace.Place.GetPlaceCategory(),
+			StartTime: scheduledPlace.TimeInterval.Start,
+			EndTime:   scheduledPlace.TimeInterval.End,
+			Address:   scheduledPlace.Place.GetPlaceFormattedAddress(),
+			URL:       scheduledPlace.Place.GetURL(),
 		}
-		for pIdx, placeName := range topSolution.PlaceNames {
-			timeSectionPlaces.Places = append(timeSectionPlaces.Places, TimeSectionPlace{
-				PlaceName: placeName,
-				StartTime: planningRequest.Slots[pIdx].TimeSlot.Slot.Start,
-				EndTime:   planningRequest.Slots[pIdx].TimeSlot.Slot.End,
-				Address:   topSolution.PlaceAddresses[pIdx],
-				URL:       topSolution.PlaceURLs[pIdx],
-			})
+	}
+	resp.Places = []TimeSectionPlaces{timeSectionPlaces}
+	resp.StatusCode = solution.ValidSolutionFound
+	resp.TravelDestination = travelDestination(planningRequest.Location)
+	return
+}
+
+// MultiDayPlanning solves the multi-day, single-city planning task
+func (planner *MyPlanner) MultiDayPlanning(ctx context.Context, planningRequest *solution.MultiDayPlanningRequest, user string) (resp MultiDayPlanningResponse) {
+	var planningResponse solution.MultiDayPlanningResponse
+
+	planner.Solver.SolveMultiDay(ctx, planner.RedisClient, planningRequest, &planningResponse)
+
+	if planningResponse.Err != nil {
+		resp.Err = planningResponse.Err
+		resp.StatusCode = planningResponse.ErrorCode
+		return
+	}
+
+	planner.logPlanningEvent(planningRequest.Location, user)
+
+	if len(planningResponse.Trips) == 0 {
+		resp.Err = errors.New("cannot find a valid solution")
+		resp.StatusCode = solution.NoValidSolution
+		return
+	}
+
+	resp.Trips = make([]TripPlan, len(planningResponse.Trips))
+	for tIdx, trip := range planningResponse.Trips {
+		tripPlan := TripPlan{Days: make([]TripDay, len(trip.Days)), Score: trip.Score}
+		for day, daySolution := range trip.Days {
+			dayRequest := planningResponse.DayRequests[day]
+			tripPlan.Days[day] = TripDay{
+				Date:    planningRequest.StartDate.AddDate(0, 0, day).Format(TripDateLayout),
+				Weekday: dayRequest.Weekday,
+				Places:  toTimeSectionPlaces(daySolution, dayRequest.Slots).Places,
+			}
 		}
-		resp.Places[sIdx] = timeSectionPlaces
+		resp.Trips[tIdx] = tripPlan
 	}
 
 	resp.StatusCode = solution.ValidSolutionFound
-	if len(planningRequest.Location) > 0 {
-		// City name
-		resp.TravelDestination = strings.Title(strings.Split(planningRequest.Location, ",")[0])
-	} else {
-		resp.TravelDestination = "Dream Vacation Destination"
+	resp.TravelDestination = travelDestination(planningRequest.Location)
+	return
+}
+
+// MultiCityPlanning solves the multi-day, multi-city planning task
+func (planner *MyPlanner) MultiCityPlanning(ctx context.Context, planningRequest *solution.MultiCityPlanningRequest, user string) (resp MultiCityPlanningResponse) {
+	var planningResponse solution.MultiCityPlanningResponse
+
+	planner.Solver.SolveMultiCity(ctx, planner.RedisClient, planningRequest, &planningResponse)
+
+	if planningResponse.Err != nil {
+		resp.Err = planningResponse.Err
+		resp.StatusCode = planningResponse.ErrorCode
+		return
+	}
+
+	for _, leg := range planningResponse.Legs {
+		planner.logPlanningEvent(leg.Location, user)
+	}
+
+	if len(planningResponse.Trips) == 0 {
+		resp.Err = errors.New("cannot find a valid solution")
+		resp.StatusCode = solution.NoValidSolution
+		return
 	}
+
+	resp.Trips = make([]MultiCityTripPlan, len(planningResponse.Trips))
+	for tIdx, trip := range planningResponse.Trips {
+		tripPlan := MultiCityTripPlan{Legs: make([]TripLegPlan, len(planningResponse.Legs)), Score: trip.Score}
+		for lIdx, leg := range planningResponse.Legs {
+			cityCountry := strings.Split(leg.Location, ",")
+			legPlan := TripLegPlan{
+				City:    strings.Title(cityCountry[0]),
+				Country: strings.Title(cityCountry[1]),
+				Days:    make([]TripDay, leg.NumDays),
+			}
+			for legDay := range legPlan.Days {
+				day := leg.StartDay + legDay
+				dayRequest := planningResponse.DayRequests[day]
+				legPlan.Days[legDay] = TripDay{
+					Date:    planningRequest.StartDate.AddDate(0, 0, day).Format(TripDateLayout),
+					Weekday: dayRequest.Weekday,
+					Places:  toTimeSectionPlaces(trip.Days[day], dayRequest.Slots).Places,
+				}
+			}
+			tripPlan.Legs[lIdx] = legPlan
+		}
+		resp.Trips[tIdx] = tripPlan
+	}
+
+	resp.StatusCode = solution.ValidSolutionFound
 	return
 }
 
+func (planner *MyPlanner) logPlanningEvent(location string, user string) {
+	countryAndCity := strings.Split(location, ",")
+	event := iowrappers.PlanningEvent{
+		User:      user,
+		Country:   countryAndCity[1],
+		City:      countryAndCity[0],
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	planner.PlanningEvents <- event
+	planner.PlanningEventLogging(event)
+}
+
+func toTimeSectionPlaces(planningSolution solution.PlanningSolution, slots []solution.SlotRequest) TimeSectionPlaces {
+	scoreBreakdown := planningSolution.ScoreBreakdown
+	timeSectionPlaces := TimeSectionPlaces{
+		Places:         make([]TimeSectionPlace, 0),
+		Score:          planningSolution.Score,
+		ScoreBreakdown: &scoreBreakdown,
+	}
+	for pIdx, placeName := range planningSolution.PlaceNames {
+		timeSectionPlace := TimeSectionPlace{
+			PlaceId:   planningSolution.PlaceIDS[pIdx],
+			PlaceName: placeName,
+			Category:  slots[pIdx].Category,
+			StartTime: slots[pIdx].TimeSlot.Slot.Start,
+			EndTime:   slots[pIdx].TimeSlot.Slot.End,
+			Address:   planningSolution.PlaceAddresses[pIdx],
+			URL:       planningSolution.PlaceURLs[pIdx],
+		}
+		if len(planningSolution.Schedule) == len(planningSolution.PlaceNames) {
+			visit := planningSolution.Schedule[pIdx]
+			timeSectionPlace.VisitStart = formatMinute(visit.Start)
+			timeSectionPlace.VisitEnd = formatMinute(visit.End)
+			timeSectionPlace.Transit = visit.Transit
+		}
+		timeSectionPlaces.Places = append(timeSectionPlaces.Places, timeSectionPlace)
+	}
+	return timeSectionPlaces
+}
+
+// formatMinute formats minutes since midnight as HH:MM
+func formatMinute(minute POI.Minute) string {
+	return fmt.Sprintf("%02d:%02d", minute/POI.MinutesPerHour, minute%POI.MinutesPerHour)
+}
+
+func travelDestination(location string) string {
+	if len(location) > 0 {
+		// City name
+		return strings.Title(strings.Split(location, ",")[0])
+	}
+	return "Dream Vacation Destination"
+}
+
 // API definitions
 func (planner *MyPlanner) searchPageHandler(c *gin.Context) {
 	c.HTML(http.StatusOK, "search_page.html", gin.H{})
@@ -290,41 +897,219 @@ func (planner *MyPlanner) homePageHandler(c *gin.Context){
 }
 
 // HTTP POST API end-point
-//func (planner *MyPlanner) postPlanningApi(context *gin.Context) {
-//	var username = "guest" // default username
-//	if planner.Environment == "production" {
-//		var authenticationErr error
-//		username, authenticationErr = planner.UserAuthentication(context, context.Request, user.LevelRegular)
-//		if authenticationErr != nil {
-//			utils.LogErrorWithLevel(authenticationErr, utils.LogDebug)
-//			planner.login(context)
-//			return
-//		}
-//	}
-//
-//	req := PlanningPostRequest{}
-//	err := context.ShouldBindJSON(&req)
-//	utils.LogErrorWithLevel(err, utils.LogInfo)
-//	if err != nil {
-//		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-//		return
-//	}
-//
-//	planningReq, err := processPlanningPostRequest(&req)
-//	utils.LogErrorWithLevel(err, utils.LogInfo)
-//	if err != nil {
-//		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-//		return
-//	}
-//
-//	planningResp := planner.Planning(context, &planningReq, username)
-//	if planningResp.Err != nil && planningResp.StatusCode == http.StatusNotFound {
-//		context.JSON(http.StatusNotFound, gin.H{"error": "No solution is found"})
-//		return
-//	}
-//	// generate valid solution
-//	utils.LogErrorWithLevel(planner.ResultHTMLTemplate.Execute(context.Writer, planningResp), utils.LogError)
-//}
+// Return top planning results to user in JSON
+func (planner *MyPlanner) postPlanningApi(ctx *gin.Context) {
+	var username = "guest" // default username
+	if strings.ToLower(planner.Environment) == "production" {
+		var authenticationErr error
+		username, authenticationErr = planner.UserAuthentication(ctx, ctx.Request, user.LevelRegular)
+		if authenticationErr != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+
+	req := PlanningPostRequest{}
+	err := ctx.ShouldBindJSON(&req)
+	utils.LogErrorWithLevel(err, utils.LogInfo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c := context.WithValue(ctx, "request_id", requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.UsernameKey, username)
+	var planningResp PlanningResponse
+	switch strings.ToLower(req.Mode) {
+	case "", PlanningModeSlots:
+		planningReq, err := processPlanningPostRequest(&req)
+		utils.LogErrorWithLevel(err, utils.LogInfo)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+			return
+		}
+		if planningReq.ScoreWeights == nil {
+			planningReq.ScoreWeights = planner.userScoreWeights(c, username)
+		}
+		planningResp = planner.Planning(c, &planningReq, username)
+	case PlanningModeBudget:
+		planningReq, err := processBudgetPlanningPostRequest(&req)
+		utils.LogErrorWithLevel(err, utils.LogInfo)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+			return
+		}
+		planningResp = planner.BudgetPlanning(c, &planningReq, username)
+	default:
+		ctx.JSON(http.StatusBadRequest, toErrorBody(newValidationError("mode", "invalid planning mode %s", req.Mode)))
+		return
+	}
+	if planningResp.Err != nil {
+		switch planningResp.StatusCode {
+		case solution.NoValidSolution:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "No solution is found"})
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": planningResp.Err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, planningResp)
+}
+
+// HTTP POST API end-point for replacing the place of a slot in an existing plan
+// Return alternatives of the slot ranked by the score of the plan with each of them
+func (planner *MyPlanner) postSlotAlternativesApi(ctx *gin.Context) {
+	var username = "guest" // default username
+	if strings.ToLower(planner.Environment) == "production" {
+		var authenticationErr error
+		username, authenticationErr = planner.UserAuthentication(ctx, ctx.Request, user.LevelRegular)
+		if authenticationErr != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+
+	req := SlotAlternativesPostRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alternativesReq, err := processSlotAlternativesPostRequest(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+		return
+	}
+
+	c := context.WithValue(ctx, "request_id", requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.UsernameKey, username)
+	if alternativesReq.ScoreWeights == nil {
+		alternativesReq.ScoreWeights = planner.userScoreWeights(c, username)
+	}
+	var alternativesResp solution.SlotAlternativesResponse
+	planner.Solver.FindSlotAlternatives(c, &alternativesReq, &alternativesResp)
+	if alternativesResp.Err != nil {
+		switch alternativesResp.ErrorCode {
+		case solution.NoValidSolution:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": alternativesResp.Err.Error()})
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": alternativesResp.Err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, SlotAlternativesResponse{Slot: alternativesReq.SlotIndex, Alternatives: alternativesResp.Alternatives})
+}
+
+// HTTP POST API end-point for optimizing the visiting order of given places
+func (planner *MyPlanner) postRouteOptimizationApi(ctx *gin.Context) {
+	if strings.ToLower(planner.Environment) == "production" {
+		if _, authenticationErr := planner.UserAuthentication(ctx, ctx.Request, user.LevelRegular); authenticationErr != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+
+	req := RouteOptimizationRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locations, categories, err := processRouteOptimizationRequest(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+		return
+	}
+
+	order, totalDistance := solution.OptimizeVisitOrder(locations, categories, nil)
+	resp := RouteOptimizationResponse{Stops: make([]RouteStop, len(order)), TotalDistance: math.Round(totalDistance)}
+	for position, stop := range order {
+		resp.Stops[position] = req.Stops[stop]
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// HTTP POST API end-point for multi-day trips
+// Return top trips to user in JSON
+func (planner *MyPlanner) postMultiDayPlanningApi(ctx *gin.Context) {
+	var username = "guest" // default username
+	if strings.ToLower(planner.Environment) == "production" {
+		var authenticationErr error
+		username, authenticationErr = planner.UserAuthentication(ctx, ctx.Request, user.LevelRegular)
+		if authenticationErr != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+
+	req := MultiDayPlanningPostRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	planningReq, err := processMultiDayPlanningPostRequest(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+		return
+	}
+
+	c := context.WithValue(ctx, "request_id", requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.UsernameKey, username)
+	planningReq.ScoreWeights = planner.userScoreWeights(c, username)
+	planningResp := planner.MultiDayPlanning(c, &planningReq, username)
+	if planningResp.Err != nil {
+		switch planningResp.StatusCode {
+		case solution.NoValidSolution:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": planningResp.Err.Error()})
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": planningResp.Err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, planningResp)
+}
+
+// HTTP POST API end-point for multi-city trips
+// Return top trips with their legs to user in JSON
+func (planner *MyPlanner) postMultiCityPlanningApi(ctx *gin.Context) {
+	var username = "guest" // default username
+	if strings.ToLower(planner.Environment) == "production" {
+		var authenticationErr error
+		username, authenticationErr = planner.UserAuthentication(ctx, ctx.Request, user.LevelRegular)
+		if authenticationErr != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+			return
+		}
+	}
+
+	req := MultiCityPlanningPostRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	planningReq, err := processMultiCityPlanningPostRequest(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, toErrorBody(err))
+		return
+	}
+
+	c := context.WithValue(ctx, "request_id", requestid.Get(ctx))
+	c = context.WithValue(c, iowrappers.UsernameKey, username)
+	planningReq.ScoreWeights = planner.userScoreWeights(c, username)
+	planningResp := planner.MultiCityPlanning(c, &planningReq, username)
+	if planningResp.Err != nil {
+		switch planningResp.StatusCode {
+		case solution.NoValidSolution:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": planningResp.Err.Error()})
+		default:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": planningResp.Err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, planningResp)
+}
 
 // HTTP GET API end-point
 // Return top planning result to user
@@ -373,6 +1158,8 @@ func (planner *MyPlanner) getPlanningApi(ctx *gin.Context) {
 	planningReq.Location = cityCountry
 
 	c := context.WithValue(ctx, "request_id", requestId)
+	c = context.WithValue(c, iowrappers.UsernameKey, username)
+	planningReq.ScoreWeights = planner.userScoreWeights(c, username)
 	planningResp := planner.Planning(c, &planningReq, username)
 
 	err := planningResp.Err
@@ -405,7 +1192,7 @@ func (planner MyPlanner) SetupRouter(serverPort string) *http.Server {
 	gin.DefaultWriter = ioutil.Discard
 
 	myRouter := gin.Default()
-	myRouter.LoadHTMLGlob("templates/*")
+	myRouter.LoadHTMLGlob(filepath.Join(planner.TemplatesDir, "*"))
 	// trace ID
 	myRouter.Use(requestid.New())
 
@@ -419,17 +1206,31 @@ func (planner MyPlanner) SetupRouter(serverPort string) *http.Server {
 	{
 		v1.GET("/", planner.searchPageHandler)
 		v1.GET("/plans", planner.getPlanningApi)
-		//v1.POST("/plans", planner.postPlanningApi)
+		v1.POST("/plans", planner.postPlanningApi)
+		v1.POST("/plans/alternatives", planner.postSlotAlternativesApi)
+		v1.POST("/trips", planner.postMultiDayPlanningApi)
+		v1.POST("/multi-city-trips", planner.postMultiCityPlanningApi)
+		v1.POST("/routes", planner.postRouteOptimizationApi)
 		v1.POST("/signup", planner.UserSignup)
 		v1.POST("/login", planner.UserLogin)
+		v1.PUT("/users/score-weights", planner.UserScoreWeights)
 		v1.GET("/reverse-geocoding", planner.ReverseGeocodingHandler)
 		v1.GET("/single-day-nearby-search", planner.SingleDayNearbySearchHandler)
 		v1.GET("/log-in", planner.login)
 		v1.GET("/sign-up", planner.signup)
-		migrations := v1.Group("/migrate")
+		v1.POST("/solution-cache/invalidations", planner.SolutionInvalidationHandler)
+		migrations := v1.Group("/migrations")
+		{
+			migrations.GET("", planner.MigrationListHandler)
+			migrations.GET("/:name", planner.MigrationStatusHandler)
+			migrations.POST("/:name/start", planner.MigrationStartHandler)
+			migrations.POST("/:name/pause", planner.MigrationPauseHandler)
+			migrations.POST("/:name/resume", planner.MigrationResumeHandler)
+		}
+		catalogue := v1.Group("/catalogue")
 		{
-			migrations.GET("/user-ratings-total", planner.UserRatingsTotalMigrationHandler)
-			migrations.GET("/url", planner.UrlMigrationHandler)
+			catalogue.GET("/export", planner.CatalogueExportHandler)
+			catalogue.POST("/import", planner.CatalogueImportHandler)
 		}
 	}
 
@@ -438,6 +1239,8 @@ func (planner MyPlanner) SetupRouter(serverPort string) *http.Server {
 	{
 		stats.GET("places", planner.PlaceStatsHandler)
 		stats.GET("cities", planner.CityStatsHandler)
+		stats.GET("maps-quota", planner.MapsQuotaStatsHandler)
+		stats.GET("solution-invalidations", planner.SolutionInvalidationStatsHandler)
 	}
 
 	svr := &http.Server{
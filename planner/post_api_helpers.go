@@ -3,18 +3,222 @@ package planner
 import (
 	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
 	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
 	"github.com/weihesdlegend/Vacation-planner/solution"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+	"math"
+	"strings"
+	"time"
 )
 
+const (
+	DefaultSearchRadius = 10000
+)
+
+func processMultiDayPlanningPostRequest(req *MultiDayPlanningPostRequest) (planningRequest solution.MultiDayPlanningRequest, err error) {
+	if strings.TrimSpace(req.City) == "" || strings.TrimSpace(req.Country) == "" {
+		err = newValidationError("city", "city and country are required")
+		return
+	}
+	planningRequest.Location = req.City + "," + req.Country
+
+	planningRequest.StartDate, err = time.Parse(TripDateLayout, req.StartDate)
+	if err != nil {
+		err = newValidationError("start_date", "invalid start date %s, expected format is YYYY-MM-DD", req.StartDate)
+		return
+	}
+
+	if req.NumDays <= 0 || req.NumDays > solution.MaxTripDays {
+		err = newValidationError("num_days", "number of days must be between 1 and %d", solution.MaxTripDays)
+		return
+	}
+	planningRequest.NumDays = req.NumDays
+
+	if req.NumPlans < 0 {
+		err = newValidationError("num_plans", "number of plans cannot be negative")
+		return
+	}
+	planningRequest.NumPlans = req.NumPlans
+
+	planningRequest.SearchRadius = req.Radius
+	if planningRequest.SearchRadius == 0 {
+		planningRequest.SearchRadius = DefaultSearchRadius
+	}
+
+	if len(req.DaySlots) == 0 {
+		planningRequest.DaySlots = [][]solution.SlotRequest{solution.GetStandardRequest(POI.DateMonday, 0).Slots}
+		return
+	}
+
+	planningRequest.DaySlots = make([][]solution.SlotRequest, len(req.DaySlots))
+	for day, slotTemplates := range req.DaySlots {
+		if planningRequest.DaySlots[day], err = toSlotRequests(slotTemplates); err != nil {
+			var validationErr RequestValidationError
+			if errors.As(err, &validationErr) {
+				err = newValidationError(fmt.Sprintf("day_%s", validationErr.Field), "%s", validationErr.Message)
+			}
+			return
+		}
+	}
+	return
+}
+
+func processMultiCityPlanningPostRequest(req *MultiCityPlanningPostRequest) (planningRequest solution.MultiCityPlanningRequest, err error) {
+	if len(req.Legs) == 0 || len(req.Legs) > solution.MaxTripLegs {
+		err = newValidationError("legs", "a trip must have 1 to %d legs", solution.MaxTripLegs)
+		return
+	}
+
+	// legs share the validation rules of single-city trips
+	planningRequest.Legs = make([]solution.TripLegRequest, len(req.Legs))
+	for idx, leg := range req.Legs {
+		var legRequest solution.MultiDayPlanningRequest
+		legRequest, err = processMultiDayPlanningPostRequest(&MultiDayPlanningPostRequest{
+			Country:   leg.Country,
+			City:      leg.City,
+			Radius:    leg.Radius,
+			StartDate: req.StartDate,
+			NumDays:   leg.NumDays,
+			NumPlans:  req.NumPlans,
+			DaySlots:  req.DaySlots,
+		})
+		if err != nil {
+			var validationErr RequestValidationError
+			if errors.As(err, &validationErr) {
+				err = newValidationError(fmt.Sprintf("legs[%d].%s", idx, validationErr.Field), "%s", validationErr.Message)
+			}
+			return
+		}
+		planningRequest.Legs[idx] = solution.TripLegRequest{
+			City:         leg.City,
+			Country:      leg.Country,
+			NumDays:      leg.NumDays,
+			SearchRadius: legRequest.SearchRadius,
+		}
+		planningRequest.StartDate = legRequest.StartDate
+		planningRequest.DaySlots = legRequest.DaySlots
+		planningRequest.NumPlans = legRequest.NumPlans
+	}
+	return
+}
+
+// toSlotRequests validates slot templates from users and converts them to slot requests
+func toSlotRequests(slotTemplates []SlotTemplate) ([]solution.SlotRequest, error) {
+	if len(slotTemplates) == 0 {
+		return nil, newValidationError("slots", "a day must have at least one slot")
+	}
+	if len(slotTemplates) > MaxPlacesPerDay {
+		return nil, newValidationError("slots", "total number of places cannot exceed %d", MaxPlacesPerDay)
+	}
+	slotRequests := make([]solution.SlotRequest, len(slotTemplates))
+	for idx, slotTemplate := range slotTemplates {
+		if slotTemplate.End > 24 || slotTemplate.Start >= slotTemplate.End {
+			return nil, newValidationError(fmt.Sprintf("slots[%d]", idx), "invalid time slot %d-%d", slotTemplate.Start, slotTemplate.End)
+		}
+		if idx > 0 && slotTemplate.Start < slotTemplates[idx-1].End {
+			return nil, newValidationError(fmt.Sprintf("slots[%d]", idx), "time slots must be ordered and cannot overlap")
+		}
+		var category POI.PlaceCategory
+		switch strings.ToLower(string(slotTemplate.Category)) {
+		case "visit":
+			category = POI.PlaceCategoryVisit
+		case "eatery":
+			category = POI.PlaceCategoryEatery
+		default:
+			return nil, newValidationError(fmt.Sprintf("slots[%d].category", idx), "invalid place category %s", slotTemplate.Category)
+		}
+		slotRequests[idx] = solution.SlotRequest{
+			TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: slotTemplate.Start, End: slotTemplate.End}},
+			Category: category,
+		}
+	}
+	return slotRequests, nil
+}
+
+// RequestValidationError describes an invalid field of a planning request
+// it is returned to users as the body of a 400 response
+type RequestValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (err RequestValidationError) Error() string {
+	return err.Field + ": " + err.Message
+}
+
+func newValidationError(field string, format string, args ...interface{}) RequestValidationError {
+	return RequestValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// toErrorBody builds a JSON body with the invalid field if the error comes from request validation
+func toErrorBody(err error) gin.H {
+	var validationErr RequestValidationError
+	if errors.As(err, &validationErr) {
+		return gin.H{"error": validationErr.Message, "field": validationErr.Field}
+	}
+	return gin.H{"error": err.Error()}
+}
+
 func processPlanningPostRequest(req *PlanningPostRequest) (planningRequest solution.PlanningRequest, err error) {
-	if req.Weekday > POI.DateSunday || req.Weekday < POI.DateMonday {
-		err = errors.New("invalid weekday in the request")
+	if strings.TrimSpace(req.City) == "" || strings.TrimSpace(req.Country) == "" {
+		err = newValidationError("city", "city and country are required")
 		return
 	}
+	planningRequest.Location = req.City + "," + req.Country
 
+	if req.Weekday > POI.DateSunday || req.Weekday < POI.DateMonday {
+		err = newValidationError("weekday", "invalid weekday in the request")
+		return
+	}
 	planningRequest.Weekday = req.Weekday
-	planningRequest.SearchRadius = 10000
+
+	if req.NumPlans < 0 {
+		err = newValidationError("num_plans", "number of plans cannot be negative")
+		return
+	}
+	planningRequest.NumPlans = req.NumPlans
+
+	planningRequest.SearchRadius = req.Radius
+	if planningRequest.SearchRadius == 0 {
+		planningRequest.SearchRadius = DefaultSearchRadius
+	}
+
+	switch travelMode := iowrappers.TravelMode(strings.ToLower(req.TravelMode)); travelMode {
+	case "", iowrappers.TravelModeWalking, iowrappers.TravelModeDriving, iowrappers.TravelModeTransit:
+		planningRequest.TravelMode = travelMode
+	default:
+		err = newValidationError("travel_mode", "invalid travel mode %s", req.TravelMode)
+		return
+	}
+
+	planningRequest.OptimizeRoute = req.OptimizeRoute
+
+	if req.ScoreWeights != nil {
+		if err = req.ScoreWeights.Validate(); err != nil {
+			err = newValidationError("score_weights", "%s", err.Error())
+			return
+		}
+		planningRequest.ScoreWeights = req.ScoreWeights
+	}
+
+	if math.IsNaN(req.Diversity) || req.Diversity < 0 || req.Diversity > solution.MaxDiversity {
+		err = newValidationError("diversity", "diversity must be between 0 and %.0f", solution.MaxDiversity)
+		return
+	}
+	planningRequest.Diversity = req.Diversity
+
+	// explicit slots take precedence over generated slots
+	if len(req.Slots) > 0 {
+		if planningRequest.Slots, err = toSlotRequests(req.Slots); err != nil {
+			return
+		}
+		planningRequest.Constraints, err = toPlaceConstraints(req, len(planningRequest.Slots))
+		return
+	}
+
 	// basic POST parameter validations
 	if req.StartTime == 0 || req.EndTime == 0 {
 		req.StartTime = 9
@@ -34,134 +238,280 @@ func processPlanningPostRequest(req *PlanningPostRequest) (planningRequest solut
 		return
 	}
 
-	planningRequest.Slots = nil
+	planningRequest.Slots = GenSlotRequests(*req)
+	planningRequest.Constraints, err = toPlaceConstraints(req, len(planningRequest.Slots))
 	return
 }
 
-//func GenSlotRequests(req PlanningPostRequest) []solution.SlotRequest {
-//	// grouping
-//	numGroups := uint(1)
-//	numVisit, numEatery := req.NumVisit, req.NumEatery
-//	if req.NumEatery > req.NumVisit {
-//		numGroups = req.NumEatery
-//	} else {
-//		numGroups = req.NumVisit
-//	}
-//	groups := make([][]string, numGroups)
-//	for idx := range groups {
-//		groups[idx] = make([]string, 0)
-//	}
-//	// construct groups, make sure eatery appear before visit locations
-//	// depends on the location type ratio, some groups might only has 1 location
-//	if req.NumVisit > req.NumEatery {
-//		ratio := int(req.NumVisit / req.NumEatery)
-//		for idx := range groups {
-//			groups[idx] = append(groups[idx], "V")
-//			if idx%ratio == 0 && numEatery > 0 {
-//				groups[idx] = append([]string{"E"}, groups[idx]...)
-//				numEatery--
-//			}
-//		}
-//	} else {
-//		ratio := int(req.NumEatery / req.NumVisit)
-//		for idx := range groups {
-//			groups[idx] = append(groups[idx], "E")
-//			if idx%ratio == 0 && numVisit > 0 {
-//				groups[idx] = append(groups[idx], "V")
-//				numVisit--
-//			}
-//		}
-//	}
-//
-//	// time allocation
-//	numHours := int(req.EndTime - req.StartTime)
-//	hours := make([]int, numGroups)
-//
-//	for idx := range hours {
-//		hours[idx] = len(groups[idx])
-//		numHours -= hours[idx]
-//	}
-//
-//	groupIdx := 0
-//	for numHours > 0 {
-//		hours[groupIdx] += 1
-//		groupIdx++
-//		numHours--
-//		if groupIdx == len(groups) {
-//			groupIdx = 0
-//		}
-//	}
-//
-//	slotRequests := make([]solution.SlotRequest, numGroups)
-//	cityCountry := req.City + "," + req.Country
-//
-//	curTime := req.StartTime
-//
-//	for groupIdx := range slotRequests {
-//		slotRequests[groupIdx].Location = cityCountry
-//		slotRequests[groupIdx].EvOption = strings.Join(groups[groupIdx], "")
-//		slotRequests[groupIdx].StayTimes = make([]matching.TimeSlot, len(groups[groupIdx]))
-//		allocatedTime := hours[groupIdx]
-//		for placeIdx, placeType := range groups[groupIdx] {
-//			curSlot := matching.TimeSlot{}
-//			if placeType == "E" {
-//				curSlot.Slot.Start = curTime
-//				curSlot.Slot.End = curTime + 1
-//				allocatedTime -= 1
-//				curTime += 1
-//			} else {
-//				curSlot.Slot.Start = curTime
-//				curTime += POI.Hour(allocatedTime)
-//				curSlot.Slot.End = curTime
-//			}
-//			slotRequests[groupIdx].StayTimes[placeIdx] = curSlot
-//		}
-//	}
-//
-//	groupIdx = 0
-//	curGroupIdx := 1
-//	excludedGroupIndexes := make(map[int]bool)
-//
-//	// combine groups and limit maximum number of groups
-//	for numGroups > 3 && curGroupIdx < len(slotRequests) {
-//		if len(slotRequests[groupIdx].EvOption)+len(slotRequests[curGroupIdx].EvOption) <= MaxPlacesPerSlot {
-//			slotRequests[groupIdx].EvOption = slotRequests[groupIdx].EvOption + slotRequests[curGroupIdx].EvOption
-//			slotRequests[groupIdx].StayTimes = append(slotRequests[groupIdx].StayTimes, slotRequests[curGroupIdx].StayTimes...)
-//			excludedGroupIndexes[curGroupIdx] = true
-//			curGroupIdx++
-//			numGroups--
-//		} else {
-//			groupIdx = curGroupIdx
-//			curGroupIdx++
-//		}
-//	}
-//
-//	finalRes := make([]solution.SlotRequest, 0)
-//	for idx, slotReq := range slotRequests {
-//		if _, exist := excludedGroupIndexes[idx]; !exist {
-//			finalRes = append(finalRes, slotReq)
-//		}
-//	}
-//	return finalRes
-//}
+// toPlaceConstraints validates pinned and excluded places of a request with the given number of slots
+func toPlaceConstraints(req *PlanningPostRequest, numSlots int) (constraints solution.PlaceConstraints, err error) {
+	excludedPlaces := make(map[string]bool)
+	for idx, placeId := range req.ExcludedPlaceIds {
+		if strings.TrimSpace(placeId) == "" {
+			err = newValidationError(fmt.Sprintf("excluded_place_ids[%d]", idx), "place ID cannot be empty")
+			return
+		}
+		excludedPlaces[placeId] = true
+		constraints.ExcludedPlaceIds = append(constraints.ExcludedPlaceIds, placeId)
+	}
+
+	locationTypes := append(POI.GetPlaceTypes(POI.PlaceCategoryVisit), POI.GetPlaceTypes(POI.PlaceCategoryEatery)...)
+	for idx, excludedType := range req.ExcludedLocationTypes {
+		locationType := POI.LocationType(strings.ToLower(string(excludedType)))
+		valid := false
+		for _, knownType := range locationTypes {
+			valid = valid || knownType == locationType
+		}
+		if !valid {
+			err = newValidationError(fmt.Sprintf("excluded_location_types[%d]", idx), "invalid location type %s", excludedType)
+			return
+		}
+		constraints.ExcludedLocationTypes = append(constraints.ExcludedLocationTypes, locationType)
+	}
+
+	if len(req.PinnedPlaces) > numSlots {
+		err = newValidationError("pinned_places", "number of pinned places cannot exceed number of slots %d", numSlots)
+		return
+	}
+	pinnedPlaces := make(map[string]bool)
+	pinnedSlots := make(map[int]bool)
+	for idx, pinnedPlace := range req.PinnedPlaces {
+		field := fmt.Sprintf("pinned_places[%d]", idx)
+		switch {
+		case strings.TrimSpace(pinnedPlace.PlaceId) == "":
+			err = newValidationError(field, "place ID cannot be empty")
+		case pinnedPlaces[pinnedPlace.PlaceId]:
+			err = newValidationError(field, "place %s is pinned more than once", pinnedPlace.PlaceId)
+		case excludedPlaces[pinnedPlace.PlaceId]:
+			err = newValidationError(field, "place %s is both pinned and excluded", pinnedPlace.PlaceId)
+		}
+		if err != nil {
+			return
+		}
+		pinnedPlaces[pinnedPlace.PlaceId] = true
+
+		slot := solution.AnySlot
+		if pinnedPlace.Slot != nil {
+			slot = *pinnedPlace.Slot
+			if slot < 0 || slot >= numSlots {
+				err = newValidationError(field+".slot", "slot must be between 0 and %d", numSlots-1)
+				return
+			}
+			if pinnedSlots[slot] {
+				err = newValidationError(field+".slot", "more than one place is pinned to slot %d", slot)
+				return
+			}
+			pinnedSlots[slot] = true
+		}
+		constraints.PinnedPlaces = append(constraints.PinnedPlaces, solution.PinnedPlace{PlaceId: pinnedPlace.PlaceId, Slot: slot})
+	}
+	return
+}
+
+func processBudgetPlanningPostRequest(req *PlanningPostRequest) (planningRequest solution.BudgetPlanningRequest, err error) {
+	if strings.TrimSpace(req.City) == "" || strings.TrimSpace(req.Country) == "" {
+		err = newValidationError("city", "city and country are required")
+		return
+	}
+	planningRequest.Location = req.City + "," + req.Country
+
+	if req.Weekday > POI.DateSunday || req.Weekday < POI.DateMonday {
+		err = newValidationError("weekday", "invalid weekday in the request")
+		return
+	}
+	planningRequest.Weekday = req.Weekday
+
+	if req.Budget == 0 {
+		err = newValidationError("budget", "budget must be positive in the budget mode")
+		return
+	}
+	planningRequest.Budget = req.Budget
+
+	planningRequest.SearchRadius = req.Radius
+	if planningRequest.SearchRadius == 0 {
+		planningRequest.SearchRadius = DefaultSearchRadius
+	}
+
+	if req.StartTime == 0 || req.EndTime == 0 {
+		req.StartTime = 9
+		req.EndTime = 22
+	}
+	if req.StartTime > 24 || req.EndTime > 24 {
+		err = newValidationError("start_time", "invalid time, valid times are chosen from 1-24")
+		return
+	}
+	if req.StartTime >= req.EndTime {
+		err = newValidationError("end_time", "start time cannot be later than end time")
+		return
+	}
+	planningRequest.TimeWindow = POI.TimeInterval{Start: req.StartTime, End: req.EndTime}
+	return
+}
+
+// processRouteOptimizationRequest validates stops and converts them to locations in the [lng, lat] order
+func processRouteOptimizationRequest(req *RouteOptimizationRequest) (locations [][2]float64, categories []POI.PlaceCategory, err error) {
+	if len(req.Stops) < 2 || len(req.Stops) > MaxRouteStops {
+		err = newValidationError("stops", "number of stops must be between 2 and %d", MaxRouteStops)
+		return
+	}
+	locations = make([][2]float64, len(req.Stops))
+	categories = make([]POI.PlaceCategory, len(req.Stops))
+	for idx, stop := range req.Stops {
+		if stop.Lat < -90 || stop.Lat > 90 || stop.Lng < -180 || stop.Lng > 180 {
+			err = newValidationError(fmt.Sprintf("stops[%d]", idx), "invalid location %f,%f", stop.Lat, stop.Lng)
+			return
+		}
+		locations[idx] = [2]float64{stop.Lng, stop.Lat}
+		switch strings.ToLower(string(stop.Category)) {
+		case "", "visit":
+			categories[idx] = POI.PlaceCategoryVisit
+		case "eatery":
+			categories[idx] = POI.PlaceCategoryEatery
+		default:
+			err = newValidationError(fmt.Sprintf("stops[%d].category", idx), "invalid place category %s", stop.Category)
+			return
+		}
+	}
+	return
+}
+
+// GenSlotRequests divides the time between start and end time of a request into slots
+// places are grouped so that each group has at most one eatery and eateries appear before visit locations in a group
+// each place stays at least one hour, and the rest of the time is shared by the visit locations
+func GenSlotRequests(req PlanningPostRequest) []solution.SlotRequest {
+	// grouping
+	numGroups := req.NumVisit
+	if req.NumEatery > req.NumVisit {
+		numGroups = req.NumEatery
+	}
+	numVisit, numEatery := req.NumVisit, req.NumEatery
+	groups := make([][]POI.PlaceCategory, numGroups)
+	// depends on the location type ratio, some groups might only has 1 location
+	if req.NumVisit > req.NumEatery {
+		ratio := int(req.NumVisit / utils.MaxUint(req.NumEatery, 1))
+		for idx := range groups {
+			if idx%ratio == 0 && numEatery > 0 {
+				groups[idx] = append(groups[idx], POI.PlaceCategoryEatery)
+				numEatery--
+			}
+			groups[idx] = append(groups[idx], POI.PlaceCategoryVisit)
+		}
+	} else {
+		ratio := int(req.NumEatery / utils.MaxUint(req.NumVisit, 1))
+		for idx := range groups {
+			groups[idx] = append(groups[idx], POI.PlaceCategoryEatery)
+			if idx%ratio == 0 && numVisit > 0 {
+				groups[idx] = append(groups[idx], POI.PlaceCategoryVisit)
+				numVisit--
+			}
+		}
+	}
+
+	categories := make([]POI.PlaceCategory, 0)
+	for _, group := range groups {
+		categories = append(categories, group...)
+	}
+
+	// time allocation
+	hours := make([]POI.Hour, len(categories))
+	visitIndexes := make([]int, 0)
+	for idx, category := range categories {
+		hours[idx] = 1
+		if category == POI.PlaceCategoryVisit {
+			visitIndexes = append(visitIndexes, idx)
+		}
+	}
+	if len(visitIndexes) > 0 {
+		for extraHours := int(req.EndTime-req.StartTime) - len(categories); extraHours > 0; extraHours-- {
+			hours[visitIndexes[0]]++
+			visitIndexes = append(visitIndexes[1:], visitIndexes[0])
+		}
+	}
+
+	slotRequests := make([]solution.SlotRequest, len(categories))
+	curTime := req.StartTime
+	for idx, category := range categories {
+		slotRequests[idx] = solution.SlotRequest{
+			TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: curTime, End: curTime + hours[idx]}},
+			Category: category,
+		}
+		curTime += hours[idx]
+	}
+	return slotRequests
+}
 
 func checkPostReqTimePlaceNum(req *PlanningPostRequest) (err error) {
 	if req.StartTime > 24 || req.EndTime > 24 {
-		err = errors.New("invalid time, valid times are chosen from 1-24")
+		err = newValidationError("start_time", "invalid time, valid times are chosen from 1-24")
 		return
 	}
 	if req.StartTime >= req.EndTime {
-		err = errors.New("start time cannot be later than end time")
+		err = newValidationError("end_time", "start time cannot be later than end time")
 		return
 	}
 
 	if req.NumEatery+req.NumVisit > MaxPlacesPerDay {
-		err = fmt.Errorf("total number of places cannot exceed %d", MaxPlacesPerDay)
+		err = newValidationError("num_visit", "total number of places cannot exceed %d", MaxPlacesPerDay)
 		return
 	}
 
 	if req.NumEatery+req.NumVisit > uint(req.EndTime-req.StartTime) {
-		err = errors.New("not enough time for visiting all the places")
+		err = newValidationError("num_visit", "not enough time for visiting all the places")
+	}
+	return
+}
+
+func processSlotAlternativesPostRequest(req *SlotAlternativesPostRequest) (alternativesRequest solution.SlotAlternativesRequest, err error) {
+	if strings.TrimSpace(req.City) == "" || strings.TrimSpace(req.Country) == "" {
+		err = newValidationError("city", "city and country are required")
+		return
+	}
+	alternativesRequest.Location = req.City + "," + req.Country
+
+	if req.Weekday > POI.DateSunday || req.Weekday < POI.DateMonday {
+		err = newValidationError("weekday", "invalid weekday in the request")
+		return
+	}
+	alternativesRequest.Weekday = req.Weekday
+
+	alternativesRequest.SearchRadius = req.Radius
+	if alternativesRequest.SearchRadius == 0 {
+		alternativesRequest.SearchRadius = DefaultSearchRadius
+	}
+
+	if alternativesRequest.Slots, err = toSlotRequests(req.Slots); err != nil {
+		return
+	}
+	if len(req.PlaceIds) != len(req.Slots) {
+		err = newValidationError("place_ids", "a plan must have one place for each of the %d slots", len(req.Slots))
+		return
+	}
+	for idx, placeId := range req.PlaceIds {
+		if strings.TrimSpace(placeId) == "" {
+			err = newValidationError(fmt.Sprintf("place_ids[%d]", idx), "place ID cannot be empty")
+			return
+		}
+	}
+	alternativesRequest.PlaceIds = req.PlaceIds
+
+	if req.Slot < 0 || req.Slot >= len(req.Slots) {
+		err = newValidationError("slot", "slot must be between 0 and %d", len(req.Slots)-1)
+		return
+	}
+	alternativesRequest.SlotIndex = req.Slot
+
+	if req.NumAlternatives < 0 || req.NumAlternatives > MaxSlotAlternatives {
+		err = newValidationError("num_alternatives", "number of alternatives must be between 0 and %d", MaxSlotAlternatives)
+		return
+	}
+	alternativesRequest.NumAlternatives = req.NumAlternatives
+
+	if req.ScoreWeights != nil {
+		if err = req.ScoreWeights.Validate(); err != nil {
+			err = newValidationError("score_weights", "%s", err.Error())
+			return
+		}
+		alternativesRequest.ScoreWeights = req.ScoreWeights
 	}
 	return
 }
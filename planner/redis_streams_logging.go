@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"context"
 	log "github.com/sirupsen/logrus"
 	"github.com/weihesdlegend/Vacation-planner/iowrappers"
 	"strings"
@@ -24,3 +25,12 @@ func (planner MyPlanner) ProcessPlanningEvent(worker int, wg *sync.WaitGroup) {
 	}
 	wg.Done()
 }
+
+// RefreshPlaces refreshes the stalest and most-used cached places until the context is done
+func (planner MyPlanner) RefreshPlaces(context context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if planner.PlaceRefresher == nil {
+		return
+	}
+	planner.PlaceRefresher.Run(context)
+}
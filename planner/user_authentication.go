@@ -2,10 +2,12 @@ package planner
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	"github.com/weihesdlegend/Vacation-planner/matching"
 	"github.com/weihesdlegend/Vacation-planner/user"
 	"net/http"
 	"os"
@@ -130,3 +132,48 @@ func (planner MyPlanner) UserAuthentication(context context.Context, r *http.Req
 	}
 	return username, nil
 }
+
+// UserScoreWeights handles PUT requests saving the weights for scoring plans to the profile of the current user
+// the weights are used by planning requests of the user without weights
+// weights absent in the request keep their values in the profile, or the default values
+func (planner MyPlanner) UserScoreWeights(context *gin.Context) {
+	username, authenticationErr := planner.UserAuthentication(context, context.Request, user.LevelRegular)
+	if authenticationErr != nil {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": authenticationErr.Error()})
+		return
+	}
+
+	weights := matching.DefaultScoreWeights
+	if savedWeights := planner.userScoreWeights(context, username); savedWeights != nil {
+		weights = *savedWeights
+	}
+	if decodeErr := context.ShouldBindJSON(&weights); decodeErr != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": decodeErr.Error()})
+		return
+	}
+	if validationErr := weights.Validate(); validationErr != nil {
+		context.JSON(http.StatusBadRequest, toErrorBody(newValidationError("score_weights", "%s", validationErr.Error())))
+		return
+	}
+
+	weightsJSON, _ := json.Marshal(weights)
+	if updateErr := planner.RedisClient.SetUserScoreWeights(context, username, string(weightsJSON)); updateErr != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": updateErr.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, weights)
+}
+
+// userScoreWeights returns the weights in the profile of a user, or nil for the default weights
+func (planner MyPlanner) userScoreWeights(context context.Context, username string) *matching.ScoreWeights {
+	userFound, findUserErr := planner.RedisClient.FindUser(context, username)
+	if findUserErr != nil || userFound.ScoreWeights == "" {
+		return nil
+	}
+	weights := &matching.ScoreWeights{}
+	if err := json.Unmarshal([]byte(userFound.ScoreWeights), weights); err != nil || weights.Validate() != nil {
+		log.Errorf("invalid score weights of user %s: %s", username, userFound.ScoreWeights)
+		return nil
+	}
+	return weights
+}
@@ -0,0 +1,158 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	// BudgetPlanningMinResults is the minimum number of places of each category considered by the budget planner
+	BudgetPlanningMinResults = 20
+)
+
+// BudgetPlanningRequest plans a single day within a time window and a budget
+// instead of filling fixed slots, the planner decides how many and which places to visit
+type BudgetPlanningRequest struct {
+	Location     string // city,country
+	Weekday      POI.Weekday
+	TimeWindow   POI.TimeInterval
+	Budget       uint
+	SearchRadius uint
+}
+
+// ScheduledPlace is a place visited during a time interval of a budget plan
+type ScheduledPlace struct {
+	Place        matching.Place
+	TimeInterval POI.TimeInterval
+}
+
+type BudgetPlanningResponse struct {
+	Places    []ScheduledPlace // in visiting order
+	TotalCost uint
+	TotalTime POI.Hour
+	Score     float64
+	Err       error
+	ErrorCode uint
+}
+
+// SolveWithBudget selects places for a day with the budget-aware knapsack
+// the knapsack maximizes the total score of places so that it visits as many good places as time and budget allow
+// places are visited one after another from the start of the time window without idle time, with meals between visits
+func (solver *Solver) SolveWithBudget(context context.Context, req *BudgetPlanningRequest, resp *BudgetPlanningResponse) {
+	if req.TimeWindow.Start >= req.TimeWindow.End || req.TimeWindow.End > 24 {
+		resp.Err = fmt.Errorf("invalid time window %d-%d", req.TimeWindow.Start, req.TimeWindow.End)
+		resp.ErrorCode = ReqTagInvalid
+		return
+	}
+	if req.Budget == 0 {
+		resp.Err = errors.New("budget must be positive")
+		resp.ErrorCode = ReqTagInvalid
+		return
+	}
+
+	if !solver.ValidateLocation(context, &req.Location) {
+		resp.Err = errors.New("invalid travel destination")
+		resp.ErrorCode = InvalidRequestLocation
+		return
+	}
+
+	places := make([]matching.Place, 0)
+	for _, placeCat := range []POI.PlaceCategory{POI.PlaceCategoryEatery, POI.PlaceCategoryVisit} {
+		searchResults, err := solver.Matcher.PoiSearcher.NearbySearch(context, &iowrappers.PlaceSearchRequest{
+			Location:      req.Location,
+			PlaceCat:      placeCat,
+			Radius:        req.SearchRadius,
+			MinNumResults: BudgetPlanningMinResults,
+		})
+		// places of partial searches are still planned
+		if err != nil {
+			iowrappers.Logger.Error(err)
+		}
+		for _, place := range searchResults {
+			places = append(places, matching.CreatePlace(place, placeCat))
+		}
+	}
+
+	interval := matching.QueryTimeInterval{
+		Day:       req.Weekday,
+		StartHour: uint8(req.TimeWindow.Start),
+		EndHour:   uint8(req.TimeWindow.End),
+	}
+	selectedPlaces, totalCost, totalTime := matching.KnapsackWithObjective(places, interval, req.Budget, matching.TotalScore)
+	if len(selectedPlaces) == 0 {
+		resp.Err = errors.New("cannot find a valid solution")
+		resp.ErrorCode = NoValidSolution
+		return
+	}
+
+	resp.Places = ScheduleWithMeals(selectedPlaces, req.Weekday, req.TimeWindow.Start)
+	resp.TotalCost = totalCost
+	resp.TotalTime = POI.Hour(totalTime)
+	orderedPlaces := make([]matching.Place, len(resp.Places))
+	for idx, scheduledPlace := range resp.Places {
+		orderedPlaces[idx] = scheduledPlace.Place
+	}
+	resp.Score = matching.TotalScore(orderedPlaces)
+}
+
+// IsOpen returns true if the place is open during its entire time interval on the given day
+func (scheduledPlace ScheduledPlace) IsOpen(day POI.Weekday) bool {
+	return scheduledPlace.Place.GetOpeningHours().IsOpenDuring(day, scheduledPlace.TimeInterval.ToMinuteInterval())
+}
+
+// ScheduleWithMeals schedules places selected by the knapsack with meals between visits
+// if interleaving meals moves a place out of its opening hours, places are visited in the order of selection,
+// in which the knapsack checked their opening hours
+func ScheduleWithMeals(places []matching.Place, day POI.Weekday, startHour POI.Hour) []ScheduledPlace {
+	scheduledPlaces := ScheduleInOrder(InterleaveMeals(places), startHour)
+	for _, scheduledPlace := range scheduledPlaces {
+		if !scheduledPlace.IsOpen(day) {
+			return ScheduleInOrder(places, startHour)
+		}
+	}
+	return scheduledPlaces
+}
+
+// InterleaveMeals orders places by the pattern of generated slots, alternating eateries and visits from an eatery,
+// the places left of either category follow at the end
+// places of the same category keep their order
+func InterleaveMeals(places []matching.Place) []matching.Place {
+	eateries := make([]matching.Place, 0)
+	visits := make([]matching.Place, 0)
+	for _, place := range places {
+		if place.GetPlaceCategory() == POI.PlaceCategoryEatery {
+			eateries = append(eateries, place)
+		} else {
+			visits = append(visits, place)
+		}
+	}
+
+	orderedPlaces := make([]matching.Place, 0, len(places))
+	for len(eateries) > 0 && len(visits) > 0 {
+		orderedPlaces = append(orderedPlaces, eateries[0], visits[0])
+		eateries, visits = eateries[1:], visits[1:]
+	}
+	orderedPlaces = append(orderedPlaces, eateries...)
+	return append(orderedPlaces, visits...)
+}
+
+// ScheduleInOrder assigns consecutive time intervals to places from the start hour
+// the staying time of each place is decided by its location type
+func ScheduleInOrder(places []matching.Place, startHour POI.Hour) []ScheduledPlace {
+	scheduledPlaces := make([]ScheduledPlace, len(places))
+	currentHour := startHour
+	for idx, place := range places {
+		stayTime := POI.Hour(POI.GetStayingTimeForLocationType(place.GetPlaceType()))
+		scheduledPlaces[idx] = ScheduledPlace{
+			Place:        place,
+			TimeInterval: POI.TimeInterval{Start: currentHour, End: currentHour + stayTime},
+		}
+		currentHour += stayTime
+	}
+	return scheduledPlaces
+}
@@ -26,3 +26,11 @@ func Categorize(cluster matching.TimePlacesCluster) CategorizedPlaces {
 
 	return res
 }
+
+// PlacesOf returns the places of a category
+func (categorizedPlaces CategorizedPlaces) PlacesOf(category POI.PlaceCategory) []matching.Place {
+	if category == POI.PlaceCategoryEatery {
+		return categorizedPlaces.EateryPlaces
+	}
+	return categorizedPlaces.VisitPlaces
+}
@@ -8,17 +8,22 @@ import (
 )
 
 type PlanningSolution struct {
-	PlaceNames      []string       `json:"place_names"`
-	PlaceIDS        []string       `json:"place_ids"`
-	PlaceLocations  [][2]float64   `json:"place_locations"` // lat,lng
-	PlaceAddresses  []string       `json:"place_addresses"`
-	PlaceURLs       []string       `json:"place_urls"`
-	Score           float64        `json:"score"`
-	IsSet           bool           `json:"is_set"`
+	PlaceNames     []string                `json:"place_names"`
+	PlaceIDS       []string                `json:"place_ids"`
+	PlaceLocations [][2]float64            `json:"place_locations"` // lng,lat
+	PlaceAddresses []string                `json:"place_addresses"`
+	PlaceURLs      []string                `json:"place_urls"`
+	PlaceTypes     []POI.LocationType      `json:"place_types"`
+	Score          float64                 `json:"score"`
+	ScoreBreakdown matching.ScoreBreakdown `json:"score_breakdown"`
+	IsSet          bool                    `json:"is_set"`
+	Schedule       []ScheduledVisit        `json:"schedule,omitempty"` // visits with transit between places
 }
 
-
-func CreateCandidate(slotCategories []POI.PlaceCategory, iter MultiDimIterator, categorizedPlaces []CategorizedPlaces) (res PlanningSolution) {
+// CreateCandidate creates the plan of the places that the iterator points to in each slot
+// the plan is not set if it repeats a place or violates the place constraints, which can be nil
+func CreateCandidate(slotCategories []POI.PlaceCategory, iter MultiDimIterator, categorizedPlaces []CategorizedPlaces,
+	constraints *PlaceConstraints, scorer matching.Scorer) (res PlanningSolution) {
 	if len(iter.Status) != len(slotCategories) {
 		return
 	}
@@ -52,8 +57,13 @@ func CreateCandidate(slotCategories []POI.PlaceCategory, iter MultiDimIterator,
 			place.SetURL(iowrappers.GoogleSearchHomePageURL)
 		}
 		res.PlaceURLs = append(res.PlaceURLs, place.GetURL())
+		res.PlaceTypes = append(res.PlaceTypes, place.GetPlaceType())
+	}
+	if !constraints.SatisfiedBy(places) {
+		return PlanningSolution{}
 	}
-	res.Score = matching.Score(places)
+	res.ScoreBreakdown = scorer.Breakdown(places)
+	res.Score = res.ScoreBreakdown.Total
 	res.IsSet = true
 	return
 }
@@ -67,43 +67,111 @@ func generateCategorizedPlaces(context context.Context, timeMatcher *matching.Ti
 
 	// place clusters are clustered by time slot
 	// now cluster by place category
+	// places of slots are cached for replacing places of generated plans
+	redisClient := timeMatcher.PoiSearcher.GetRedisClient()
 	for idx, timePlaceCluster := range timePlaceClusters {
 		categorizedPlaces[idx] = Categorize(timePlaceCluster)
+		cacheCategorizedPlaces(context, redisClient, location, radius, weekday, timePlaceCluster.Slot.Slot, categorizedPlaces[idx])
 	}
 	return categorizedPlaces, GetTimeSlotLengthInMin(timePlaceClusters)
 }
 
 // GenerateSolutions generates multi-slot solutions and cache them
-func GenerateSolutions(context context.Context, timeMatcher *matching.TimeMatcher, redisClient iowrappers.RedisClient, redisReq iowrappers.SlotSolutionCacheRequest, request PlanningRequest) (solutions []PlanningSolution, slotSolutionRedisKey string, err error) {
-	solutions = make([]PlanningSolution, 0)
+// solutions are scheduled with transit by the scheduler, which can be nil, and those whose transit does not fit are not generated
+func GenerateSolutions(context context.Context, timeMatcher *matching.TimeMatcher, redisClient iowrappers.RedisClient, redisReq iowrappers.SlotSolutionCacheRequest, request PlanningRequest, scheduler TransitScheduler) (solutions []PlanningSolution, slotSolutionRedisKey string, err error) {
+	bestCandidates, err := findTopSolutions(context, timeMatcher, request, request.NumPlans, scheduler)
+	if err != nil {
+		return
+	}
+	solutions = bestCandidates
+
+	// cache slot solution calculation results
+	redisClient.CacheSlotSolution(context, redisReq, toSlotSolutionCache(bestCandidates))
+
+	return
+}
 
+// findTopSolutions searches the candidates of a single-day request and keeps the best numSolutions of them
+// with a scheduler, candidates whose transit does not fit are skipped, and more candidates are drawn
+// until there are numSolutions plans left or no more candidates
+func findTopSolutions(context context.Context, timeMatcher *matching.TimeMatcher, request PlanningRequest, numSolutions int64, scheduler TransitScheduler) (solutions []PlanningSolution, err error) {
 	categorizedPlaces, _ := generateCategorizedPlaces(context, timeMatcher, request.Location, request.SearchRadius, request.Weekday, ToTimeSlots(request.Slots))
 
 	placeCategories := ToSlotCategories(request.Slots)
+	// constraints are applied once for both the search and route optimization,
+	// which must not move places to slots they are excluded from
+	if categorizedPlaces, err = request.Constraints.Apply(placeCategories, categorizedPlaces); err != nil {
+		return
+	}
+	scorer := request.scorer()
+	// diversified plans are selected from more candidates
+	numCandidates := numSolutions
+	if request.Diversity > 0 {
+		numCandidates = numSolutions * DiversityCandidatesFactor
+	}
+	// plans rejected by the scheduler do not count, so that diversified plans are still selected from enough candidates
+	minSolutions := numCandidates
+	maxCandidates := numCandidates * maxTransitCandidatesFactor
+	for {
+		var candidates []PlanningSolution
+		if candidates, err = searchTopSolutions(placeCategories, categorizedPlaces, numCandidates, &request.Constraints, scorer); err != nil {
+			return
+		}
+		exhausted := int64(len(candidates)) < numCandidates
+		if request.OptimizeRoute {
+			candidates = optimizeRoutes(candidates, placeCategories, categorizedPlaces, numCandidates, scorer)
+		}
+		solutions = scheduleTransit(candidates, scheduler)
+		if int64(len(solutions)) >= minSolutions || exhausted || numCandidates >= maxCandidates {
+			break
+		}
+		numCandidates *= 2
+	}
+	solutions = SelectDiversePlans(solutions, numSolutions, request.Diversity)
+	return
+}
+
+// EnumerateTopSolutions scores every combination of places of the slots and keeps the best topSolutionsCount of them
+// plans that are permutations of each other are deduplicated by keeping the first one in the enumeration order
+// plans violating the place constraints, which can be nil, are skipped, and plans are scored with the default scorer if the scorer is nil
+func EnumerateTopSolutions(placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces, topSolutionsCount int64,
+	constraints *PlaceConstraints, scorer matching.Scorer) (solutions []PlanningSolution, err error) {
+	solutions = make([]PlanningSolution, 0)
+	if scorer == nil {
+		scorer = matching.DefaultScorer
+	}
+
+	if categorizedPlaces, err = constraints.Apply(placeCategories, categorizedPlaces); err != nil {
+		return
+	}
+
 	mdIter := MultiDimIterator{}
 	if err = mdIter.Init(placeCategories, categorizedPlaces); err != nil {
 		return
 	}
 
-	for mdIter.HasNext() {
-		curCandidate := CreateCandidate(placeCategories, mdIter, categorizedPlaces)
+	for {
+		curCandidate := CreateCandidate(placeCategories, mdIter, categorizedPlaces, constraints, scorer)
 
 		if curCandidate.IsSet {
 			solutions = append(solutions, curCandidate)
 		}
-		mdIter.Next()
+		if !mdIter.Next() {
+			break
+		}
 	}
 
 	solutions = TravelPlansDeduplication(solutions)
 
-	bestCandidates := FindBestPlanningSolutions(solutions, request.NumPlans)
-	solutions = bestCandidates
+	solutions = FindBestPlanningSolutions(solutions, topSolutionsCount)
+	return
+}
 
-	// cache slot solution calculation results
+func toSlotSolutionCache(candidates []PlanningSolution) iowrappers.SlotSolutionCacheResponse {
 	slotSolutionToCache := iowrappers.SlotSolutionCacheResponse{}
-	slotSolutionToCache.SlotSolutionCandidate = make([]iowrappers.SlotSolutionCandidateCache, len(bestCandidates))
+	slotSolutionToCache.SlotSolutionCandidate = make([]iowrappers.SlotSolutionCandidateCache, len(candidates))
 
-	for idx, slotSolutionCandidate := range bestCandidates {
+	for idx, slotSolutionCandidate := range candidates {
 		candidateCache := iowrappers.SlotSolutionCandidateCache{
 			PlaceIds:       slotSolutionCandidate.PlaceIDS,
 			Score:          slotSolutionCandidate.Score,
@@ -111,13 +179,26 @@ func GenerateSolutions(context context.Context, timeMatcher *matching.TimeMatche
 			PlaceLocations: slotSolutionCandidate.PlaceLocations,
 			PlaceAddresses: slotSolutionCandidate.PlaceAddresses,
 			PlaceURLs:      slotSolutionCandidate.PlaceURLs,
+			PlaceTypes:     slotSolutionCandidate.PlaceTypes,
+			ScoreBreakdown: iowrappers.ScoreBreakdownCache(slotSolutionCandidate.ScoreBreakdown),
 		}
 		slotSolutionToCache.SlotSolutionCandidate[idx] = candidateCache
 	}
+	return slotSolutionToCache
+}
 
-	redisClient.CacheSlotSolution(context, redisReq, slotSolutionToCache)
-
-	return
+func fromSlotSolutionCache(candidate iowrappers.SlotSolutionCandidateCache) PlanningSolution {
+	return PlanningSolution{
+		PlaceNames:     candidate.PlaceNames,
+		PlaceIDS:       candidate.PlaceIds,
+		PlaceLocations: candidate.PlaceLocations,
+		PlaceAddresses: candidate.PlaceAddresses,
+		PlaceURLs:      candidate.PlaceURLs,
+		PlaceTypes:     candidate.PlaceTypes,
+		Score:          candidate.Score,
+		ScoreBreakdown: matching.ScoreBreakdown(candidate.ScoreBreakdown),
+		IsSet:          true,
+	}
 }
 
 //TravelPlansDeduplication removes travel plans contain places that are permutations of each other
@@ -126,7 +207,9 @@ func TravelPlansDeduplication(travelPlans []PlanningSolution) []PlanningSolution
 	results := make([]PlanningSolution, 0)
 
 	for _, travelPlan := range travelPlans {
-		placeIds := travelPlan.PlaceIDS
+		// sort a copy so that place IDs still match other place details of the plan
+		placeIds := make([]string, len(travelPlan.PlaceIDS))
+		copy(placeIds, travelPlan.PlaceIDS)
 		radix.Sort(placeIds)
 		jointPlanIds := strings.Join(placeIds, "_")
 		if _, exists := duplicatedPlans[jointPlanIds]; !exists {
@@ -0,0 +1,125 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	MaxTripLegs = 5
+)
+
+// TripLegRequest describes the part of a trip spent in one city
+type TripLegRequest struct {
+	City         string
+	Country      string
+	NumDays      int
+	SearchRadius uint
+}
+
+// MultiCityPlanningRequest plans a trip made of ordered legs starting from StartDate
+// day i of the whole trip uses the slot template DaySlots[i % len(DaySlots)]
+type MultiCityPlanningRequest struct {
+	Legs         []TripLegRequest
+	StartDate    time.Time
+	DaySlots     [][]SlotRequest
+	NumPlans     int64
+	ScoreWeights *matching.ScoreWeights // default weights if nil
+}
+
+// TripLegBoundary locates a leg in the days of a combined trip
+type TripLegBoundary struct {
+	Location string `json:"location"` // city,country
+	StartDay int    `json:"start_day"`
+	NumDays  int    `json:"num_days"`
+}
+
+type MultiCityPlanningResponse struct {
+	Trips       []TripSolution
+	Legs        []TripLegBoundary
+	DayRequests []PlanningRequest
+	Err         error
+	ErrorCode   uint
+}
+
+func (solver *Solver) SolveMultiCity(context context.Context, redisCli iowrappers.RedisClient, req *MultiCityPlanningRequest, resp *MultiCityPlanningResponse) {
+	if len(req.Legs) == 0 || len(req.Legs) > MaxTripLegs || len(req.DaySlots) == 0 {
+		resp.Err = fmt.Errorf("a trip must have 1 to %d legs and at least one slot template", MaxTripLegs)
+		resp.ErrorCode = ReqTagInvalid
+		return
+	}
+
+	if req.NumPlans == 0 {
+		req.NumPlans = NumPlansDefault
+	}
+
+	// geocode all legs before planning any of them
+	legRequests := make([]MultiDayPlanningRequest, len(req.Legs))
+	startDay := 0
+	for idx, leg := range req.Legs {
+		location := strings.Join([]string{leg.City, leg.Country}, ",")
+		if !solver.ValidateLocation(context, &location) {
+			resp.Err = fmt.Errorf("invalid travel destination %s, %s", leg.City, leg.Country)
+			resp.ErrorCode = InvalidRequestLocation
+			return
+		}
+		if leg.NumDays <= 0 || leg.NumDays > MaxTripDays {
+			resp.Err = fmt.Errorf("number of days in %s must be between 1 and %d", leg.City, MaxTripDays)
+			resp.ErrorCode = ReqTagInvalid
+			return
+		}
+
+		legRequests[idx] = MultiDayPlanningRequest{
+			Location:     location,
+			StartDate:    req.StartDate.AddDate(0, 0, startDay),
+			NumDays:      leg.NumDays,
+			DaySlots:     rotateDaySlots(req.DaySlots, startDay),
+			NumPlans:     req.NumPlans * int64(len(req.Legs)),
+			SearchRadius: leg.SearchRadius,
+			ScoreWeights: req.ScoreWeights,
+		}
+		resp.Legs = append(resp.Legs, TripLegBoundary{
+			Location: location,
+			StartDay: startDay,
+			NumDays:  leg.NumDays,
+		})
+		startDay += leg.NumDays
+	}
+
+	// each leg is planned and cached as a multi-day trip in its own city
+	legTrips := make([][]TripSolution, len(legRequests))
+	for idx := range legRequests {
+		legResponse := MultiDayPlanningResponse{}
+		solver.SolveMultiDay(context, redisCli, &legRequests[idx], &legResponse)
+		if legResponse.Err != nil {
+			resp.Err = legResponse.Err
+			resp.ErrorCode = legResponse.ErrorCode
+			return
+		}
+		if len(legResponse.Trips) == 0 {
+			resp.Err = errors.New("cannot find a valid solution for " + legRequests[idx].Location)
+			resp.ErrorCode = NoValidSolution
+			return
+		}
+		legTrips[idx] = legResponse.Trips
+		resp.DayRequests = append(resp.DayRequests, legResponse.DayRequests...)
+	}
+
+	resp.Trips = CombineTrips(legTrips, req.NumPlans)
+}
+
+// rotateDaySlots shifts slot templates so that the first day of a leg starting at startDay
+// uses the same template as day startDay of the whole trip
+func rotateDaySlots(daySlots [][]SlotRequest, startDay int) [][]SlotRequest {
+	rotated := make([][]SlotRequest, len(daySlots))
+	for idx := range daySlots {
+		rotated[idx] = daySlots[(idx+startDay)%len(daySlots)]
+	}
+	return rotated
+}
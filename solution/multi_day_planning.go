@@ -0,0 +1,223 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	MaxTripDays = 7
+	// number of partial trips kept after planning each day is the number of requested trips times this factor
+	tripBeamWidthFactor = 4
+)
+
+// MultiDayPlanningRequest plans a trip of consecutive days in one city
+// day i of the trip uses the slot template DaySlots[i % len(DaySlots)]
+type MultiDayPlanningRequest struct {
+	Location     string // city,country
+	StartDate    time.Time
+	NumDays      int
+	DaySlots     [][]SlotRequest
+	NumPlans     int64
+	SearchRadius uint
+	ScoreWeights *matching.ScoreWeights // default weights if nil
+}
+
+// TripSolution contains one PlanningSolution for each day of a trip and no place appears twice in a trip
+type TripSolution struct {
+	Days  []PlanningSolution `json:"days"`
+	Score float64            `json:"score"`
+}
+
+type MultiDayPlanningResponse struct {
+	Trips       []TripSolution
+	DayRequests []PlanningRequest
+	Err         error
+	ErrorCode   uint
+}
+
+// DayRequests expands a multi-day request to single-day requests
+// the weekday of each day is derived from the start date
+func (req *MultiDayPlanningRequest) DayRequests() []PlanningRequest {
+	dayRequests := make([]PlanningRequest, req.NumDays)
+	if len(req.DaySlots) == 0 {
+		return dayRequests
+	}
+	for day := range dayRequests {
+		date := req.StartDate.AddDate(0, 0, day)
+		dayRequests[day] = PlanningRequest{
+			Location:     req.Location,
+			Slots:        req.DaySlots[day%len(req.DaySlots)],
+			Weekday:      POI.GetWeekday(date),
+			NumPlans:     req.NumPlans,
+			SearchRadius: req.SearchRadius,
+			ScoreWeights: req.ScoreWeights,
+		}
+	}
+	return dayRequests
+}
+
+func (solver *Solver) SolveMultiDay(context context.Context, redisCli iowrappers.RedisClient, req *MultiDayPlanningRequest, resp *MultiDayPlanningResponse) {
+	if req.NumDays <= 0 || req.NumDays > MaxTripDays || len(req.DaySlots) == 0 {
+		resp.Err = errors.New("invalid number of days or slot templates of the trip")
+		resp.ErrorCode = ReqTagInvalid
+		return
+	}
+
+	if !solver.ValidateLocation(context, &req.Location) {
+		resp.Err = errors.New("invalid travel destination")
+		resp.ErrorCode = InvalidRequestLocation
+		return
+	}
+
+	if req.NumPlans == 0 {
+		req.NumPlans = NumPlansDefault
+	}
+
+	resp.DayRequests = req.DayRequests()
+	cacheRequest := iowrappers.MultiDaySolutionCacheRequest{Days: make([]iowrappers.SlotSolutionCacheRequest, req.NumDays), NumPlans: req.NumPlans}
+	for day := range resp.DayRequests {
+		cacheRequest.Days[day] = toSlotSolutionRedisRequest(&resp.DayRequests[day])
+	}
+
+	cacheResponse := redisCli.GetMultiDaySolution(context, cacheRequest)
+	if cacheResponse.Err == nil {
+		iowrappers.Logger.Infof("Found multi-day solution in cache!")
+		for _, trip := range cacheResponse.Trips {
+			tripSolution := TripSolution{Days: make([]PlanningSolution, len(trip.Days)), Score: trip.Score}
+			for day, candidate := range trip.Days {
+				tripSolution.Days[day] = fromSlotSolutionCache(candidate)
+			}
+			resp.Trips = append(resp.Trips, tripSolution)
+		}
+		return
+	}
+
+	iowrappers.Logger.Infof("Multi-day solution cache miss!")
+	// keep more candidates than the requested number of trips for each day
+	// so that there are enough choices left after removing places repeating across days
+	numDaySolutions := req.NumPlans * int64(req.NumDays)
+	dailySolutions := make([][]PlanningSolution, req.NumDays)
+	for day := range resp.DayRequests {
+		solutions, err := findTopSolutions(context, solver.Matcher, resp.DayRequests[day], numDaySolutions, nil)
+		if err != nil {
+			resp.Err = err
+			if err.Error() == CategorizedPlaceIterInitFailureErrMsg {
+				resp.ErrorCode = CatPlaceIterInitFailure
+			} else {
+				resp.ErrorCode = ReqTagInvalid
+			}
+			return
+		}
+		dailySolutions[day] = solutions
+	}
+
+	resp.Trips = CombineDailySolutions(dailySolutions, req.NumPlans)
+	if len(resp.Trips) == 0 {
+		return
+	}
+
+	tripsToCache := iowrappers.MultiDaySolutionCacheResponse{Trips: make([]iowrappers.TripSolutionCache, len(resp.Trips))}
+	for idx, trip := range resp.Trips {
+		tripsToCache.Trips[idx] = iowrappers.TripSolutionCache{
+			Days:  toSlotSolutionCache(trip.Days).SlotSolutionCandidate,
+			Score: trip.Score,
+		}
+	}
+	redisCli.CacheMultiDaySolution(context, cacheRequest, tripsToCache)
+}
+
+// CombineDailySolutions assembles trips from the candidate solutions of each day with a beam search
+// trips containing the same place on different days are discarded, and trips are ranked by the sum of daily scores
+func CombineDailySolutions(dailySolutions [][]PlanningSolution, numTrips int64) []TripSolution {
+	stages := make([][]TripSolution, len(dailySolutions))
+	for day, daySolutions := range dailySolutions {
+		stages[day] = make([]TripSolution, len(daySolutions))
+		for idx, daySolution := range daySolutions {
+			stages[day][idx] = TripSolution{Days: []PlanningSolution{daySolution}, Score: daySolution.Score}
+		}
+	}
+	return CombineTrips(stages, numTrips)
+}
+
+// CombineTrips concatenates one partial trip from each stage in order to build complete trips
+func CombineTrips(stages [][]TripSolution, numTrips int64) []TripSolution {
+	if numTrips <= 0 {
+		numTrips = TopSolutionsCountDefault
+	}
+	beamWidth := int(numTrips) * tripBeamWidthFactor
+
+	type partialTrip struct {
+		trip     TripSolution
+		numStage int
+		placeIds map[string]bool
+	}
+
+	trips := []partialTrip{{placeIds: make(map[string]bool)}}
+	for _, stageTrips := range stages {
+		nextTrips := make([]partialTrip, 0)
+		for _, t := range trips {
+			for _, stageTrip := range stageTrips {
+				stagePlaceIds := tripPlaceIds(stageTrip)
+				if containsAnyPlace(t.placeIds, stagePlaceIds) {
+					continue
+				}
+				placeIds := make(map[string]bool, len(t.placeIds)+len(stagePlaceIds))
+				for placeId := range t.placeIds {
+					placeIds[placeId] = true
+				}
+				for _, placeId := range stagePlaceIds {
+					placeIds[placeId] = true
+				}
+				days := make([]PlanningSolution, len(t.trip.Days), len(t.trip.Days)+len(stageTrip.Days))
+				copy(days, t.trip.Days)
+				nextTrips = append(nextTrips, partialTrip{
+					trip:     TripSolution{Days: append(days, stageTrip.Days...), Score: t.trip.Score + stageTrip.Score},
+					numStage: t.numStage + 1,
+					placeIds: placeIds,
+				})
+			}
+		}
+		sort.SliceStable(nextTrips, func(i, j int) bool {
+			return nextTrips[i].trip.Score > nextTrips[j].trip.Score
+		})
+		if len(nextTrips) > beamWidth {
+			nextTrips = nextTrips[:beamWidth]
+		}
+		trips = nextTrips
+	}
+
+	results := make([]TripSolution, 0)
+	for _, t := range trips {
+		if int64(len(results)) == numTrips {
+			break
+		}
+		if t.numStage == len(stages) && len(stages) > 0 {
+			results = append(results, t.trip)
+		}
+	}
+	return results
+}
+
+func tripPlaceIds(trip TripSolution) []string {
+	placeIds := make([]string, 0)
+	for _, day := range trip.Days {
+		placeIds = append(placeIds, day.PlaceIDS...)
+	}
+	return placeIds
+}
+
+func containsAnyPlace(placeIds map[string]bool, candidates []string) bool {
+	for _, placeId := range candidates {
+		if placeIds[placeId] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package solution
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+// AnySlot pins a place to a plan without choosing its slot
+const AnySlot = -1
+
+// PinnedPlace must be in every plan, in the given slot unless the slot is AnySlot
+type PinnedPlace struct {
+	PlaceId string
+	Slot    int
+}
+
+// PlaceConstraints restricts places of planning solutions
+type PlaceConstraints struct {
+	PinnedPlaces          []PinnedPlace
+	ExcludedPlaceIds      []string
+	ExcludedLocationTypes []POI.LocationType
+}
+
+func (constraints *PlaceConstraints) IsEmpty() bool {
+	return constraints == nil ||
+		len(constraints.PinnedPlaces)+len(constraints.ExcludedPlaceIds)+len(constraints.ExcludedLocationTypes) == 0
+}
+
+// Serialize returns the same string for the same set of constraints regardless of their order
+func (constraints *PlaceConstraints) Serialize() string {
+	if constraints.IsEmpty() {
+		return ""
+	}
+	pinnedPlaces := make([]string, len(constraints.PinnedPlaces))
+	for idx, pinnedPlace := range constraints.PinnedPlaces {
+		pinnedPlaces[idx] = pinnedPlace.PlaceId + "@" + strconv.Itoa(pinnedPlace.Slot)
+	}
+	excludedPlaceIds := append([]string(nil), constraints.ExcludedPlaceIds...)
+	excludedLocationTypes := make([]string, len(constraints.ExcludedLocationTypes))
+	for idx, locationType := range constraints.ExcludedLocationTypes {
+		excludedLocationTypes[idx] = string(locationType)
+	}
+	sort.Strings(pinnedPlaces)
+	sort.Strings(excludedPlaceIds)
+	sort.Strings(excludedLocationTypes)
+
+	return strings.Join([]string{
+		"pin=" + strings.Join(pinnedPlaces, ","),
+		"exclude=" + strings.Join(excludedPlaceIds, ","),
+		"exclude_type=" + strings.Join(excludedLocationTypes, ","),
+	}, ";")
+}
+
+// excludes checks if a place cannot be in any plan
+func (constraints *PlaceConstraints) excludes(place matching.Place) bool {
+	if constraints.IsEmpty() {
+		return false
+	}
+	for _, placeId := range constraints.ExcludedPlaceIds {
+		if place.GetPlaceId() == placeId {
+			return true
+		}
+	}
+	for _, locationType := range constraints.ExcludedLocationTypes {
+		if place.GetPlaceType() == locationType {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredPlaces returns IDs of places pinned to any slot
+func (constraints *PlaceConstraints) requiredPlaces() []string {
+	placeIds := make([]string, 0)
+	if constraints.IsEmpty() {
+		return placeIds
+	}
+	for _, pinnedPlace := range constraints.PinnedPlaces {
+		if pinnedPlace.Slot == AnySlot {
+			placeIds = append(placeIds, pinnedPlace.PlaceId)
+		}
+	}
+	return placeIds
+}
+
+// SatisfiedBy checks if places of a plan, one for each slot, honor all constraints
+func (constraints *PlaceConstraints) SatisfiedBy(places []matching.Place) bool {
+	if constraints.IsEmpty() {
+		return true
+	}
+	slots := make(map[string]int, len(places))
+	for slot, place := range places {
+		if constraints.excludes(place) {
+			return false
+		}
+		slots[place.GetPlaceId()] = slot
+	}
+	for _, pinnedPlace := range constraints.PinnedPlaces {
+		slot, exists := slots[pinnedPlace.PlaceId]
+		if !exists || (pinnedPlace.Slot != AnySlot && pinnedPlace.Slot != slot) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply removes excluded places from the categorized places of slots,
+// keeps only the pinned place in a slot if a place is pinned to the slot and removes the place from other slots.
+// It returns an error if a pinned place is not available in its slot or in any slot.
+func (constraints *PlaceConstraints) Apply(placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces) ([]CategorizedPlaces, error) {
+	if constraints.IsEmpty() || len(placeCategories) != len(categorizedPlaces) {
+		return categorizedPlaces, nil
+	}
+
+	slotPins := make(map[int]string)
+	slotPinnedPlaces := make(map[string]bool)
+	for _, pinnedPlace := range constraints.PinnedPlaces {
+		if pinnedPlace.Slot != AnySlot {
+			if pinnedPlace.Slot < 0 || pinnedPlace.Slot >= len(placeCategories) {
+				return nil, fmt.Errorf("place %s is pinned to slot %d, which does not exist", pinnedPlace.PlaceId, pinnedPlace.Slot)
+			}
+			if _, exists := slotPins[pinnedPlace.Slot]; exists {
+				return nil, fmt.Errorf("more than one place is pinned to slot %d", pinnedPlace.Slot)
+			}
+			slotPins[pinnedPlace.Slot] = pinnedPlace.PlaceId
+			slotPinnedPlaces[pinnedPlace.PlaceId] = true
+		}
+	}
+
+	availablePlaces := make(map[string]bool)
+	results := make([]CategorizedPlaces, len(categorizedPlaces))
+	for slot, category := range placeCategories {
+		places := categorizedPlaces[slot].VisitPlaces
+		if category == POI.PlaceCategoryEatery {
+			places = categorizedPlaces[slot].EateryPlaces
+		}
+
+		filteredPlaces := make([]matching.Place, 0, len(places))
+		pinnedPlaceId, pinned := slotPins[slot]
+		for _, place := range places {
+			if constraints.excludes(place) {
+				continue
+			}
+			if (pinned && place.GetPlaceId() != pinnedPlaceId) || (!pinned && slotPinnedPlaces[place.GetPlaceId()]) {
+				continue
+			}
+			filteredPlaces = append(filteredPlaces, place)
+			availablePlaces[place.GetPlaceId()] = true
+		}
+		if pinned && len(filteredPlaces) == 0 {
+			return nil, fmt.Errorf("pinned place %s is not available in slot %d", pinnedPlaceId, slot)
+		}
+
+		if category == POI.PlaceCategoryEatery {
+			results[slot] = CategorizedPlaces{EateryPlaces: filteredPlaces, VisitPlaces: make([]matching.Place, 0)}
+		} else {
+			results[slot] = CategorizedPlaces{EateryPlaces: make([]matching.Place, 0), VisitPlaces: filteredPlaces}
+		}
+	}
+
+	for _, placeId := range constraints.requiredPlaces() {
+		if !availablePlaces[placeId] {
+			return nil, fmt.Errorf("pinned place %s is not available in any slot", placeId)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,116 @@
+package solution
+
+import (
+	"sort"
+)
+
+const (
+	MaxDiversity = 1.0
+	// number of candidate plans searched for each plan selected with diversity
+	DiversityCandidatesFactor = 4
+	// share of place overlap in the similarity of two plans, the rest is the overlap of location types
+	placeOverlapSimilarityWeight = 0.7
+)
+
+// SelectDiversePlans selects topSolutionsCount plans from candidates with maximal marginal relevance (MMR)
+// each step picks the plan maximizing (1 - diversity) * normalized score - diversity * its maximum similarity to the plans
+// already selected, so diversity 0 keeps the top plans and diversity 1 prefers plans sharing few places and location types.
+// Selected plans are in the same order as the results of FindBestPlanningSolutions.
+func SelectDiversePlans(candidates []PlanningSolution, topSolutionsCount int64, diversity float64) []PlanningSolution {
+	if topSolutionsCount <= 0 {
+		topSolutionsCount = TopSolutionsCountDefault
+	}
+	if diversity <= 0 || int64(len(candidates)) <= topSolutionsCount {
+		return FindBestPlanningSolutions(candidates, topSolutionsCount)
+	}
+
+	ranked := make([]PlanningSolution, len(candidates))
+	copy(ranked, candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	maxScore, minScore := ranked[0].Score, ranked[len(ranked)-1].Score
+
+	relevance := make([]float64, len(ranked))
+	features := make([]planFeatures, len(ranked))
+	for idx, candidate := range ranked {
+		relevance[idx] = 1
+		if maxScore > minScore {
+			relevance[idx] = (candidate.Score - minScore) / (maxScore - minScore)
+		}
+		features[idx] = toPlanFeatures(candidate)
+	}
+
+	// maximum similarity of each candidate to the selected plans
+	maxSimilarities := make([]float64, len(ranked))
+	selected := make([]bool, len(ranked))
+	results := make([]PlanningSolution, 0, topSolutionsCount)
+	for int64(len(results)) < topSolutionsCount {
+		best := -1
+		var bestRelevance float64
+		for idx := range ranked {
+			if selected[idx] {
+				continue
+			}
+			marginalRelevance := (1-diversity)*relevance[idx] - diversity*maxSimilarities[idx]
+			if best < 0 || marginalRelevance > bestRelevance {
+				best, bestRelevance = idx, marginalRelevance
+			}
+		}
+		selected[best] = true
+		results = append(results, ranked[best])
+
+		for idx := range ranked {
+			if selected[idx] {
+				continue
+			}
+			similarity := features[idx].similarity(features[best])
+			if similarity > maxSimilarities[idx] {
+				maxSimilarities[idx] = similarity
+			}
+		}
+	}
+	return FindBestPlanningSolutions(results, topSolutionsCount)
+}
+
+// PlanSimilarity is the similarity of two plans in [0, 1] used in the diversified selection of plans
+func PlanSimilarity(x PlanningSolution, y PlanningSolution) float64 {
+	return toPlanFeatures(x).similarity(toPlanFeatures(y))
+}
+
+// planFeatures are the sets of places and location types of a plan
+type planFeatures struct {
+	places        map[string]bool
+	locationTypes map[string]bool
+}
+
+func toPlanFeatures(planningSolution PlanningSolution) planFeatures {
+	features := planFeatures{places: make(map[string]bool), locationTypes: make(map[string]bool)}
+	for _, placeId := range planningSolution.PlaceIDS {
+		features.places[placeId] = true
+	}
+	for _, placeType := range planningSolution.PlaceTypes {
+		features.locationTypes[string(placeType)] = true
+	}
+	return features
+}
+
+func (features planFeatures) similarity(other planFeatures) float64 {
+	return placeOverlapSimilarityWeight*jaccardSimilarity(features.places, other.places) +
+		(1-placeOverlapSimilarityWeight)*jaccardSimilarity(features.locationTypes, other.locationTypes)
+}
+
+// jaccardSimilarity is the size of the intersection over the size of the union of two sets, 0 for two empty sets
+func jaccardSimilarity(x map[string]bool, y map[string]bool) float64 {
+	var intersection int
+	for key := range x {
+		if y[key] {
+			intersection++
+		}
+	}
+	union := len(x) + len(y) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
@@ -0,0 +1,81 @@
+package solution
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/graph"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+// OptimizeVisitOrder finds a shorter order of visiting places with locations in the [lng, lat] order
+// eateries keep their slots at meal times and the other places are reordered among the remaining slots.
+// It returns the place visited in each slot and the total distance in meters.
+func OptimizeVisitOrder(locations [][2]float64, categories []POI.PlaceCategory, compatible graph.Compatibility) (order []int, totalDistance float64) {
+	points := make([]graph.Point, len(locations))
+	pinned := make([]bool, len(locations))
+	for idx, location := range locations {
+		points[idx] = graph.Point{Lat: location[1], Lng: location[0]}
+		pinned[idx] = categories[idx] == POI.PlaceCategoryEatery
+	}
+	return graph.OptimizeRoute(points, pinned, compatible)
+}
+
+// optimizeRoutes reorders places of each solution for a shorter route and ranks the solutions again
+// a place can only move to a slot in which it is open, i.e. it is one of the categorized places of the slot
+func optimizeRoutes(solutions []PlanningSolution, placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces,
+	numSolutions int64, scorer matching.Scorer) []PlanningSolution {
+	slotPlaces := make([]map[string]matching.Place, len(placeCategories))
+	for slot, category := range placeCategories {
+		slotPlaces[slot] = make(map[string]matching.Place)
+		places := categorizedPlaces[slot].VisitPlaces
+		if category == POI.PlaceCategoryEatery {
+			places = categorizedPlaces[slot].EateryPlaces
+		}
+		for _, place := range places {
+			slotPlaces[slot][place.GetPlaceId()] = place
+		}
+	}
+
+	optimizedSolutions := make([]PlanningSolution, len(solutions))
+	for idx, planningSolution := range solutions {
+		compatible := func(place int, slot int) bool {
+			_, exists := slotPlaces[slot][planningSolution.PlaceIDS[place]]
+			return exists
+		}
+		order, _ := OptimizeVisitOrder(planningSolution.PlaceLocations, placeCategories, compatible)
+
+		places := make([]matching.Place, len(order))
+		for slot, place := range order {
+			places[slot] = slotPlaces[slot][planningSolution.PlaceIDS[place]]
+		}
+		optimizedSolution := reorderSolution(planningSolution, order)
+		optimizedSolution.ScoreBreakdown = scorer.Breakdown(places)
+		optimizedSolution.Score = optimizedSolution.ScoreBreakdown.Total
+		optimizedSolutions[idx] = optimizedSolution
+	}
+	return FindBestPlanningSolutions(optimizedSolutions, numSolutions)
+}
+
+// reorderSolution puts place order[i] of the solution to slot i
+func reorderSolution(planningSolution PlanningSolution, order []int) PlanningSolution {
+	reordered := PlanningSolution{
+		PlaceNames:     make([]string, len(order)),
+		PlaceIDS:       make([]string, len(order)),
+		PlaceLocations: make([][2]float64, len(order)),
+		PlaceAddresses: make([]string, len(order)),
+		PlaceURLs:      make([]string, len(order)),
+		PlaceTypes:     make([]POI.LocationType, len(order)),
+		Score:          planningSolution.Score,
+		IsSet:          planningSolution.IsSet,
+	}
+	for slot, place := range order {
+		reordered.PlaceNames[slot] = planningSolution.PlaceNames[place]
+		reordered.PlaceIDS[slot] = planningSolution.PlaceIDS[place]
+		reordered.PlaceLocations[slot] = planningSolution.PlaceLocations[place]
+		reordered.PlaceAddresses[slot] = planningSolution.PlaceAddresses[place]
+		reordered.PlaceURLs[slot] = planningSolution.PlaceURLs[place]
+		if len(planningSolution.PlaceTypes) == len(order) {
+			reordered.PlaceTypes[slot] = planningSolution.PlaceTypes[place]
+		}
+	}
+	return reordered
+}
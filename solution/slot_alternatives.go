@@ -0,0 +1,179 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const (
+	SlotAlternativesCountDefault = 10
+)
+
+// SlotAlternativesRequest asks for places replacing the place of one slot of an existing plan
+type SlotAlternativesRequest struct {
+	Location        string // city,country
+	Weekday         POI.Weekday
+	SearchRadius    uint
+	Slots           []SlotRequest
+	PlaceIds        []string // place of each slot in the existing plan
+	SlotIndex       int      // the slot to replace
+	NumAlternatives int
+	ScoreWeights    *matching.ScoreWeights // default weights if nil
+}
+
+// SlotAlternative is a place for the slot and the score of the plan with the place in the slot
+type SlotAlternative struct {
+	Place matching.PlaceView `json:"place"`
+	Score float64            `json:"score"`
+}
+
+type SlotAlternativesResponse struct {
+	Alternatives []SlotAlternative
+	Err          error
+	ErrorCode    uint
+}
+
+// FindSlotAlternatives ranks places open in the slot of the request with places of the other slots unchanged
+// places of slots are loaded from cache if they were cached when plans were generated
+func (solver *Solver) FindSlotAlternatives(context context.Context, req *SlotAlternativesRequest, resp *SlotAlternativesResponse) {
+	if !solver.ValidateLocation(context, &req.Location) {
+		resp.Err = errors.New("invalid travel destination")
+		resp.ErrorCode = InvalidRequestLocation
+		return
+	}
+	if len(req.PlaceIds) != len(req.Slots) || req.SlotIndex < 0 || req.SlotIndex >= len(req.Slots) {
+		resp.Err = errors.New("a plan must have one place for each slot and the slot to replace must exist")
+		resp.ErrorCode = ReqTagInvalid
+		return
+	}
+
+	categorizedPlaces, err := solver.loadCategorizedPlaces(context, req.Location, req.SearchRadius, req.Weekday, req.Slots)
+	if err != nil {
+		resp.Err = err
+		resp.ErrorCode = CatPlaceIterInitFailure
+		return
+	}
+
+	scorer := matching.DefaultScorer
+	if req.ScoreWeights != nil {
+		scorer = matching.CreateWeightedScorer(*req.ScoreWeights)
+	}
+	resp.Alternatives, err = RankSlotAlternatives(ToSlotCategories(req.Slots), categorizedPlaces, req.PlaceIds, req.SlotIndex, req.NumAlternatives, scorer)
+	if err != nil {
+		resp.Err = err
+		resp.ErrorCode = NoValidSolution
+	}
+}
+
+// RankSlotAlternatives scores the plan with each place of the slot replacing the current one
+// and returns the best numAlternatives places, excluding places already in the plan
+func RankSlotAlternatives(placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces, placeIds []string,
+	slotIndex int, numAlternatives int, scorer matching.Scorer) ([]SlotAlternative, error) {
+	if len(placeCategories) != len(categorizedPlaces) || len(placeIds) != len(placeCategories) {
+		return nil, errors.New(CategorizedPlaceIterInitFailureErrMsg)
+	}
+	if numAlternatives <= 0 {
+		numAlternatives = SlotAlternativesCountDefault
+	}
+
+	places := make([]matching.Place, len(placeIds))
+	planPlaces := make(map[string]bool, len(placeIds))
+	for slot, placeId := range placeIds {
+		planPlaces[placeId] = true
+		if slot == slotIndex {
+			continue
+		}
+		found := false
+		for _, place := range categorizedPlaces[slot].PlacesOf(placeCategories[slot]) {
+			if place.GetPlaceId() == placeId {
+				places[slot], found = place, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("place %s is not available in slot %d", placeId, slot)
+		}
+	}
+
+	alternatives := make([]SlotAlternative, 0)
+	for _, candidate := range categorizedPlaces[slotIndex].PlacesOf(placeCategories[slotIndex]) {
+		if planPlaces[candidate.GetPlaceId()] {
+			continue
+		}
+		planPlaces[candidate.GetPlaceId()] = true
+		places[slotIndex] = candidate
+		alternatives = append(alternatives, SlotAlternative{Place: matching.ToPlaceView(candidate), Score: scorer.Score(places)})
+	}
+
+	sort.SliceStable(alternatives, func(i, j int) bool {
+		return alternatives[i].Score > alternatives[j].Score
+	})
+	if len(alternatives) > numAlternatives {
+		alternatives = alternatives[:numAlternatives]
+	}
+	return alternatives, nil
+}
+
+func toSlotPlacesCacheRequest(location string, radius uint, weekday POI.Weekday, interval POI.TimeInterval, category POI.PlaceCategory) iowrappers.SlotPlacesCacheRequest {
+	cityCountry := strings.Split(location, ",")
+	return iowrappers.SlotPlacesCacheRequest{
+		Country:  cityCountry[1],
+		City:     cityCountry[0],
+		Radius:   uint64(radius),
+		Weekday:  weekday,
+		Interval: interval,
+		Category: category,
+	}
+}
+
+// cacheCategorizedPlaces caches the places of each category open during the slot
+func cacheCategorizedPlaces(context context.Context, redisClient *iowrappers.RedisClient, location string, radius uint,
+	weekday POI.Weekday, interval POI.TimeInterval, categorizedPlaces CategorizedPlaces) {
+	for _, category := range []POI.PlaceCategory{POI.PlaceCategoryEatery, POI.PlaceCategoryVisit} {
+		places := categorizedPlaces.PlacesOf(category)
+		placeIds := make([]string, len(places))
+		for idx, place := range places {
+			placeIds[idx] = place.GetPlaceId()
+		}
+		redisClient.CacheSlotPlaces(context, toSlotPlacesCacheRequest(location, radius, weekday, interval, category), placeIds)
+	}
+}
+
+// loadCategorizedPlaces gets places of slots from cache and searches places again if any slot is not cached
+func (solver *Solver) loadCategorizedPlaces(context context.Context, location string, radius uint, weekday POI.Weekday, slots []SlotRequest) ([]CategorizedPlaces, error) {
+	redisClient := solver.Matcher.PoiSearcher.GetRedisClient()
+	categorizedPlaces := make([]CategorizedPlaces, len(slots))
+	for idx, slot := range slots {
+		cachedPlaces, err := redisClient.GetSlotPlaces(context, toSlotPlacesCacheRequest(location, radius, weekday, slot.TimeSlot.Slot, slot.Category))
+		if err != nil {
+			iowrappers.Logger.Infof("Slot places cache miss!")
+			return solver.searchCategorizedPlaces(context, location, radius, weekday, slots)
+		}
+		places := make([]matching.Place, len(cachedPlaces))
+		for placeIdx, place := range cachedPlaces {
+			places[placeIdx] = matching.CreatePlace(place, slot.Category)
+		}
+		categorizedPlaces[idx] = CategorizedPlaces{EateryPlaces: make([]matching.Place, 0), VisitPlaces: make([]matching.Place, 0)}
+		if slot.Category == POI.PlaceCategoryEatery {
+			categorizedPlaces[idx].EateryPlaces = places
+		} else {
+			categorizedPlaces[idx].VisitPlaces = places
+		}
+	}
+	return categorizedPlaces, nil
+}
+
+func (solver *Solver) searchCategorizedPlaces(context context.Context, location string, radius uint, weekday POI.Weekday, slots []SlotRequest) ([]CategorizedPlaces, error) {
+	categorizedPlaces, _ := generateCategorizedPlaces(context, solver.Matcher, location, radius, weekday, ToTimeSlots(slots))
+	if len(categorizedPlaces) != len(slots) {
+		return nil, errors.New(CategorizedPlaceIterInitFailureErrMsg)
+	}
+	return categorizedPlaces, nil
+}
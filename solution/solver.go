@@ -3,6 +3,7 @@ package solution
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/weihesdlegend/Vacation-planner/POI"
@@ -15,7 +16,8 @@ const (
 )
 
 type Solver struct {
-	Matcher *matching.TimeMatcher
+	Matcher            *matching.TimeMatcher
+	TravelTimeProvider iowrappers.TravelTimeProvider
 }
 
 // HTTP status codes
@@ -28,11 +30,16 @@ const (
 )
 
 type PlanningRequest struct {
-	Location     string // city,country
-	Slots        []SlotRequest
-	Weekday      POI.Weekday
-	NumPlans     int64
-	SearchRadius uint
+	Location      string // city,country
+	Slots         []SlotRequest
+	Weekday       POI.Weekday
+	NumPlans      int64
+	SearchRadius  uint
+	TravelMode    iowrappers.TravelMode  // walking by default
+	OptimizeRoute bool                   // reorder places for shorter routes, eateries keep their slots
+	Constraints   PlaceConstraints       // places that must be in or out of plans
+	ScoreWeights  *matching.ScoreWeights // default weights if nil
+	Diversity     float64                // in [0, 1], plans share fewer places and location types with higher diversity
 }
 
 type PlanningResponse struct {
@@ -47,9 +54,18 @@ type SlotRequest struct {
 	Category POI.PlaceCategory
 }
 
+// scorer of plans with the weights of the request
+func (req *PlanningRequest) scorer() matching.Scorer {
+	if req.ScoreWeights == nil {
+		return matching.DefaultScorer
+	}
+	return matching.CreateWeightedScorer(*req.ScoreWeights)
+}
+
 func (solver *Solver) Init(poiSearcher *iowrappers.PoiSearcher) {
 	solver.Matcher = &matching.TimeMatcher{}
 	solver.Matcher.Init(poiSearcher)
+	solver.TravelTimeProvider = iowrappers.CreateSpeedProfileTravelTimeProvider()
 }
 
 func (solver *Solver) ValidateLocation(context context.Context, slotRequestLocation *string) bool {
@@ -79,16 +95,40 @@ func GenerateSlotSolutionRedisRequest(location string, evTag string, stayTimes [
 	}
 
 	req := iowrappers.SlotSolutionCacheRequest{
-		Country:   cityCountry[1],
-		City:      cityCountry[0],
-		Radius:    uint64(radius),
-		EVTags:    evTags,
-		Intervals: intervals,
-		Weekday:   weekday,
+		Country:       cityCountry[1],
+		City:          cityCountry[0],
+		Radius:        uint64(radius),
+		EVTags:        evTags,
+		Intervals:     intervals,
+		Weekday:       weekday,
+		ScorerVersion: matching.ScorerVersion,
 	}
 	return req
 }
 
+// toSlotSolutionRedisRequest derives the slot solution cache request of a single-day planning request
+func toSlotSolutionRedisRequest(req *PlanningRequest) iowrappers.SlotSolutionCacheRequest {
+	var sb strings.Builder
+	for _, cat := range ToSlotCategories(req.Slots) {
+		switch cat {
+		case POI.PlaceCategoryEatery:
+			sb.WriteString("e")
+		case POI.PlaceCategoryVisit:
+			sb.WriteString("v")
+		}
+	}
+	cacheRequest := GenerateSlotSolutionRedisRequest(req.Location, sb.String(), ToTimeSlots(req.Slots), req.SearchRadius, req.Weekday)
+	cacheRequest.OptimizedRoute = req.OptimizeRoute
+	cacheRequest.PlaceConstraints = req.Constraints.Serialize()
+	if req.ScoreWeights != nil {
+		cacheRequest.ScoreWeights = req.ScoreWeights.Serialize()
+	}
+	if req.Diversity > 0 {
+		cacheRequest.Diversity = strconv.FormatFloat(req.Diversity, 'g', -1, 64)
+	}
+	return cacheRequest
+}
+
 func (solver *Solver) Solve(context context.Context, redisCli iowrappers.RedisClient, req *PlanningRequest, resp *PlanningResponse) {
 	// validate location with PoiSearcher of the TimeMatcher
 	if !solver.ValidateLocation(context, &req.Location) {
@@ -102,18 +142,14 @@ func (solver *Solver) Solve(context context.Context, redisCli iowrappers.RedisCl
 		req.NumPlans = NumPlansDefault
 	}
 
-	redisRequests := make([]iowrappers.SlotSolutionCacheRequest, 1)
+	// plans are scheduled with transit in the travel mode of the request, whose transit must fit in the slots
+	scheduler := solver.transitScheduler(context, req)
 
-	var sb strings.Builder
-	for _, cat := range ToSlotCategories(req.Slots) {
-		switch cat {
-		case POI.PlaceCategoryEatery:
-			sb.WriteString("e")
-		case POI.PlaceCategoryVisit:
-			sb.WriteString("v")
-		}
+	redisRequests := make([]iowrappers.SlotSolutionCacheRequest, 1)
+	redisRequests[0] = toSlotSolutionRedisRequest(req)
+	if scheduler != nil {
+		redisRequests[0].TravelMode = string(req.TravelMode)
 	}
-	redisRequests[0] = GenerateSlotSolutionRedisRequest(req.Location, sb.String(), ToTimeSlots(req.Slots), req.SearchRadius, req.Weekday)
 
 	// TODO: Refactor Redis client to take single iowrappers.SlotSolutionCacheRequest
 	slotSolutionCacheResponses := redisCli.GetMultiSlotSolutions(context, redisRequests)
@@ -125,24 +161,21 @@ func (solver *Solver) Solve(context context.Context, redisCli iowrappers.RedisCl
 	if cacheResponse.Err == nil {
 		iowrappers.Logger.Infof("Found slot cacheResponse in cache!")
 		for _, candidate := range cacheResponse.SlotSolutionCandidate {
-			planningSolution := PlanningSolution{
-				PlaceNames:     candidate.PlaceNames,
-				PlaceIDS:       candidate.PlaceIds,
-				PlaceLocations: candidate.PlaceLocations,
-				PlaceAddresses: candidate.PlaceAddresses,
-				PlaceURLs:      candidate.PlaceURLs,
-				Score:          candidate.Score,
-				IsSet:          true,
-			}
-			resp.Solutions = append(resp.Solutions, planningSolution)
+			resp.Solutions = append(resp.Solutions, fromSlotSolutionCache(candidate))
 		}
 		iowrappers.Logger.Infof("Got %d results from Redis", len(resp.Solutions))
+		resp.Solutions = scheduleTransit(resp.Solutions, scheduler)
+		if len(resp.Solutions) == 0 {
+			resp.Err = errors.New("cannot find a valid solution")
+			resp.ErrorCode = NoValidSolution
+		}
 		return
 	}
 
 	iowrappers.Logger.Infof("Solution cache miss!")
-	solutions, slotSolutionRedisKey, err := GenerateSolutions(context, solver.Matcher, redisCli, redisRequests[0], *req)
+	solutions, slotSolutionRedisKey, err := GenerateSolutions(context, solver.Matcher, redisCli, redisRequests[0], *req, scheduler)
 	if err != nil {
+		resp.Err = err
 		if err.Error() == CategorizedPlaceIterInitFailureErrMsg {
 			resp.ErrorCode = CatPlaceIterInitFailure
 		} else {
@@ -156,6 +189,8 @@ func (solver *Solver) Solve(context context.Context, redisCli iowrappers.RedisCl
 
 	if len(resp.Solutions) == 0 {
 		invalidateSlotSolutionCache(context, &redisCli, slotSolutionRedisKeys)
+		resp.Err = errors.New("cannot find a valid solution")
+		resp.ErrorCode = NoValidSolution
 	}
 }
 
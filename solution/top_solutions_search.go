@@ -0,0 +1,315 @@
+package solution
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+const (
+	// distances shorter than this do not count toward the maximum distance in scores of plans
+	minScoringDistance = 0.001
+	// tolerance of floating point errors between score upper bounds and scores
+	scoreBoundTolerance = 1e-9
+)
+
+// SearchTopSolutions finds the same top solutions as EnumerateTopSolutions with a branch-and-bound search
+// places of each slot are tried in the order of single place scores, and a branch is pruned if the upper bound
+// of its scores is lower than the worst of the best topSolutionsCount solutions found so far.
+// The upper bound of plans with the average of their place scores comes from the scorer.
+// A branch is also pruned if the remaining slots cannot take all places pinned to any slot by the constraints.
+// Plans are scored with the default scorer if the scorer is nil.
+func SearchTopSolutions(placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces, topSolutionsCount int64,
+	constraints *PlaceConstraints, scorer matching.Scorer) (solutions []PlanningSolution, err error) {
+	// exclusions and places pinned to slots shrink the places of slots before the search
+	if categorizedPlaces, err = constraints.Apply(placeCategories, categorizedPlaces); err != nil {
+		return make([]PlanningSolution, 0), err
+	}
+	return searchTopSolutions(placeCategories, categorizedPlaces, topSolutionsCount, constraints, scorer)
+}
+
+// searchTopSolutions is SearchTopSolutions on places the constraints are already applied to
+func searchTopSolutions(placeCategories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces, topSolutionsCount int64,
+	constraints *PlaceConstraints, scorer matching.Scorer) (solutions []PlanningSolution, err error) {
+	solutions = make([]PlanningSolution, 0)
+	if topSolutionsCount <= 0 {
+		topSolutionsCount = TopSolutionsCountDefault
+	}
+	if scorer == nil {
+		scorer = matching.DefaultScorer
+	}
+
+	search := topSolutionsSearch{constraints: constraints, requiredPlaces: constraints.requiredPlaces(), scorer: scorer}
+	if err = search.init(placeCategories, categorizedPlaces, int(topSolutionsCount)); err != nil || len(placeCategories) == 0 {
+		return
+	}
+	search.dfs(0, 0, false)
+
+	for _, candidate := range search.best {
+		solutions = append(solutions, candidate.solution)
+	}
+	// keep the same order of results as the exhaustive enumeration
+	return FindBestPlanningSolutions(solutions, topSolutionsCount), nil
+}
+
+type topSolutionsSearch struct {
+	categories        []POI.PlaceCategory
+	categorizedPlaces []CategorizedPlaces
+	slotPlaces        [][]matching.Place
+	slotIndexes       []map[string]int // place ID to the smallest index of the place in each slot
+	singleScores      [][]float64
+	orders            [][]int   // place indexes of each slot sorted by single place scores in descending order
+	maxScoresFrom     []float64 // sum of the maximum single place score of slots starting from each slot
+	status            []int
+	usedPlaces        map[string]bool
+	constraints       *PlaceConstraints
+	scorer            matching.Scorer
+	requiredPlaces    []string // places pinned to any slot
+	numSolutions      int
+	best              candidateHeap
+}
+
+func (search *topSolutionsSearch) init(categories []POI.PlaceCategory, categorizedPlaces []CategorizedPlaces, numSolutions int) error {
+	if len(categories) != len(categorizedPlaces) {
+		log.Error("place category list length is different from categorized places")
+		return errors.New(CategorizedPlaceIterInitFailureErrMsg)
+	}
+
+	numSlots := len(categories)
+	search.categories = categories
+	search.categorizedPlaces = categorizedPlaces
+	search.numSolutions = numSolutions
+	search.slotPlaces = make([][]matching.Place, numSlots)
+	search.slotIndexes = make([]map[string]int, numSlots)
+	search.singleScores = make([][]float64, numSlots)
+	search.orders = make([][]int, numSlots)
+	search.maxScoresFrom = make([]float64, numSlots+1)
+	search.status = make([]int, numSlots)
+	search.usedPlaces = make(map[string]bool)
+
+	var err error
+	for slot, category := range categories {
+		switch category {
+		case POI.PlaceCategoryEatery:
+			search.slotPlaces[slot] = categorizedPlaces[slot].EateryPlaces
+		case POI.PlaceCategoryVisit:
+			search.slotPlaces[slot] = categorizedPlaces[slot].VisitPlaces
+		}
+		if len(search.slotPlaces[slot]) == 0 {
+			log.Errorf("number of places for category %s is 0, tag index is %d \n", category, slot)
+			err = errors.New(CategorizedPlaceIterInitFailureErrMsg)
+			continue
+		}
+
+		places := search.slotPlaces[slot]
+		search.slotIndexes[slot] = make(map[string]int, len(places))
+		search.singleScores[slot] = make([]float64, len(places))
+		search.orders[slot] = make([]int, len(places))
+		for idx, place := range places {
+			if _, exists := search.slotIndexes[slot][place.GetPlaceId()]; !exists {
+				search.slotIndexes[slot][place.GetPlaceId()] = idx
+			}
+			search.singleScores[slot][idx] = search.scorer.PlaceScore(place)
+			search.orders[slot][idx] = idx
+		}
+		scores := search.singleScores[slot]
+		sort.SliceStable(search.orders[slot], func(i, j int) bool {
+			return scores[search.orders[slot][i]] > scores[search.orders[slot][j]]
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	for slot := numSlots - 1; slot >= 0; slot-- {
+		search.maxScoresFrom[slot] = search.maxScoresFrom[slot+1] + search.singleScores[slot][search.orders[slot][0]]
+	}
+	return nil
+}
+
+// upperBound is the maximum score of solutions starting with places chosen for slots before the depth
+func (search *topSolutionsSearch) upperBound(depth int, prefixScore float64, apart bool) float64 {
+	numSlots := len(search.categories)
+	averagePlaceScore := (prefixScore + search.maxScoresFrom[depth]) / float64(numSlots)
+	return search.scorer.UpperBound(averagePlaceScore, numSlots, apart) + scoreBoundTolerance
+}
+
+func (search *topSolutionsSearch) dfs(depth int, prefixScore float64, apart bool) {
+	if len(search.best) == search.numSolutions && search.upperBound(depth, prefixScore, apart) < search.best[0].solution.Score {
+		return
+	}
+
+	if depth == len(search.categories) {
+		search.addCandidate()
+		return
+	}
+	if !search.canTakeRequiredPlaces(depth) {
+		return
+	}
+
+	places := search.slotPlaces[depth]
+	for _, idx := range search.orders[depth] {
+		place := places[idx]
+		if search.usedPlaces[place.GetPlaceId()] {
+			continue
+		}
+		nextApart := apart
+		if depth > 0 {
+			previous := search.slotPlaces[depth-1][search.status[depth-1]].GetLocation()
+			current := place.GetLocation()
+			if utils.HaversineDist(previous[:], current[:]) >= minScoringDistance {
+				nextApart = true
+			}
+		}
+
+		search.status[depth] = idx
+		search.usedPlaces[place.GetPlaceId()] = true
+		search.dfs(depth+1, prefixScore+search.singleScores[depth][idx], nextApart)
+		delete(search.usedPlaces, place.GetPlaceId())
+	}
+}
+
+// canTakeRequiredPlaces checks if slots starting from the depth can take the required places not used yet
+func (search *topSolutionsSearch) canTakeRequiredPlaces(depth int) bool {
+	numMissing := 0
+	for _, placeId := range search.requiredPlaces {
+		if search.usedPlaces[placeId] {
+			continue
+		}
+		numMissing++
+		available := false
+		for slot := depth; slot < len(search.categories) && !available; slot++ {
+			_, available = search.slotIndexes[slot][placeId]
+		}
+		if !available {
+			return false
+		}
+	}
+	return numMissing <= len(search.categories)-depth
+}
+
+func (search *topSolutionsSearch) addCandidate() {
+	// the exhaustive enumeration keeps the first permutation of the same places
+	if !search.isFirstPermutation() {
+		return
+	}
+	candidate := CreateCandidate(search.categories, MultiDimIterator{Status: search.status}, search.categorizedPlaces, search.constraints, search.scorer)
+	if !candidate.IsSet {
+		return
+	}
+
+	status := make([]int, len(search.status))
+	copy(status, search.status)
+	heap.Push(&search.best, rankedCandidate{solution: candidate, status: status})
+	if len(search.best) > search.numSolutions {
+		heap.Pop(&search.best)
+	}
+}
+
+// isFirstPermutation checks if no other assignment of the current places to slots comes earlier in the enumeration order
+// an earlier assignment keeps the places of the first slots, and puts a place with a smaller index in the next slot
+func (search *topSolutionsSearch) isFirstPermutation() bool {
+	numSlots := len(search.status)
+	placeIds := make([]string, numSlots)
+	for slot, idx := range search.status {
+		placeIds[slot] = search.slotPlaces[slot][idx].GetPlaceId()
+	}
+
+	for slot := 0; slot < numSlots; slot++ {
+		for _, placeId := range placeIds[slot:] {
+			idx, exists := search.slotIndexes[slot][placeId]
+			if !exists || idx >= search.status[slot] {
+				continue
+			}
+			remaining := make([]string, 0, numSlots-slot-1)
+			for _, otherId := range placeIds[slot:] {
+				if otherId != placeId {
+					remaining = append(remaining, otherId)
+				}
+			}
+			if search.canAssign(remaining, slot+1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canAssign checks if places can be assigned to slots starting from the first slot, one place for each slot
+func (search *topSolutionsSearch) canAssign(placeIds []string, firstSlot int) bool {
+	numSlots := len(search.status) - firstSlot
+	if len(placeIds) != numSlots {
+		return false
+	}
+	// bipartite matching of places and slots with augmenting paths
+	slotOwners := make([]int, numSlots)
+	for slot := range slotOwners {
+		slotOwners[slot] = -1
+	}
+	var augment func(place int, visited []bool) bool
+	augment = func(place int, visited []bool) bool {
+		for slot := 0; slot < numSlots; slot++ {
+			if _, exists := search.slotIndexes[firstSlot+slot][placeIds[place]]; !exists || visited[slot] {
+				continue
+			}
+			visited[slot] = true
+			if slotOwners[slot] < 0 || augment(slotOwners[slot], visited) {
+				slotOwners[slot] = place
+				return true
+			}
+		}
+		return false
+	}
+	for place := range placeIds {
+		if !augment(place, make([]bool, numSlots)) {
+			return false
+		}
+	}
+	return true
+}
+
+type rankedCandidate struct {
+	solution PlanningSolution
+	status   []int // place indexes in slots, used for ranking candidates of the same score in the enumeration order
+}
+
+// candidateHeap is a min heap with the worst candidate on top
+type candidateHeap []rankedCandidate
+
+func (h candidateHeap) Len() int {
+	return len(h)
+}
+
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].solution.Score != h[j].solution.Score {
+		return h[i].solution.Score < h[j].solution.Score
+	}
+	// candidates enumerated later are worse
+	for slot := range h[i].status {
+		if h[i].status[slot] != h[j].status[slot] {
+			return h[i].status[slot] > h[j].status[slot]
+		}
+	}
+	return false
+}
+
+func (h candidateHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *candidateHeap) Push(x interface{}) {
+	*h = append(*h, x.(rankedCandidate))
+}
+
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,101 @@
+package solution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+const (
+	// a visit keeps at least this ratio of its slot after the transit from the previous place
+	MinVisitRatio = 0.5
+	// at most this many times of the candidates of a request are drawn for plans whose transit fits
+	maxTransitCandidatesFactor = 16
+)
+
+var ErrTransitNotFit = errors.New("not enough time for transit between places")
+
+// ScheduledVisit is the actual visit to a place in its slot after arriving from the previous place
+type ScheduledVisit struct {
+	Start   POI.Minute                 `json:"start"`
+	End     POI.Minute                 `json:"end"`
+	Transit *iowrappers.TravelEstimate `json:"transit,omitempty"` // from the previous place, nil for the first place
+}
+
+// ScheduleWithTransit inserts transit legs between consecutive places of a plan
+// a visit starts when the slot starts or when the traveler arrives from the previous place, whichever is later,
+// and ends when the slot ends. Plans leaving less than MinVisitRatio of a slot for the visit are rejected.
+func ScheduleWithTransit(context context.Context, provider iowrappers.TravelTimeProvider, mode iowrappers.TravelMode,
+	locations [][2]float64, slots []SlotRequest) ([]ScheduledVisit, error) {
+	if len(locations) != len(slots) {
+		return nil, errors.New("number of places is different from number of slots")
+	}
+
+	visits := make([]ScheduledVisit, len(slots))
+	for idx, slot := range slots {
+		slotInterval := slot.TimeSlot.Slot.ToMinuteInterval()
+		visit := ScheduledVisit{Start: slotInterval.Start, End: slotInterval.End}
+		if idx > 0 {
+			estimate, err := provider.EstimateTravel(context, locations[idx-1], locations[idx], mode)
+			if err != nil {
+				return nil, err
+			}
+			visit.Transit = &estimate
+			if arrival := visits[idx-1].End + POI.Minute(estimate.Minutes); arrival > visit.Start {
+				visit.Start = arrival
+			}
+		}
+
+		minVisitMinutes := POI.Minute(float64(slotInterval.End-slotInterval.Start) * MinVisitRatio)
+		if visit.Start >= visit.End || visit.End-visit.Start < minVisitMinutes {
+			return nil, fmt.Errorf("%w: arriving at place %d at minute %d of the day", ErrTransitNotFit, idx, visit.Start)
+		}
+		visits[idx] = visit
+	}
+	return visits, nil
+}
+
+// TransitScheduler schedules a plan with transit between its places, and returns an error if the transit does not fit
+type TransitScheduler func(plan PlanningSolution) (PlanningSolution, error)
+
+// transitScheduler schedules plans of the request in its travel mode, walking by default,
+// and is nil if the solver does not estimate travel times
+func (solver *Solver) transitScheduler(context context.Context, req *PlanningRequest) TransitScheduler {
+	if solver.TravelTimeProvider == nil {
+		return nil
+	}
+	if req.TravelMode == "" {
+		req.TravelMode = iowrappers.TravelModeWalking
+	}
+
+	provider, mode, slots := solver.TravelTimeProvider, req.TravelMode, req.Slots
+	return func(plan PlanningSolution) (PlanningSolution, error) {
+		schedule, err := ScheduleWithTransit(context, provider, mode, plan.PlaceLocations, slots)
+		if err != nil {
+			return plan, err
+		}
+		plan.Schedule = schedule
+		return plan, nil
+	}
+}
+
+// scheduleTransit schedules plans with transit and removes those whose transit does not fit,
+// plans are kept as they are if the scheduler is nil
+func scheduleTransit(plans []PlanningSolution, scheduler TransitScheduler) []PlanningSolution {
+	if scheduler == nil {
+		return plans
+	}
+	scheduledPlans := make([]PlanningSolution, 0, len(plans))
+	for _, plan := range plans {
+		scheduledPlan, err := scheduler(plan)
+		if err != nil {
+			iowrappers.Logger.Debug(err)
+			continue
+		}
+		scheduledPlans = append(scheduledPlans, scheduledPlan)
+	}
+	return scheduledPlans
+}
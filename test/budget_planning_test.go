@@ -0,0 +1,79 @@
+package test
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+	"testing"
+)
+
+func TestScheduleInOrder(t *testing.T) {
+	places := []matching.Place{
+		matching.CreatePlace(POI.Place{ID: "1", LocationType: POI.LocationTypeCafe}, POI.PlaceCategoryEatery),
+		matching.CreatePlace(POI.Place{ID: "2", LocationType: POI.LocationTypeMuseum}, POI.PlaceCategoryVisit),
+		matching.CreatePlace(POI.Place{ID: "3", LocationType: POI.LocationTypePark}, POI.PlaceCategoryVisit),
+	}
+
+	scheduledPlaces := solution.ScheduleInOrder(places, 9)
+	expected := []POI.TimeInterval{{Start: 9, End: 10}, {Start: 10, End: 13}, {Start: 13, End: 15}}
+	for idx, scheduledPlace := range scheduledPlaces {
+		if scheduledPlace.Place.GetPlaceId() != places[idx].GetPlaceId() {
+			t.Errorf("expected place %s at position %d", places[idx].GetPlaceId(), idx)
+		}
+		if scheduledPlace.TimeInterval != expected[idx] {
+			t.Errorf("expected place %s to be visited during %v, got %v",
+				places[idx].GetPlaceId(), expected[idx], scheduledPlace.TimeInterval)
+		}
+	}
+}
+
+func TestInterleaveMeals(t *testing.T) {
+	// the knapsack selects eateries before visits
+	places := []matching.Place{
+		matching.CreatePlace(POI.Place{ID: "e1", LocationType: POI.LocationTypeCafe}, POI.PlaceCategoryEatery),
+		matching.CreatePlace(POI.Place{ID: "e2", LocationType: POI.LocationTypeRestaurant}, POI.PlaceCategoryEatery),
+		matching.CreatePlace(POI.Place{ID: "e3", LocationType: POI.LocationTypeRestaurant}, POI.PlaceCategoryEatery),
+		matching.CreatePlace(POI.Place{ID: "v1", LocationType: POI.LocationTypeMuseum}, POI.PlaceCategoryVisit),
+		matching.CreatePlace(POI.Place{ID: "v2", LocationType: POI.LocationTypePark}, POI.PlaceCategoryVisit),
+	}
+
+	expected := []string{"e1", "v1", "e2", "v2", "e3"}
+	for idx, place := range solution.InterleaveMeals(places) {
+		if place.GetPlaceId() != expected[idx] {
+			t.Errorf("expected place %s at position %d, got %s", expected[idx], idx, place.GetPlaceId())
+		}
+	}
+
+	expected = []string{"e1", "v1", "v2"}
+	for idx, place := range solution.InterleaveMeals([]matching.Place{places[3], places[0], places[4]}) {
+		if place.GetPlaceId() != expected[idx] {
+			t.Errorf("expected place %s at position %d, got %s", expected[idx], idx, place.GetPlaceId())
+		}
+	}
+}
+
+func TestScheduleWithMealsKeepsOpeningHours(t *testing.T) {
+	museum := matching.CreatePlace(POI.Place{ID: "museum", LocationType: POI.LocationTypeMuseum}, POI.PlaceCategoryVisit)
+	lunchHours := [7]string{}
+	lunchHours[POI.DateMonday] = "Monday: 12:00 PM – 2:00 PM"
+	cafe := matching.CreatePlace(POI.Place{ID: "cafe", LocationType: POI.LocationTypeCafe, Hours: lunchHours}, POI.PlaceCategoryEatery)
+
+	// the knapsack selects the museum from 9 to 12 and then the cafe from 12 to 13
+	// interleaving meals would move the cafe to 9, before it opens
+	scheduledPlaces := solution.ScheduleWithMeals([]matching.Place{museum, cafe}, POI.DateMonday, 9)
+	expected := []string{"museum", "cafe"}
+	for idx, scheduledPlace := range scheduledPlaces {
+		if scheduledPlace.Place.GetPlaceId() != expected[idx] {
+			t.Errorf("expected place %s at position %d, got %s", expected[idx], idx, scheduledPlace.Place.GetPlaceId())
+		}
+		if !scheduledPlace.IsOpen(POI.DateMonday) {
+			t.Errorf("place %s is scheduled during %v while closed", scheduledPlace.Place.GetPlaceId(), scheduledPlace.TimeInterval)
+		}
+	}
+
+	// meals are interleaved if all places stay open
+	scheduledPlaces = solution.ScheduleWithMeals([]matching.Place{museum, cafe}, POI.DateTuesday, 9)
+	if scheduledPlaces[0].Place.GetPlaceId() != "cafe" {
+		t.Errorf("expected to start with the cafe on Tuesday, got %s", scheduledPlaces[0].Place.GetPlaceId())
+	}
+}
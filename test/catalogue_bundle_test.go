@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+const catalogueBundleFile = "testdata/catalogue/chicago_toronto.ndjson"
+
+// seedCatalogue imports the test bundle into a new Redis
+func seedCatalogue(t *testing.T) (iowrappers.RedisClient, *miniredis.Miniredis) {
+	_, redisClient, redisServer := createRedis(t)
+	bundle, err := os.Open(catalogueBundleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.Close()
+	stats, err := redisClient.ImportCatalogue(context.Background(), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (iowrappers.CatalogueStats{Places: 4, Cities: 2, Aliases: 6}) {
+		t.Errorf("unexpected import stats %+v", stats)
+	}
+	return redisClient, redisServer
+}
+
+// catalogueRecords returns the records of an NDJSON bundle other than the header in order
+func catalogueRecords(t *testing.T, redisClient iowrappers.RedisClient, filter iowrappers.CatalogueFilter) []string {
+	var bundle bytes.Buffer
+	if _, err := redisClient.ExportCatalogue(context.Background(), &bundle, iowrappers.CatalogueFormatNDJSON, filter); err != nil {
+		t.Fatal(err)
+	}
+	records := strings.Split(strings.TrimSpace(bundle.String()), "\n")[1:]
+	sort.Strings(records)
+	return records
+}
+
+func TestCatalogueImport(t *testing.T) {
+	redisClient, redisServer := seedCatalogue(t)
+	ctx := context.Background()
+
+	lat, lng, err := redisClient.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Chi-Town", Country: "United States"})
+	if err != nil || lat != 41.8781 || lng != -87.6298 {
+		t.Errorf("expected the geocode of Chicago by its aliases, got %f, %f, %v", lat, lng, err)
+	}
+	if visitCount, _ := redisClient.GetPlaceCountByCategory(ctx, POI.PlaceCategoryVisit); visitCount != 2 {
+		t.Errorf("expected 2 places in the geo index of visit places, got %d", visitCount)
+	}
+	if osmParks, _ := redisServer.ZMembers("osm:placeIDs:visit"); len(osmParks) != 1 || osmParks[0] != "osm-node-2401" {
+		t.Errorf("expected the place of OpenStreetMap in its geo index, got %v", osmParks)
+	}
+	places, err := redisClient.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryEatery, Location: "41.878100,-87.629800", Radius: 5000})
+	if err != nil || len(places) != 1 || places[0].Name != "Lou Malnati's Pizzeria" {
+		t.Errorf("expected the restaurant to be found by nearby searches, got %v, %v", places, err)
+	}
+	if lastRefreshed, _ := redisClient.GetPlaceLastRefreshed(ctx, "catalogue-tor-rom"); lastRefreshed.Unix() != 1622505600 {
+		t.Errorf("expected the refresh time of the bundle to be kept, got %s", lastRefreshed)
+	}
+}
+
+func TestCatalogueRoundTrip(t *testing.T) {
+	source, _ := seedCatalogue(t)
+	sourceRecords := catalogueRecords(t, source, iowrappers.CatalogueFilter{})
+
+	for _, format := range []string{iowrappers.CatalogueFormatNDJSON, iowrappers.CatalogueFormatGeoJSON} {
+		var bundle bytes.Buffer
+		exportStats, err := source.ExportCatalogue(context.Background(), &bundle, format, iowrappers.CatalogueFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, target, _ := createRedis(t)
+		importStats, err := target.ImportCatalogue(context.Background(), &bundle)
+		if err != nil {
+			t.Fatalf("failed to import the %s bundle: %v", format, err)
+		}
+		if exportStats != importStats {
+			t.Errorf("expected the %s bundle to be imported as exported, exported %+v, imported %+v", format, exportStats, importStats)
+		}
+		if targetRecords := catalogueRecords(t, target, iowrappers.CatalogueFilter{}); strings.Join(targetRecords, "\n") != strings.Join(sourceRecords, "\n") {
+			t.Errorf("expected the catalogue imported from the %s bundle to be the same, got\n%s", format, strings.Join(targetRecords, "\n"))
+		}
+	}
+}
+
+func TestCatalogueFilters(t *testing.T) {
+	redisClient, _ := seedCatalogue(t)
+
+	chicago := catalogueRecords(t, redisClient, iowrappers.CatalogueFilter{City: "Chi-Town", Country: "USA"})
+	if len(chicago) != 8 || strings.Contains(strings.Join(chicago, "\n"), "toronto") {
+		t.Errorf("expected Chicago with 4 aliases and 3 places, got\n%s", strings.Join(chicago, "\n"))
+	}
+
+	bbox, err := iowrappers.ParseBoundingBox("-80,43,-79,44")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle bytes.Buffer
+	stats, err := redisClient.ExportCatalogue(context.Background(), &bundle, iowrappers.CatalogueFormatGeoJSON, iowrappers.CatalogueFilter{BoundingBox: bbox})
+	if err != nil || stats != (iowrappers.CatalogueStats{Places: 1, Cities: 1, Aliases: 2}) {
+		t.Errorf("expected Toronto with 2 aliases and 1 place, got %+v, %v", stats, err)
+	}
+	if !strings.Contains(bundle.String(), `"id":"catalogue-tor-rom","geometry":{"type":"Point","coordinates":[-79.3948,43.6677]}`) {
+		t.Errorf("expected the museum in Toronto as a GeoJSON feature, got %s", bundle.String())
+	}
+
+	bundle.Reset()
+	if _, err = redisClient.ExportCatalogue(context.Background(), &bundle, iowrappers.CatalogueFormatNDJSON, iowrappers.CatalogueFilter{City: "Atlantis", Country: "Greece"}); err == nil || bundle.Len() != 0 {
+		t.Errorf("expected nothing to be exported for unknown cities, got %q, %v", bundle.String(), err)
+	}
+	if _, err = iowrappers.ParseBoundingBox("-79,43,-80,44"); err == nil {
+		t.Error("expected bounding boxes with minimums greater than maximums to be invalid")
+	}
+}
+
+func TestCatalogueImportValidation(t *testing.T) {
+	_, redisClient, _ := createRedis(t)
+	for description, bundle := range map[string]string{
+		"newer version": `{"type":"header","format":"vacation-planner-catalogue","version":2}`,
+		"other format":  `{"type":"header","format":"places","version":1}`,
+		"no header":     `{"type":"city","city":"chicago","country":"usa","location":[-87.6298,41.8781]}`,
+		"unknown geo index": `{"type":"header","format":"vacation-planner-catalogue","version":1}` + "\n" +
+			`{"type":"place","place":{"ID":"catalogue-1"},"indexes":["user:"]}`,
+	} {
+		if _, err := redisClient.ImportCatalogue(context.Background(), strings.NewReader(bundle)); !errors.Is(err, iowrappers.ErrInvalidCatalogueBundle) {
+			t.Errorf("expected the bundle of %s to be invalid, got %v", description, err)
+		}
+	}
+}
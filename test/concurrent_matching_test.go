@@ -0,0 +1,117 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+// citySearchClient places each city at its own latitude, and returns places with IDs prefixed by the city of the request after a random delay
+type citySearchClient struct {
+	cities    []string
+	numPlaces int
+}
+
+func (client citySearchClient) GetGeocode(_ context.Context, query *iowrappers.GeocodeQuery) (float64, float64, error) {
+	for idx, city := range client.cities {
+		if city == query.City {
+			return float64(idx), 0, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("unknown city %s", query.City)
+}
+
+func (client citySearchClient) NearbySearch(_ context.Context, request *iowrappers.PlaceSearchRequest) ([]POI.Place, error) {
+	// the location of the request is lat,lng of the city
+	lat, err := strconv.ParseFloat(strings.Split(request.Location, ",")[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	city := client.cities[int(lat)]
+	// let concurrent searches interleave
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+	places := make([]POI.Place, client.numPlaces)
+	for idx := range places {
+		places[idx] = POI.Place{
+			ID:               fmt.Sprintf("%s_%s_%d", city, request.PlaceCat, idx),
+			Name:             fmt.Sprintf("%s %s %d", city, request.PlaceCat, idx),
+			LocationType:     POI.LocationTypeMuseum,
+			PriceLevel:       1 + idx%4,
+			Rating:           3 + float32(idx%3),
+			UserRatingsTotal: 100 * (idx + 1),
+			Hours:            [7]string{"Open 24 hours", "Open 24 hours", "Open 24 hours", "Open 24 hours", "Open 24 hours", "Open 24 hours", "Open 24 hours"},
+		}
+		if request.PlaceCat == POI.PlaceCategoryEatery {
+			places[idx].LocationType = POI.LocationTypeRestaurant
+		}
+		places[idx].SetLocation([2]float64{float64(idx) / 1000, lat + float64(idx)/1000})
+	}
+	return places, nil
+}
+
+func (client citySearchClient) PlaceDetailsSearch(context.Context, string) (POI.Place, error) {
+	return POI.Place{}, nil
+}
+
+func TestConcurrentSolve(t *testing.T) {
+	redisURL, redisClient, _ := createRedis(t)
+	cities := []string{"chicago", "paris", "tokyo", "sydney", "cairo", "lima"}
+	solver := solution.Solver{}
+	solver.Init(iowrappers.CreatePoiSearcherWithSearchClient(citySearchClient{cities: cities, numPlaces: 6}, redisURL))
+	slots := []solution.SlotRequest{
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 8, End: 10}}, Category: POI.PlaceCategoryEatery},
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 10, End: 13}}, Category: POI.PlaceCategoryVisit},
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 13, End: 15}}, Category: POI.PlaceCategoryVisit},
+	}
+
+	const numRequests = 60
+	errs := make(chan error, numRequests)
+	var wg sync.WaitGroup
+	for requestIdx := 0; requestIdx < numRequests; requestIdx++ {
+		wg.Add(1)
+		go func(city string) {
+			defer wg.Done()
+			req := solution.PlanningRequest{
+				Location:     city + ",country",
+				Slots:        slots,
+				Weekday:      POI.DateMonday,
+				NumPlans:     3,
+				SearchRadius: 5000,
+			}
+			resp := solution.PlanningResponse{}
+			solver.Solve(context.Background(), redisClient, &req, &resp)
+			if resp.Err != nil {
+				errs <- fmt.Errorf("%s: %v", city, resp.Err)
+				return
+			}
+			if len(resp.Solutions) == 0 {
+				errs <- fmt.Errorf("%s: no plans found", city)
+			}
+			for _, plan := range resp.Solutions {
+				for _, placeId := range plan.PlaceIDS {
+					if !strings.HasPrefix(placeId, city+"_") {
+						errs <- fmt.Errorf("%s: plan has place %s of another city", city, placeId)
+						return
+					}
+				}
+			}
+		}(cities[requestIdx%len(cities)])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// urlDetailsServer serves the URL of refresh-museum and NOT_FOUND for other places, and counts details searches
+type urlDetailsServer struct {
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func (server *urlDetailsServer) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	placeId := req.URL.Query().Get("placeid")
+	server.mutex.Lock()
+	server.calls[placeId]++
+	server.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if placeId != "refresh-museum" {
+		_, _ = writer.Write([]byte(`{"status": "NOT_FOUND"}`))
+		return
+	}
+	_, _ = writer.Write([]byte(`{"status": "OK", "result": {"place_id": "refresh-museum", "url": "https://maps.google.com/?cid=42"}}`))
+}
+
+func (server *urlDetailsServer) callsOf(placeId string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.calls[placeId]
+}
+
+func TestUrlMigration(t *testing.T) {
+	_, redisClient, redisServer := createRedis(t)
+	detailsServer := &urlDetailsServer{calls: make(map[string]int)}
+	server := httptest.NewServer(detailsServer)
+	defer server.Close()
+	mapsClient := iowrappers.CreateReplayMapsClient(server.URL)
+	ctx := context.Background()
+
+	places := make([]POI.Place, 0)
+	for _, placeId := range []string{"refresh-museum", "refresh-gone", "osm-node-1"} {
+		places = append(places, POI.Place{ID: placeId, Name: placeId, Status: POI.Operational, LocationType: POI.LocationTypeMuseum,
+			Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6237, 41.8796}}})
+	}
+	redisClient.SetPlacesOnCategory(ctx, places)
+	migration := iowrappers.CreateUrlMigration(&mapsClient, &redisClient)
+
+	// dry runs report places of Google Maps not updated yet without searching place details
+	batch, err := migration.Batch(ctx, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Done || batch.Processed != 3 || len(batch.Changes) != 2 || len(batch.Errors) != 0 {
+		t.Errorf("expected the museum and the missing place to need update, got %+v", batch)
+	}
+	for _, placeId := range []string{"refresh-museum", "refresh-gone", "osm-node-1"} {
+		if calls := detailsServer.callsOf(placeId); calls != 0 {
+			t.Errorf("expected no details searches of %s in the dry run, got %d", placeId, calls)
+		}
+	}
+	if museum := cachedPlace(t, redisServer, "refresh-museum"); museum.URL != "" {
+		t.Errorf("expected no changes written by the dry run, got URL %s", museum.URL)
+	}
+
+	batch, err = migration.Batch(ctx, "", false)
+	if err != nil || len(batch.Changes) != 1 || !strings.Contains(batch.Changes[0], "https://maps.google.com/?cid=42") {
+		t.Fatalf("expected the museum to be updated, got %+v, %v", batch, err)
+	}
+	if len(batch.Errors) != 1 || !strings.Contains(batch.Errors[0], "refresh-gone") {
+		t.Errorf("expected the details search of the missing place to fail, got %v", batch.Errors)
+	}
+	if museum := cachedPlace(t, redisServer, "refresh-museum"); museum.URL != "https://maps.google.com/?cid=42" {
+		t.Errorf("expected the URL of the museum to be updated, got %s", museum.URL)
+	}
+	// updated places are skipped, and places of failed details searches are searched again
+	if batch, err = migration.Batch(ctx, "", false); err != nil || len(batch.Changes) != 0 || detailsServer.callsOf("refresh-museum") != 1 ||
+		detailsServer.callsOf("refresh-gone") != 2 {
+		t.Errorf("expected only the missing place to be searched again, got %+v, %v", batch, err)
+	}
+	if detailsServer.callsOf("osm-node-1") != 0 {
+		t.Error("expected places of OpenStreetMap not to be searched")
+	}
+}
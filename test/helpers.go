@@ -0,0 +1,24 @@
+package test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// createRedis starts a Redis in memory which is closed when the test finishes,
+// and returns the URL of the Redis with a client of it
+func createRedis(t *testing.T) (*url.URL, iowrappers.RedisClient, *miniredis.Miniredis) {
+	if err := iowrappers.CreateLogger(); err != nil {
+		t.Fatal(err)
+	}
+	redisServer, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(redisServer.Close)
+	redisURL, _ := url.Parse("redis://" + redisServer.Addr())
+	return redisURL, iowrappers.CreateRedisClient(redisURL), redisServer
+}
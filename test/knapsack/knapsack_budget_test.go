@@ -0,0 +1,54 @@
+package knapsack
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"testing"
+)
+
+func createBudgetTestPlace(id string, locationType POI.LocationType, priceLevel int, location [2]float64) matching.Place {
+	place := POI.Place{ID: id, Name: id, LocationType: locationType, PriceLevel: priceLevel, Rating: 4.5, UserRatingsTotal: 1000}
+	place.SetLocation(location)
+	return matching.CreatePlace(place, POI.GetPlaceCategory(locationType))
+}
+
+func TestKnapsackSpendsExactBudget(t *testing.T) {
+	places := []matching.Place{
+		createBudgetTestPlace("museum", POI.LocationTypeMuseum, 2, [2]float64{-87.62, 41.88}),
+		createBudgetTestPlace("restaurant", POI.LocationTypeRestaurant, 2, [2]float64{-87.63, 41.88}),
+	}
+	interval := matching.QueryTimeInterval{Day: POI.DateMonday, StartHour: 10, EndHour: 18}
+
+	results, totalCost, totalTime := matching.KnapsackWithObjective(places, interval, 60, matching.TotalScore)
+	if len(results) != 2 {
+		t.Fatalf("expected both places to fit in the budget, got %d places", len(results))
+	}
+	if totalCost != 60 {
+		t.Errorf("expected total cost of 60, got %d", totalCost)
+	}
+	if totalTime != 4 {
+		t.Errorf("expected total time of 4 hours, got %d", totalTime)
+	}
+
+	// a place does not fit after the budget is used up
+	places = append(places, createBudgetTestPlace("gallery", POI.LocationTypeGallery, 1, [2]float64{-87.63, 41.89}))
+	_, totalCost, _ = matching.KnapsackWithObjective(places, interval, 60, matching.TotalScore)
+	if totalCost > 60 {
+		t.Errorf("total cost %d exceeds the budget", totalCost)
+	}
+}
+
+func TestKnapsackPlacesWithoutPriceLevel(t *testing.T) {
+	places := []matching.Place{
+		createBudgetTestPlace("park", POI.LocationTypePark, -1, [2]float64{-87.62, 41.88}),
+	}
+	interval := matching.QueryTimeInterval{Day: POI.DateMonday, StartHour: 10, EndHour: 18}
+
+	results, totalCost, _ := matching.KnapsackWithObjective(places, interval, 10, matching.TotalScore)
+	if len(results) != 1 {
+		t.Fatal("expected places without price level to be selected")
+	}
+	if totalCost != 0 {
+		t.Errorf("expected places without price level to be free, got cost %d", totalCost)
+	}
+}
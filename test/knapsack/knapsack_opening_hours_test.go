@@ -0,0 +1,93 @@
+package knapsack
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+	"testing"
+)
+
+// verify a knapsack schedule by visiting places in order without idle time between places
+func checkScheduleFeasibility(t *testing.T, schedule []matching.Place, interval matching.QueryTimeInterval, closedPlaces map[string]bool) {
+	currentHour := interval.StartHour
+	for _, place := range schedule {
+		if closedPlaces[place.GetPlaceId()] {
+			t.Errorf("place %s is closed but scheduled", place.GetPlaceName())
+		}
+		stayTime := uint8(POI.GetStayingTimeForLocationType(place.GetPlaceType()))
+		stayInterval := matching.QueryTimeInterval{Day: interval.Day, StartHour: currentHour, EndHour: interval.EndHour}
+		if !place.IsOpenBetween(stayInterval, stayTime) {
+			t.Errorf("place %s with opening hours %s is scheduled during %d-%d", place.GetPlaceName(),
+				place.GetHours()[interval.Day], currentHour, currentHour+stayTime)
+		}
+		currentHour += stayTime
+	}
+}
+
+func TestKnapsackOpeningHours(t *testing.T) {
+	placesFromData := make([]POI.Place, 0)
+	if err := utils.ReadFromFile("data/random_gen_visiting_places_for_test.json", &placesFromData); err != nil {
+		t.Fatal("Json file read error")
+	}
+
+	openingHours := []string{
+		"Monday: 2:00 PM – 6:00 PM",
+		"Monday: Closed",
+		"Monday: 9:00 AM – 11:00 AM, 1:00 – 5:00 PM",
+		"Monday: Open 24 hours",
+		"Monday: 10:30 AM – 4:00 PM",
+	}
+
+	closedPlaces := make(map[string]bool)
+	places := make([]matching.Place, 0)
+	for idx, p := range placesFromData {
+		if idx >= 20 {
+			break
+		}
+		p.Hours[POI.DateMonday] = openingHours[idx%len(openingHours)]
+		if idx%len(openingHours) == 1 {
+			closedPlaces[p.ID] = true
+		}
+		places = append(places, matching.CreatePlace(p, POI.PlaceCategoryVisit))
+	}
+
+	budget := uint(80)
+	interval := matching.QueryTimeInterval{StartHour: 8, Day: POI.DateMonday, EndHour: 18}
+
+	result := matching.KnapsackV1(places, interval, budget)
+	if len(result) == 0 {
+		t.Error("No result is returned.")
+	}
+	checkScheduleFeasibility(t, result, interval, closedPlaces)
+
+	result2, _, _ := matching.Knapsack(places, interval, budget)
+	if len(result2) == 0 {
+		t.Error("No result is returned by v2")
+	}
+	checkScheduleFeasibility(t, result2, interval, closedPlaces)
+}
+
+func TestIsOpenBetween(t *testing.T) {
+	place := matching.CreatePlace(POI.Place{
+		Hours: [7]string{"Monday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM"},
+	}, POI.PlaceCategoryEatery)
+
+	testCases := []struct {
+		startHour uint8
+		stay      uint8
+		expected  bool
+	}{
+		{12, 2, true},
+		{11, 1, false},
+		{13, 2, false},
+		{17, 5, true},
+		{20, 3, false},
+	}
+	for _, testCase := range testCases {
+		interval := matching.QueryTimeInterval{Day: POI.DateMonday, StartHour: testCase.startHour, EndHour: 23}
+		if place.IsOpenBetween(interval, testCase.stay) != testCase.expected {
+			t.Errorf("expected IsOpenBetween to be %t for a stay of %d hours from %d",
+				testCase.expected, testCase.stay, testCase.startHour)
+		}
+	}
+}
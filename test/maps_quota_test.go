@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func createMapsMeter(t *testing.T, config iowrappers.MapsQuotaConfig) (*iowrappers.MapsMeter, *url.URL) {
+	redisURL, redisClient, _ := createRedis(t)
+	return iowrappers.CreateMapsMeter(&redisClient, config), redisURL
+}
+
+func today() string {
+	return time.Now().UTC().Format(iowrappers.MapsQuotaDateLayout)
+}
+
+func TestMapsMeterCounts(t *testing.T) {
+	meter, _ := createMapsMeter(t, iowrappers.MapsQuotaConfig{})
+	ctx := context.WithValue(context.Background(), iowrappers.RequestIdKey, "request-1")
+	ctx = context.WithValue(ctx, iowrappers.UsernameKey, "alice")
+
+	for _, sku := range []string{iowrappers.MapsSKUNearbySearch, iowrappers.MapsSKUNearbySearch, iowrappers.MapsSKUPlaceDetails} {
+		if err := meter.Acquire(ctx, sku); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := meter.Acquire(context.Background(), iowrappers.MapsSKUGeocoding); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := meter.Stats(context.Background(), today(), "alice", "request-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Calls[iowrappers.MapsSKUNearbySearch] != 2 || stats.Calls[iowrappers.MapsSKUPlaceDetails] != 1 || stats.Calls[iowrappers.MapsSKUGeocoding] != 1 {
+		t.Errorf("unexpected calls of the day %v", stats.Calls)
+	}
+	if len(stats.UserCalls) != 2 || stats.UserCalls[iowrappers.MapsSKUNearbySearch] != 2 {
+		t.Errorf("unexpected calls of the user %v", stats.UserCalls)
+	}
+	if len(stats.RequestCalls) != 2 || stats.RequestCalls[iowrappers.MapsSKUPlaceDetails] != 1 {
+		t.Errorf("unexpected calls of the request %v", stats.RequestCalls)
+	}
+	if expectedSpend := 0.032*2 + 0.017 + 0.005; stats.Spend < expectedSpend-1e-9 || stats.Spend > expectedSpend+1e-9 {
+		t.Errorf("expected spend of %f, got %f", expectedSpend, stats.Spend)
+	}
+	if stats.CacheOnly {
+		t.Error("expected no cache-only mode without budget")
+	}
+
+	// a nil meter allows every call
+	var nilMeter *iowrappers.MapsMeter
+	if err = nilMeter.Acquire(ctx, iowrappers.MapsSKUNearbySearch); err != nil || nilMeter.CacheOnly(ctx) {
+		t.Error("expected a nil meter to allow calls")
+	}
+}
+
+func TestMapsMeterDailyBudget(t *testing.T) {
+	meter, _ := createMapsMeter(t, iowrappers.MapsQuotaConfig{DailyBudget: 0.05})
+	ctx := context.Background()
+
+	for call := 0; call < 2; call++ {
+		if err := meter.Acquire(ctx, iowrappers.MapsSKUNearbySearch); err != nil {
+			t.Fatalf("expected call %d within budget, got %v", call, err)
+		}
+	}
+	if !meter.CacheOnly(ctx) {
+		t.Error("expected cache-only mode over budget")
+	}
+	if err := meter.Acquire(ctx, iowrappers.MapsSKUGeocoding); err != iowrappers.ErrMapsBudgetExceeded {
+		t.Errorf("expected budget exceeded error, got %v", err)
+	}
+	stats, _ := meter.Stats(ctx, today(), "", "")
+	if !stats.CacheOnly || stats.Calls[iowrappers.MapsSKUGeocoding] != 0 {
+		t.Errorf("expected calls over budget not to be made, got %+v", stats)
+	}
+}
+
+func TestMapsMeterRateLimit(t *testing.T) {
+	meter, _ := createMapsMeter(t, iowrappers.MapsQuotaConfig{RateLimit: 20, Burst: 2})
+
+	// 2 calls of the burst and 3 calls waiting 50 milliseconds each
+	startTime := time.Now()
+	for call := 0; call < 5; call++ {
+		if err := meter.Acquire(context.Background(), iowrappers.MapsSKUPlaceDetails); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(startTime); elapsed < 120*time.Millisecond {
+		t.Errorf("expected calls to wait for tokens, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := meter.Acquire(ctx, iowrappers.MapsSKUPlaceDetails); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline to end the wait, got %v", err)
+	}
+}
+
+func TestMeteredReplayNearbySearch(t *testing.T) {
+	meter, redisURL := createMapsMeter(t, iowrappers.MapsQuotaConfig{})
+	mapsClient, closeServer := createReplayMapsClient(t)
+	defer closeServer()
+	mapsClient.SetMeter(meter)
+
+	ctx := context.WithValue(context.Background(), iowrappers.RequestIdKey, "request-2")
+	if _, err := mapsClient.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000, MinNumResults: 6,
+	}); err != nil && !errors.As(err, new(*iowrappers.PartialNearbySearchError)) {
+		t.Fatal(err)
+	}
+	stats, err := meter.Stats(ctx, today(), "", "request-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 pages of museums, parks, amusement parks and art galleries, and details of 2 parks
+	if stats.RequestCalls[iowrappers.MapsSKUNearbySearch] != 5 || stats.RequestCalls[iowrappers.MapsSKUPlaceDetails] != 2 {
+		t.Errorf("unexpected calls of the request %v", stats.RequestCalls)
+	}
+
+	// the spend of the search above is over the budget, and places are only served from cache
+	poiSearcher := iowrappers.CreatePoiSearcherWithSearchClient(&mapsClient, redisURL)
+	poiSearcher.SetMapsMeter(iowrappers.CreateMapsMeter(poiSearcher.GetRedisClient(), iowrappers.MapsQuotaConfig{DailyBudget: 0.1}))
+	poiSearcher.GetRedisClient().SetGeocode(ctx, iowrappers.GeocodeQuery{City: "chicago", Country: "USA"}, 41.8781, -87.6298,
+		iowrappers.GeocodeQuery{City: "chicago", Country: "USA"})
+	places, err := poiSearcher.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "chicago,USA", Radius: 10000, MinNumResults: 6,
+	})
+	if err != nil || len(places) != 0 {
+		t.Errorf("expected no places from the empty cache, got %d places, %v", len(places), err)
+	}
+	if _, err = poiSearcher.GetRedisClient().GetMapsLastSearchTime(ctx, "chicago,USA", POI.PlaceCategoryVisit); err == nil {
+		t.Error("expected no last search time in cache-only mode")
+	}
+	stats, _ = meter.Stats(ctx, today(), "", "")
+	if stats.Calls[iowrappers.MapsSKUNearbySearch] != 5 {
+		t.Errorf("expected no calls in cache-only mode, got %v", stats.Calls)
+	}
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// cassettes of the test are recorded from a local server giving Google Maps responses of Chicago
+const mapsCassetteDir = "testdata/maps_cassettes"
+
+var replayDetailedSearchFields = []string{"name", "opening_hours", "formatted_address", "adr_address", "url"}
+
+func createReplayMapsClient(t *testing.T) (iowrappers.MapsClient, func()) {
+	server, err := iowrappers.CreateMapsReplayServer(mapsCassetteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapsClient := iowrappers.CreateReplayMapsClient(server.URL)
+	mapsClient.SetDetailedSearchFields(replayDetailedSearchFields)
+	return mapsClient, server.Close
+}
+
+func TestReplayGeocoding(t *testing.T) {
+	mapsClient, closeServer := createReplayMapsClient(t)
+	defer closeServer()
+	ctx := context.Background()
+
+	lat, lng, err := mapsClient.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "chicago", Country: "USA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat != 41.8781136 || lng != -87.6297982 {
+		t.Errorf("expected geocode of Chicago to be (41.8781136, -87.6297982), got (%f, %f)", lat, lng)
+	}
+
+	// recorded with ZERO_RESULTS
+	if _, _, err = mapsClient.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Atlantis", Country: "Atlantis"}); err == nil {
+		t.Error("expected an error for a city without geocode")
+	}
+
+	// not recorded
+	if _, _, err = mapsClient.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Paris", Country: "France"}); err == nil {
+		t.Error("expected an error for a request without cassette")
+	}
+}
+
+func TestReplayReverseGeocoding(t *testing.T) {
+	mapsClient, closeServer := createReplayMapsClient(t)
+	defer closeServer()
+
+	query, err := mapsClient.ReverseGeocoding(context.Background(), 41.8781, -87.6298)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.City != "Chicago" || query.Country != "United States" {
+		t.Errorf("expected Chicago, United States, got %s, %s", query.City, query.Country)
+	}
+}
+
+func TestReplayExtensiveNearbySearch(t *testing.T) {
+	mapsClient, closeServer := createReplayMapsClient(t)
+	defer closeServer()
+
+	places, err := mapsClient.NearbySearch(context.Background(), &iowrappers.PlaceSearchRequest{
+		PlaceCat:      POI.PlaceCategoryVisit,
+		Location:      "41.878100,-87.629800",
+		Radius:        16000,
+		MinNumResults: 6,
+	})
+	// the details search of a park fails, and its place is kept without details
+	var partialErr *iowrappers.PartialNearbySearchError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expected partial results, got %v", err)
+	}
+	if failedDetails := partialErr.Result.FailedDetails; len(failedDetails) != 1 || failedDetails[0] != "cassette-park-2" {
+		t.Errorf("expected the failed details search of cassette-park-2, got %v", failedDetails)
+	}
+	if museums := partialErr.Result.PlaceTypes[POI.LocationTypeMuseum]; museums.Pages != 2 || !museums.Complete || museums.Err != nil {
+		t.Errorf("expected 2 pages of museums, got %+v", museums)
+	}
+
+	placesById := make(map[string]POI.Place)
+	for _, place := range places {
+		placesById[place.ID] = place
+	}
+	if len(places) != 6 || len(placesById) != 6 {
+		t.Fatalf("expected 6 distinct places, got %d places", len(places))
+	}
+	// the second page of museums
+	if _, exists := placesById["cassette-museum-3"]; !exists {
+		t.Error("expected places of the next page token")
+	}
+
+	// parks have no opening hours in nearby search results, and only the details search of the first park succeeds
+	park := placesById["cassette-park-1"]
+	if park.Hours[POI.DateMonday] != "Monday: 6:00 AM – 11:00 PM" {
+		t.Errorf("expected opening hours from details search, got %s", park.Hours[POI.DateMonday])
+	}
+	if park.FormattedAddress != "201 E Randolph St, Chicago, IL 60602, USA" {
+		t.Errorf("expected address from details search, got %s", park.FormattedAddress)
+	}
+	if _, exists := placesById["cassette-park-2"]; !exists {
+		t.Error("expected places with failed details search to be kept")
+	}
+}
+
+func TestReplayNearbySearchTimeout(t *testing.T) {
+	handler, err := iowrappers.MapsReplayHandler(mapsCassetteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			handler.ServeHTTP(writer, req)
+		case <-req.Context().Done():
+		}
+	}))
+	defer slowServer.Close()
+
+	mapsClient := iowrappers.CreateReplayMapsClient(slowServer.URL)
+	mapsClient.SetDetailedSearchFields(replayDetailedSearchFields)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	places, err := mapsClient.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat:      POI.PlaceCategoryVisit,
+		Location:      "41.878100,-87.629800",
+		Radius:        16000,
+		MinNumResults: 6,
+	})
+	var partialErr *iowrappers.PartialNearbySearchError
+	if !errors.As(err, &partialErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected partial results of the context deadline, got %v", err)
+	}
+	if len(places) != 0 || partialErr.Result.PlaceTypes[POI.LocationTypePark].Pages != 0 {
+		t.Errorf("expected no places after time out, got %d", len(places))
+	}
+	if time.Since(startTime) > 500*time.Millisecond {
+		t.Errorf("expected the search to return at the deadline, took %s", time.Since(startTime))
+	}
+}
+
+func TestMapsRecorderScrubsApiKey(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		_, _ = writer.Write([]byte(`{"results": [], "status": "ZERO_RESULTS"}`))
+	}))
+	defer upstream.Close()
+
+	cassetteDir := t.TempDir()
+	client := &http.Client{Transport: &iowrappers.MapsRecorder{CassetteDir: cassetteDir}}
+	resp, err := client.Get(upstream.URL + "/maps/api/geocode/json?address=Atlantis&key=SECRET-API-KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "ZERO_RESULTS") {
+		t.Errorf("expected the recorder to pass the response through, got %s", body)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cassetteDir, "geocode-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 geocode cassette, got %d", len(files))
+	}
+	cassette, _ := ioutil.ReadFile(files[0])
+	if strings.Contains(string(cassette), "SECRET-API-KEY") {
+		t.Error("expected the API key to be scrubbed from the cassette")
+	}
+
+	// the recorded exchange is replayed for the same request with any key
+	handler, err := iowrappers.MapsReplayHandler(cassetteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/maps/api/geocode/json?key=replay&address=Atlantis", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "ZERO_RESULTS") {
+		t.Errorf("expected the cassette to be replayed, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// flakyMapsServer fails the first requests of each path and place ID before serving cassettes
+type flakyMapsServer struct {
+	failures func(req *http.Request, attempt int) bool
+	handler  http.Handler
+	mutex    sync.Mutex
+	attempts map[string]int
+}
+
+func (server *flakyMapsServer) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	key := req.URL.Path + "?" + req.URL.Query().Get("placeid") + req.URL.Query().Get("type") + req.URL.Query().Get("pagetoken")
+	server.mutex.Lock()
+	server.attempts[key]++
+	attempt := server.attempts[key]
+	server.mutex.Unlock()
+	if server.failures(req, attempt) {
+		if strings.HasSuffix(req.URL.Path, "/details/json") {
+			writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			_, _ = writer.Write([]byte(`{"status": "OVER_QUERY_LIMIT", "error_message": "You have exceeded your rate-limit for this API."}`))
+			return
+		}
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	server.handler.ServeHTTP(writer, req)
+}
+
+func (server *flakyMapsServer) attemptsOf(path string, suffix string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.attempts[path+"?"+suffix]
+}
+
+func createFlakyMapsClient(t *testing.T, failures func(req *http.Request, attempt int) bool) (iowrappers.MapsClient, *flakyMapsServer, func()) {
+	handler, err := iowrappers.MapsReplayHandler(mapsCassetteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flakyServer := &flakyMapsServer{failures: failures, handler: handler, attempts: make(map[string]int)}
+	server := httptest.NewServer(flakyServer)
+
+	mapsClient := iowrappers.CreateReplayMapsClient(server.URL)
+	mapsClient.SetDetailedSearchFields(replayDetailedSearchFields)
+	mapsClient.SetRetryBaseDelay(10 * time.Millisecond)
+	return mapsClient, flakyServer, server.Close
+}
+
+var chicagoVisitRequest = iowrappers.PlaceSearchRequest{
+	PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000, MinNumResults: 6,
+}
+
+func TestNearbySearchRetries(t *testing.T) {
+	// the first attempts of nearby searches and the details search of cassette-park-1 fail with transient errors
+	mapsClient, server, closeServer := createFlakyMapsClient(t, func(req *http.Request, attempt int) bool {
+		return attempt < iowrappers.MapsMaxAttempts && req.URL.Query().Get("placeid") != "cassette-park-2"
+	})
+	defer closeServer()
+
+	request := chicagoVisitRequest
+	places, err := mapsClient.NearbySearch(context.Background(), &request)
+	var partialErr *iowrappers.PartialNearbySearchError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expected partial results of the denied details search, got %v", err)
+	}
+	if len(places) != 6 || len(partialErr.Result.FailedDetails) != 1 {
+		t.Errorf("expected 6 places and 1 failed details search, got %d places, %v", len(places), partialErr.Result.FailedDetails)
+	}
+	if attempts := server.attemptsOf("/maps/api/place/nearbysearch/json", "museum"); attempts != iowrappers.MapsMaxAttempts {
+		t.Errorf("expected %d attempts of the nearby search of museums, got %d", iowrappers.MapsMaxAttempts, attempts)
+	}
+	if attempts := server.attemptsOf("/maps/api/place/details/json", "cassette-park-1"); attempts != iowrappers.MapsMaxAttempts {
+		t.Errorf("expected %d attempts of the details search of cassette-park-1, got %d", iowrappers.MapsMaxAttempts, attempts)
+	}
+	// REQUEST_DENIED of the replayed details search is not retried
+	if attempts := server.attemptsOf("/maps/api/place/details/json", "cassette-park-2"); attempts != 1 {
+		t.Errorf("expected no retry after REQUEST_DENIED, got %d attempts", attempts)
+	}
+}
+
+func TestNearbySearchPartialResults(t *testing.T) {
+	// nearby searches of parks always fail
+	mapsClient, server, closeServer := createFlakyMapsClient(t, func(req *http.Request, attempt int) bool {
+		return req.URL.Query().Get("type") == string(POI.LocationTypePark)
+	})
+	defer closeServer()
+
+	request := chicagoVisitRequest
+	places, err := mapsClient.NearbySearch(context.Background(), &request)
+	var partialErr *iowrappers.PartialNearbySearchError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expected partial results, got %v", err)
+	}
+	var statusCodeErr *iowrappers.MapsStatusCodeError
+	if !errors.As(err, &statusCodeErr) || statusCodeErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the status code of the failed searches of parks, got %v", err)
+	}
+	parks := partialErr.Result.PlaceTypes[POI.LocationTypePark]
+	if parks.Err == nil || parks.Pages != 0 {
+		t.Errorf("expected failed searches of parks, got %+v", parks)
+	}
+	if museums := partialErr.Result.PlaceTypes[POI.LocationTypeMuseum]; museums.Err != nil || museums.Pages != 2 {
+		t.Errorf("expected 2 pages of museums, got %+v", museums)
+	}
+	for _, place := range places {
+		if place.LocationType == POI.LocationTypePark {
+			t.Errorf("expected no parks, got %s", place.ID)
+		}
+	}
+	if len(places) == 0 {
+		t.Error("expected places of other place types")
+	}
+	if attempts := server.attemptsOf("/maps/api/place/nearbysearch/json", "park"); attempts != iowrappers.MapsMaxAttempts {
+		t.Errorf("expected parks not to be searched after %d attempts, got %d", iowrappers.MapsMaxAttempts, attempts)
+	}
+}
+
+func TestPoiSearcherPartialResults(t *testing.T) {
+	// nearby searches of parks always fail
+	mapsClient, flakyServer, closeServer := createFlakyMapsClient(t, func(req *http.Request, attempt int) bool {
+		return req.URL.Query().Get("type") == string(POI.LocationTypePark)
+	})
+	defer closeServer()
+	redisURL, _, redisServer := createRedis(t)
+	poiSearcher := iowrappers.CreatePoiSearcherWithSearchClient(&mapsClient, redisURL)
+	ctx := context.Background()
+	poiSearcher.GetRedisClient().SetGeocode(ctx, iowrappers.GeocodeQuery{City: "chicago", Country: "USA"}, 41.8781, -87.6298,
+		iowrappers.GeocodeQuery{City: "chicago", Country: "USA"})
+
+	// places of other place types are returned with the error of the partial search
+	places, err := poiSearcher.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "chicago,USA", Radius: 10000, MinNumResults: 6,
+	})
+	if !errors.As(err, new(*iowrappers.PartialNearbySearchError)) || len(places) == 0 {
+		t.Errorf("expected places with a partial search error, got %d places, %v", len(places), err)
+	}
+	if _, err = poiSearcher.GetRedisClient().GetMapsLastSearchTime(ctx, "chicago,USA", POI.PlaceCategoryVisit); err == nil {
+		t.Error("expected no last search time of a partial search")
+	}
+
+	// the next requests use the cached places until the backoff of the partial search expires
+	parkAttempts := flakyServer.attemptsOf("/maps/api/place/nearbysearch/json", string(POI.LocationTypePark))
+	cachedPlaces, err := poiSearcher.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "chicago,USA", Radius: 10000, MinNumResults: 6,
+	})
+	if err != nil || len(cachedPlaces) == 0 {
+		t.Errorf("expected cached places during the backoff, got %d places, %v", len(cachedPlaces), err)
+	}
+	if attempts := flakyServer.attemptsOf("/maps/api/place/nearbysearch/json", string(POI.LocationTypePark)); attempts != parkAttempts {
+		t.Errorf("expected no searches during the backoff, got %d more", attempts-parkAttempts)
+	}
+
+	// the location is searched again once the backoff expires
+	redisServer.FastForward(iowrappers.MapsPartialSearchBackoff)
+	if _, err = poiSearcher.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "chicago,USA", Radius: 10000, MinNumResults: 6,
+	}); !errors.As(err, new(*iowrappers.PartialNearbySearchError)) {
+		t.Errorf("expected the location to be searched again after the backoff, got %v", err)
+	}
+}
+
+func TestNearbySearchCancellation(t *testing.T) {
+	mapsClient, _, closeServer := createFlakyMapsClient(t, func(req *http.Request, attempt int) bool {
+		return true
+	})
+	defer closeServer()
+	mapsClient.SetRetryBaseDelay(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	startTime := time.Now()
+	request := chicagoVisitRequest
+	places, err := mapsClient.NearbySearch(ctx, &request)
+	if !errors.Is(err, context.Canceled) || len(places) != 0 {
+		t.Errorf("expected no places of the canceled search, got %d places, %v", len(places), err)
+	}
+	if elapsed := time.Since(startTime); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the search to stop waiting for retries at cancellation, took %s", elapsed)
+	}
+}
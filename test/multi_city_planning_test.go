@@ -0,0 +1,34 @@
+package test
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/solution"
+	"testing"
+)
+
+func TestCombineLegTrips(t *testing.T) {
+	seattleTrips := []solution.TripSolution{
+		{Days: []solution.PlanningSolution{{PlaceIDS: []string{"space_needle"}}, {PlaceIDS: []string{"pike_place"}}}, Score: 6},
+	}
+	portlandTrips := []solution.TripSolution{
+		{Days: []solution.PlanningSolution{{PlaceIDS: []string{"powells"}}}, Score: 4},
+		{Days: []solution.PlanningSolution{{PlaceIDS: []string{"pike_place"}}}, Score: 10},
+	}
+
+	trips := solution.CombineTrips([][]solution.TripSolution{seattleTrips, portlandTrips}, 5)
+
+	if len(trips) != 1 {
+		t.Fatalf("expected 1 trip, got %d", len(trips))
+	}
+	if trips[0].Score != 10 {
+		t.Errorf("expected trip score 10, got %f", trips[0].Score)
+	}
+	expectedPlaceIds := []string{"space_needle", "pike_place", "powells"}
+	if len(trips[0].Days) != len(expectedPlaceIds) {
+		t.Fatalf("expected %d days, got %d", len(expectedPlaceIds), len(trips[0].Days))
+	}
+	for day, placeId := range expectedPlaceIds {
+		if trips[0].Days[day].PlaceIDS[0] != placeId {
+			t.Errorf("expected %s on day %d, got %s", placeId, day, trips[0].Days[day].PlaceIDS[0])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+	"testing"
+	"time"
+)
+
+func TestCombineDailySolutions(t *testing.T) {
+	day1 := []solution.PlanningSolution{
+		{PlaceIDS: []string{"museum", "cafe"}, Score: 10},
+		{PlaceIDS: []string{"park", "restaurant"}, Score: 8},
+	}
+	day2 := []solution.PlanningSolution{
+		{PlaceIDS: []string{"museum", "bistro"}, Score: 9},
+		{PlaceIDS: []string{"gallery", "bistro"}, Score: 5},
+	}
+
+	trips := solution.CombineDailySolutions([][]solution.PlanningSolution{day1, day2}, 5)
+
+	// the museum cannot be visited on both days
+	expectedScores := []float64{17, 15, 13}
+	if len(trips) != len(expectedScores) {
+		t.Fatalf("expected %d trips, got %d", len(expectedScores), len(trips))
+	}
+	for idx, trip := range trips {
+		if trip.Score != expectedScores[idx] {
+			t.Errorf("expected score %f for trip %d, got %f", expectedScores[idx], idx, trip.Score)
+		}
+		if len(trip.Days) != 2 {
+			t.Errorf("expected 2 days in trip %d, got %d", idx, len(trip.Days))
+		}
+		seen := make(map[string]bool)
+		for _, day := range trip.Days {
+			for _, placeId := range day.PlaceIDS {
+				if seen[placeId] {
+					t.Errorf("place %s appears more than once in trip %d", placeId, idx)
+				}
+				seen[placeId] = true
+			}
+		}
+	}
+}
+
+func TestMultiDayRequestWeekdays(t *testing.T) {
+	req := solution.MultiDayPlanningRequest{
+		StartDate: time.Date(2020, time.November, 7, 0, 0, 0, 0, time.UTC), // Saturday
+		NumDays:   3,
+		DaySlots:  [][]solution.SlotRequest{solution.GetStandardRequest(POI.DateMonday, 0).Slots},
+	}
+
+	expectedWeekdays := []POI.Weekday{POI.DateSaturday, POI.DateSunday, POI.DateMonday}
+	for day, dayRequest := range req.DayRequests() {
+		if dayRequest.Weekday != expectedWeekdays[day] {
+			t.Errorf("expected weekday %d for day %d, got %d", expectedWeekdays[day], day, dayRequest.Weekday)
+		}
+		if len(dayRequest.Slots) != 3 {
+			t.Errorf("expected 3 slots for day %d, got %d", day, len(dayRequest.Slots))
+		}
+	}
+}
+
+func TestMultiDayRequestScoreWeights(t *testing.T) {
+	req := solution.MultiDayPlanningRequest{
+		StartDate:    time.Date(2020, time.November, 7, 0, 0, 0, 0, time.UTC),
+		NumDays:      2,
+		DaySlots:     [][]solution.SlotRequest{solution.GetStandardRequest(POI.DateMonday, 0).Slots},
+		ScoreWeights: &matching.ScoreWeights{Rating: 2, ReviewCount: 1, Price: 1, Distance: 1},
+	}
+
+	for day, dayRequest := range req.DayRequests() {
+		if dayRequest.ScoreWeights != req.ScoreWeights {
+			t.Errorf("expected the score weights of the trip for day %d", day)
+		}
+	}
+}
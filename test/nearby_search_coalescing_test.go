@@ -0,0 +1,195 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// slowSearchClient counts nearby searches which take a while like external searches
+type slowSearchClient struct {
+	searches int32
+}
+
+func (client *slowSearchClient) GetGeocode(context.Context, *iowrappers.GeocodeQuery) (float64, float64, error) {
+	return 41.8781, -87.6298, nil
+}
+
+func (client *slowSearchClient) NearbySearch(_ context.Context, request *iowrappers.PlaceSearchRequest) ([]POI.Place, error) {
+	atomic.AddInt32(&client.searches, 1)
+	time.Sleep(200 * time.Millisecond)
+	locationType := POI.LocationTypeMuseum
+	if request.PlaceCat == POI.PlaceCategoryEatery {
+		locationType = POI.LocationTypeRestaurant
+	}
+	places := make([]POI.Place, 0, request.MinNumResults)
+	for idx := uint(0); idx < request.MinNumResults; idx++ {
+		place := POI.Place{}
+		place.SetID(fmt.Sprintf("%s-%d", request.PlaceCat, idx))
+		place.SetName(fmt.Sprintf("%s %d", locationType, idx))
+		place.SetType(locationType)
+		place.SetLocation([2]float64{-87.6298 + float64(idx)*0.001, 41.8781})
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+func (client *slowSearchClient) PlaceDetailsSearch(context.Context, string) (POI.Place, error) {
+	return POI.Place{}, nil
+}
+
+func TestNearbySearchCoalescing(t *testing.T) {
+	redisURL, _, redisServer := createRedis(t)
+
+	// two servers sharing Redis
+	searchClient := &slowSearchClient{}
+	poiSearchers := []*iowrappers.PoiSearcher{
+		iowrappers.CreatePoiSearcherWithSearchClient(searchClient, redisURL),
+		iowrappers.CreatePoiSearcherWithSearchClient(searchClient, redisURL),
+	}
+
+	const numRequests = 20
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery}
+	numPlaces := make([][]int, len(categories))
+	wg := sync.WaitGroup{}
+	for categoryIdx, category := range categories {
+		numPlaces[categoryIdx] = make([]int, numRequests)
+		for requestIdx := 0; requestIdx < numRequests; requestIdx++ {
+			wg.Add(1)
+			go func(categoryIdx int, category POI.PlaceCategory, requestIdx int) {
+				defer wg.Done()
+				ctx := context.WithValue(context.Background(), iowrappers.RequestIdKey, fmt.Sprintf("request-%s-%d", category, requestIdx))
+				places, searchErr := poiSearchers[requestIdx%len(poiSearchers)].NearbySearch(ctx, &iowrappers.PlaceSearchRequest{
+					PlaceCat: category, Location: "Chicago,USA", Radius: 5000, MinNumResults: 3,
+				})
+				if searchErr != nil {
+					t.Error(searchErr)
+				}
+				numPlaces[categoryIdx][requestIdx] = len(places)
+			}(categoryIdx, category, requestIdx)
+		}
+	}
+	wg.Wait()
+
+	// searches of different categories are not coalesced
+	if searches := atomic.LoadInt32(&searchClient.searches); searches != int32(len(categories)) {
+		t.Errorf("expected %d external searches, got %d", len(categories), searches)
+	}
+	for categoryIdx, category := range categories {
+		for requestIdx, num := range numPlaces[categoryIdx] {
+			if num != 3 {
+				t.Errorf("expected 3 places of %s for request %d, got %d", category, requestIdx, num)
+			}
+		}
+	}
+	if keys := redisServer.Keys(); len(keys) == 0 {
+		t.Fatal("expected places in Redis")
+	} else {
+		for _, key := range keys {
+			if key == "nearby_search_lock:usa:chicago:visit" || key == "nearby_search_lock:usa:chicago:eatery" {
+				t.Errorf("expected lock %s to be released", key)
+			}
+		}
+	}
+}
+
+// cancelableSearchClient stops searching when the context of the search is done
+type cancelableSearchClient struct {
+	slowSearchClient
+}
+
+func (client *cancelableSearchClient) NearbySearch(ctx context.Context, request *iowrappers.PlaceSearchRequest) ([]POI.Place, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(100 * time.Millisecond):
+	}
+	return client.slowSearchClient.NearbySearch(ctx, request)
+}
+
+func TestNearbySearchCoalescingCanceledRequest(t *testing.T) {
+	redisURL, _, redisServer := createRedis(t)
+	searchClient := &cancelableSearchClient{}
+	poiSearcher := iowrappers.CreatePoiSearcherWithSearchClient(searchClient, redisURL)
+	newRequest := func() *iowrappers.PlaceSearchRequest {
+		return &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "Chicago,USA", Radius: 5000, MinNumResults: 3}
+	}
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = poiSearcher.NearbySearch(canceledCtx, newRequest())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+	}()
+
+	// the waiting request searches again instead of failing with the canceled request,
+	// and the lock of the canceled request is released instead of blocking the search until it expires
+	ctx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWait()
+	places, err := poiSearcher.NearbySearch(ctx, newRequest())
+	<-done
+	if err != nil || len(places) != 3 {
+		t.Errorf("expected 3 places for the waiting request, got %d, %v", len(places), err)
+	}
+	if redisServer.Exists("nearby_search_lock:usa:chicago:visit") {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestRedisLock(t *testing.T) {
+	_, redisClient, redisServer := createRedis(t)
+	ctx := context.Background()
+
+	token, acquired, err := redisClient.AcquireLock(ctx, "lock", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("expected the lock to be acquired, got %v", err)
+	}
+	if _, acquired, _ = redisClient.AcquireLock(ctx, "lock", time.Minute); acquired {
+		t.Error("expected the lock not to be acquired twice")
+	}
+	// only the holder of the lock releases it
+	if err = redisClient.ReleaseLock(ctx, "lock", "other token"); err != nil || !redisServer.Exists("lock") {
+		t.Errorf("expected the lock to be held, got %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err = redisClient.WaitForLock(waitCtx, "lock", 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline to end the wait, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = redisClient.ReleaseLock(ctx, "lock", token)
+	}()
+	if err = redisClient.WaitForLock(ctx, "lock", 10*time.Millisecond); err != nil || redisServer.Exists("lock") {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
+
+func TestNearbySearchLocationWithoutCountry(t *testing.T) {
+	redisURL, _, _ := createRedis(t)
+	searchClient := &slowSearchClient{}
+	poiSearcher := iowrappers.CreatePoiSearcherWithSearchClient(searchClient, redisURL)
+
+	places, err := poiSearcher.NearbySearch(context.Background(), &iowrappers.PlaceSearchRequest{
+		PlaceCat: POI.PlaceCategoryVisit, Location: "Chicago", Radius: 5000, MinNumResults: 3,
+	})
+	if err == nil || len(places) != 0 {
+		t.Errorf("expected an error for a location without a country, got %d places, %v", len(places), err)
+	}
+	if searches := atomic.LoadInt32(&searchClient.searches); searches != 0 {
+		t.Errorf("expected no external searches, got %d", searches)
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/planner"
+)
+
+// TestOfflinePlanning plans with places of the fixtures file and Redis in memory
+func TestOfflinePlanning(t *testing.T) {
+	redisURL, _, _ := createRedis(t)
+
+	// templates and fixtures are loaded from paths relative to the test directory
+	myPlanner := planner.MyPlanner{}
+	myPlanner.Init("", redisURL, "", map[string]interface{}{
+		"server:templates_dir":               "../templates",
+		"server:search_client:provider":      "fixtures",
+		"server:search_client:fixtures_file": "../data/fixtures/chicago.json",
+	})
+	defer myPlanner.Destroy()
+	router := myPlanner.SetupRouter("10000").Handler
+
+	requestBody := `{"country": "USA", "city": "chicago", "weekday": 2, "num_plans": 3,
+		"slots": [{"start": 11, "end": 13, "category": "eatery"}, {"start": 13, "end": 16, "category": "visit"}, {"start": 16, "end": 18, "category": "visit"}]}`
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/plans", bytes.NewBufferString(requestBody)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	planningResp := planner.PlanningResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &planningResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(planningResp.Places) == 0 {
+		t.Fatal("expected plans from fixtures")
+	}
+	for _, plan := range planningResp.Places {
+		if len(plan.Places) != 3 {
+			t.Errorf("expected 3 places in each plan, got %d", len(plan.Places))
+		}
+		for _, place := range plan.Places {
+			if !strings.HasPrefix(place.PlaceId, "fixture-chi-") {
+				t.Errorf("expected places of the fixtures, got %s", place.PlaceId)
+			}
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/plans",
+		bytes.NewBufferString(`{"country": "France", "city": "Paris", "weekday": 2, "slots": [{"start": 13, "end": 16, "category": "visit"}]}`)))
+	if recorder.Code == http.StatusOK {
+		t.Error("expected an error for a city without fixtures")
+	}
+
+	// fixtures are not Google Maps calls
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats/maps-quota", nil))
+	quotaStats := iowrappers.MapsQuotaStats{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &quotaStats); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("expected Google Maps quota stats, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if len(quotaStats.Calls) != 0 {
+		t.Errorf("expected no Google Maps calls, got %v", quotaStats.Calls)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats/maps-quota?date=yesterday", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid date, got %d", http.StatusBadRequest, recorder.Code)
+	}
+	// calls of users are only for admins
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats/maps-quota?username=guest", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d for calls of a user without authentication, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
@@ -0,0 +1,173 @@
+package test
+
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"reflect"
+	"testing"
+)
+
+func TestParseDailyOpeningHours(t *testing.T) {
+	testCases := []struct {
+		openingHour string
+		expected    []POI.MinuteInterval
+		spill       []POI.MinuteInterval
+	}{
+		{"Monday: 9:00 AM – 5:00 PM", []POI.MinuteInterval{{Start: 540, End: 1020}}, nil},
+		{"Tuesday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM", []POI.MinuteInterval{{Start: 690, End: 870}, {Start: 1020, End: 1320}}, nil},
+		{"Wednesday: Open 24 hours", []POI.MinuteInterval{{Start: 0, End: 1440}}, nil},
+		{"Thursday: Closed", []POI.MinuteInterval{}, nil},
+		{"Friday: 12:00 PM – 12:00 AM", []POI.MinuteInterval{{Start: 720, End: 1440}}, nil},
+		{"Saturday: 6:00 PM – 2:30 AM", []POI.MinuteInterval{{Start: 1080, End: 1440}}, []POI.MinuteInterval{{Start: 0, End: 150}}},
+		{"8:30 am – 9:30 pm", []POI.MinuteInterval{{Start: 510, End: 1290}}, nil},
+		{"Monday: 11:00 – 2:00 PM", []POI.MinuteInterval{{Start: 660, End: 840}}, nil},
+		{"Monday: 9:15 a.m. to 12:00 p.m.; 1 PM - 5:45 PM", []POI.MinuteInterval{{Start: 555, End: 720}, {Start: 780, End: 1065}}, nil},
+		{"Montag: 09:00–13:00, 14:00–18:30", []POI.MinuteInterval{{Start: 540, End: 780}, {Start: 840, End: 1110}}, nil},
+		{"Friday: 18:00—02:00", []POI.MinuteInterval{{Start: 1080, End: 1440}}, []POI.MinuteInterval{{Start: 0, End: 120}}},
+		{"Sunday: 10:00 AM – 4:00 PM", []POI.MinuteInterval{{Start: 600, End: 960}}, nil},
+		{"Monday: 9:00 AM – 5:00 PM (closed for lunch 12–1)", []POI.MinuteInterval{{Start: 540, End: 1020}}, nil},
+	}
+
+	for _, testCase := range testCases {
+		hours, spill, err := POI.ParseDailyOpeningHours(testCase.openingHour)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", testCase.openingHour, err)
+			continue
+		}
+		if !reflect.DeepEqual(hours.Intervals, testCase.expected) {
+			t.Errorf("parsing %s, expected %v, got %v", testCase.openingHour, testCase.expected, hours.Intervals)
+		}
+		if !reflect.DeepEqual(spill, testCase.spill) {
+			t.Errorf("parsing %s, expected spill %v, got %v", testCase.openingHour, testCase.spill, spill)
+		}
+	}
+
+	if _, _, err := POI.ParseDailyOpeningHours("Sunday: sometimes"); err == nil {
+		t.Error("expected an error for invalid opening hours")
+	}
+	if _, _, err := POI.ParseDailyOpeningHours("Sunday: 25:00 – 26:00"); err == nil {
+		t.Error("expected an error for invalid time")
+	}
+}
+
+func TestDailyOpeningHoursFlags(t *testing.T) {
+	closed, _, _ := POI.ParseDailyOpeningHours("Thursday: Closed")
+	if !closed.Closed || closed.Open24Hours || closed.Unavailable {
+		t.Errorf("expected closed flag only, got %+v", closed)
+	}
+	lunchBreak, _, _ := POI.ParseDailyOpeningHours("Thursday: 9:00 AM – 5:00 PM (closed for lunch 12–1)")
+	if lunchBreak.Closed {
+		t.Errorf("expected days with remarks about closing not to be closed, got %+v", lunchBreak)
+	}
+	allDay, _, _ := POI.ParseDailyOpeningHours("Wednesday: Open 24 hours")
+	if allDay.Closed || !allDay.Open24Hours {
+		t.Errorf("expected open 24 hours flag, got %+v", allDay)
+	}
+	unknown, _, _ := POI.ParseDailyOpeningHours("")
+	if !unknown.Unavailable {
+		t.Errorf("expected unavailable flag, got %+v", unknown)
+	}
+}
+
+func TestWeeklyOpeningHoursOvernightSpill(t *testing.T) {
+	hours, err := POI.ParseWeeklyOpeningHours([7]string{
+		"Monday: Closed",
+		"Tuesday: 5:00 PM – 1:00 AM",
+		"Wednesday: 11:00 AM – 3:00 PM",
+		"Thursday: Open 24 hours",
+		"Friday: 12:00 AM – 2:00 AM, 6:00 PM – 3:00 AM",
+		"Saturday: 6:00 PM – 3:00 AM",
+		"Sunday: 8:00 PM – 1:30 AM",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [7][]POI.MinuteInterval{
+		{{Start: 0, End: 90}},
+		{{Start: 1020, End: 1440}},
+		{{Start: 0, End: 60}, {Start: 660, End: 900}},
+		{{Start: 0, End: 1440}},
+		{{Start: 0, End: 120}, {Start: 1080, End: 1440}},
+		{{Start: 0, End: 180}, {Start: 1080, End: 1440}},
+		{{Start: 0, End: 180}, {Start: 1200, End: 1440}},
+	}
+	for day := range expected {
+		if !reflect.DeepEqual(hours[day].Intervals, expected[day]) {
+			t.Errorf("day %d, expected %v, got %v", day, expected[day], hours[day].Intervals)
+		}
+	}
+	if hours[POI.DateMonday].Closed {
+		t.Error("Monday should not be closed with hours spilled over from Sunday")
+	}
+
+	if !hours.IsOpenDuring(POI.DateWednesday, POI.MinuteInterval{Start: 0, End: 60}) {
+		t.Error("expected the place to be open after midnight on Wednesday")
+	}
+	if hours.IsOpenDuring(POI.DateWednesday, POI.MinuteInterval{Start: 840, End: 960}) {
+		t.Error("expected the place to be closed after 3 PM on Wednesday")
+	}
+	if hours.IsOpenDuring(POI.DateMonday, POI.MinuteInterval{Start: 600, End: 660}) {
+		t.Error("expected the place to be closed on Monday morning")
+	}
+}
+
+func TestOSMOpeningHoursSpillIntoDayOff(t *testing.T) {
+	weekdayText, err := POI.ParseOSMOpeningHours("Mo-Fr 18:00-26:00; Sa off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours, err := POI.ParseWeeklyOpeningHours(weekdayText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saturday := hours[POI.DateSaturday]
+	if saturday.Closed {
+		t.Errorf("expected Saturday not to be closed with hours spilled over from Friday, got %+v", saturday)
+	}
+	if !reflect.DeepEqual(saturday.Intervals, []POI.MinuteInterval{{Start: 0, End: 120}}) {
+		t.Errorf("expected Saturday to be open until 2 AM, got %v", saturday.Intervals)
+	}
+	if hours.IsOpenDuring(POI.DateSaturday, POI.MinuteInterval{Start: 600, End: 660}) {
+		t.Error("expected the place to be closed on Saturday morning")
+	}
+}
+
+func TestParseTimeIntervalHourResolution(t *testing.T) {
+	interval, err := POI.ParseTimeInterval("Friday: 12:00 PM – 11:30 PM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interval != (POI.TimeInterval{Start: 12, End: 23}) {
+		t.Errorf("expected 12-23, got %v", interval)
+	}
+}
+
+func TestParseTimeIntervals(t *testing.T) {
+	testCases := []struct {
+		openingHour string
+		expected    []POI.TimeInterval
+	}{
+		{"Monday: 9:00 AM – 5:00 PM", []POI.TimeInterval{{Start: 9, End: 17}}},
+		{"Tuesday: 11:30 AM – 2:30 PM, 5:00 – 10:00 PM", []POI.TimeInterval{{Start: 12, End: 14}, {Start: 17, End: 22}}},
+		{"Wednesday: Open 24 hours", []POI.TimeInterval{{Start: 0, End: 24}}},
+		{"Thursday: Closed", []POI.TimeInterval{}},
+		{"Friday: 12:00 PM – 12:00 AM", []POI.TimeInterval{{Start: 12, End: 24}}},
+		{"Saturday: 6:00 PM – 2:00 AM", []POI.TimeInterval{{Start: 18, End: 24}}},
+		{"8:30 am – 9:30 pm", []POI.TimeInterval{{Start: 9, End: 21}}},
+	}
+
+	for _, testCase := range testCases {
+		intervals, err := POI.ParseTimeIntervals(testCase.openingHour)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", testCase.openingHour, err)
+			continue
+		}
+		if !reflect.DeepEqual(intervals, testCase.expected) {
+			t.Errorf("parsing %s, expected %v, got %v", testCase.openingHour, testCase.expected, intervals)
+		}
+	}
+
+	if _, err := POI.ParseTimeIntervals("Sunday: sometimes"); err == nil {
+		t.Error("expected an error for invalid opening hours")
+	}
+}
@@ -0,0 +1,329 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+)
+
+const overpassChicagoPlaces = `{"elements": [
+	{"type": "node", "id": 1, "lat": 41.8663, "lon": -87.6169,
+		"tags": {"tourism": "museum", "name": "Field Museum", "opening_hours": "Mo-Su 09:00-17:00",
+			"addr:housenumber": "1400", "addr:street": "South DuSable Lake Shore Drive", "addr:city": "Chicago", "addr:postcode": "60605"}},
+	{"type": "way", "id": 2, "center": {"lat": 41.8796, "lon": -87.6237},
+		"tags": {"tourism": "museum", "name": "Art Institute of Chicago", "wikidata": "Q239303", "website": "https://www.artic.edu"}},
+	{"type": "way", "id": 3, "center": {"lat": 41.8826, "lon": -87.6226},
+		"tags": {"leisure": "park", "name": "Millennium Park", "opening_hours": "06:00-23:00"}},
+	{"type": "node", "id": 4, "lat": 41.8800, "lon": -87.6300, "tags": {"leisure": "park"}},
+	{"type": "node", "id": 5, "lat": 41.8810, "lon": -87.6290,
+		"tags": {"amenity": "restaurant", "name": "Lou Mitchell's", "opening_hours": "Jan-Mar Mo-Fr 06:00-15:00"}},
+	{"type": "node", "id": 6, "lat": 41.8781, "lon": -87.6298, "tags": {"place": "city", "name": "Chicago"}}
+]}`
+
+func TestParseOSMOpeningHours(t *testing.T) {
+	tests := []struct {
+		openingHours string
+		expected     [7]string
+	}{
+		{
+			openingHours: "Mo-Fr 09:00-17:00; Sa 10:00-14:00,15:00-18:00; Su off",
+			expected: [7]string{"Monday: 09:00–17:00", "Tuesday: 09:00–17:00", "Wednesday: 09:00–17:00", "Thursday: 09:00–17:00",
+				"Friday: 09:00–17:00", "Saturday: 10:00–14:00, 15:00–18:00", "Sunday: Closed"},
+		},
+		{
+			// days without rules are closed, later rules override earlier rules, and holidays are ignored
+			openingHours: "Tu-Su 10:30-17:00; Th 10:30-20:00; PH off",
+			expected: [7]string{"Monday: Closed", "Tuesday: 10:30–17:00", "Wednesday: 10:30–17:00", "Thursday: 10:30–20:00",
+				"Friday: 10:30–17:00", "Saturday: 10:30–17:00", "Sunday: 10:30–17:00"},
+		},
+		{
+			openingHours: "Fr-Mo 18:00-26:00",
+			expected: [7]string{"Monday: 18:00–02:00", "Tuesday: Closed", "Wednesday: Closed", "Thursday: Closed",
+				"Friday: 18:00–02:00", "Saturday: 18:00–02:00", "Sunday: 18:00–02:00"},
+		},
+		{
+			openingHours: "24/7",
+			expected: [7]string{"Monday: Open 24 hours", "Tuesday: Open 24 hours", "Wednesday: Open 24 hours", "Thursday: Open 24 hours",
+				"Friday: Open 24 hours", "Saturday: Open 24 hours", "Sunday: Open 24 hours"},
+		},
+		{
+			openingHours: "",
+		},
+	}
+	for _, test := range tests {
+		hours, err := POI.ParseOSMOpeningHours(test.openingHours)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", test.openingHours, err)
+			continue
+		}
+		if hours != test.expected {
+			t.Errorf("expected opening hours of %s to be %v, got %v", test.openingHours, test.expected, hours)
+		}
+	}
+
+	// hours are parsed as opening hours of Google Maps
+	hours, _ := POI.ParseOSMOpeningHours("Fr-Mo 18:00-26:00")
+	weeklyHours, err := POI.ParseWeeklyOpeningHours(hours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !weeklyHours.IsOpenDuring(POI.DateSaturday, POI.MinuteInterval{Start: 60, End: 90}) {
+		t.Error("expected the place to be open on Saturday night after Friday")
+	}
+
+	for _, unsupported := range []string{"Jan-Mar 10:00-12:00", "Mo[1] 10:00-12:00", "sunrise-sunset", "Mo-Fr 10:00+"} {
+		if _, err = POI.ParseOSMOpeningHours(unsupported); err == nil {
+			t.Errorf("expected an error for opening hours %s", unsupported)
+		}
+	}
+}
+
+func TestOverpassSearchClient(t *testing.T) {
+	queries := make([]string, 0)
+	overpass := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		_ = req.ParseForm()
+		query := req.PostForm.Get("data")
+		queries = append(queries, query)
+		writer.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(query, "area.country"):
+			_, _ = writer.Write([]byte(`{"elements": [{"type": "node", "id": 6, "lat": 41.8781, "lon": -87.6298, "tags": {"place": "city", "name": "Chicago"}}]}`))
+		case strings.HasSuffix(query, "way(3);out center;"):
+			_, _ = writer.Write([]byte(`{"elements": [{"type": "way", "id": 3, "center": {"lat": 41.8826, "lon": -87.6226}, "tags": {"leisure": "park", "name": "Millennium Park"}}]}`))
+		default:
+			_, _ = writer.Write([]byte(overpassChicagoPlaces))
+		}
+	}))
+	defer overpass.Close()
+	client := iowrappers.CreateOverpassSearchClient(overpass.URL)
+	ctx := context.Background()
+
+	query := &iowrappers.GeocodeQuery{City: "chicago", Country: "USA"}
+	lat, lng, err := client.GetGeocode(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat != 41.8781 || lng != -87.6298 || query.City != "Chicago" {
+		t.Errorf("expected Chicago at (41.8781, -87.6298), got %s at (%f, %f)", query.City, lat, lng)
+	}
+
+	places, err := client.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nearbyQuery := queries[len(queries)-1]
+	// the radius of Google Maps searches is capped, and the number of elements is limited
+	for _, tag := range []string{`nwr["tourism"="museum"]["name"](around:5000,41.878100,-87.629800)`, `nwr["leisure"="park"]`, "out center 1000;"} {
+		if !strings.Contains(nearbyQuery, tag) {
+			t.Errorf("expected Overpass query to contain %s, got %s", tag, nearbyQuery)
+		}
+	}
+	if strings.Contains(nearbyQuery, "restaurant") {
+		t.Errorf("expected Overpass query to search places of the request category only, got %s", nearbyQuery)
+	}
+
+	// places with Wikidata entries come first, and places without names or of other categories are skipped
+	expectedIds := []string{"osm-way-2", "osm-way-3", "osm-node-1"}
+	if len(places) != len(expectedIds) {
+		t.Fatalf("expected %d places, got %d", len(expectedIds), len(places))
+	}
+	for idx, place := range places {
+		if place.ID != expectedIds[idx] {
+			t.Errorf("expected place %d to be %s, got %s", idx, expectedIds[idx], place.ID)
+		}
+		if place.Status != POI.Operational {
+			t.Errorf("expected place %s to be operational", place.ID)
+		}
+	}
+	museum, park := places[2], places[1]
+	if museum.LocationType != POI.LocationTypeMuseum || park.LocationType != POI.LocationTypePark {
+		t.Errorf("expected a museum and a park, got %s and %s", museum.LocationType, park.LocationType)
+	}
+	if museum.Hours[POI.DateSunday] != "Sunday: 09:00–17:00" || park.Hours[POI.DateMonday] != "Monday: 06:00–23:00" {
+		t.Errorf("expected opening hours from OSM, got %s and %s", museum.Hours[POI.DateSunday], park.Hours[POI.DateMonday])
+	}
+	if museum.FormattedAddress != "1400 South DuSable Lake Shore Drive, Chicago 60605" {
+		t.Errorf("unexpected address %s", museum.FormattedAddress)
+	}
+	if museum.Location.Coordinates != [2]float64{-87.6169, 41.8663} {
+		t.Errorf("expected [lng, lat] coordinates, got %v", museum.Location.Coordinates)
+	}
+	if places[0].URL != "https://www.artic.edu" || park.URL != "https://www.openstreetmap.org/way/3" {
+		t.Errorf("expected website or OSM URLs, got %s and %s", places[0].URL, park.URL)
+	}
+
+	// unsupported opening hours get the default hours
+	eateries, _ := client.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryEatery, Location: "41.878100,-87.629800", Radius: 16000})
+	if len(eateries) != 1 || eateries[0].Hours[POI.DateMonday] != "8:30 am – 9:30 pm" {
+		t.Errorf("expected 1 eatery with the default hours, got %v", eateries)
+	}
+
+	place, err := client.PlaceDetailsSearch(ctx, "osm-way-3")
+	if err != nil || place.Name != "Millennium Park" {
+		t.Errorf("expected details of Millennium Park, got %s, %v", place.Name, err)
+	}
+	if _, err = client.PlaceDetailsSearch(ctx, "ChIJ7cv00DwsDogRAMDACa2m4K8"); err == nil {
+		t.Error("expected an error for a place ID of another provider")
+	}
+}
+
+func TestOSMPlaceScores(t *testing.T) {
+	overpass := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write([]byte(overpassChicagoPlaces))
+	}))
+	defer overpass.Close()
+	client := iowrappers.CreateOverpassSearchClient(overpass.URL)
+	places, err := client.NearbySearch(context.Background(),
+		&iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scores of single places do not depend on distances
+	scores := make(map[string]float64)
+	for _, place := range places {
+		scores[place.ID] = matching.DefaultScorer.PlaceScore(matching.CreatePlace(place, POI.PlaceCategoryVisit))
+		if scores[place.ID] <= 0 {
+			t.Errorf("expected a positive score of place %s without ratings, got %f", place.ID, scores[place.ID])
+		}
+	}
+	// the Art Institute of Chicago with Wikidata and website tags is better known than Millennium Park without them
+	if scores["osm-way-2"] <= scores["osm-way-3"] {
+		t.Errorf("expected places with notability tags to score higher, got %v", scores)
+	}
+}
+
+func TestOverpassSearchClientError(t *testing.T) {
+	overpass := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusTooManyRequests)
+		_, _ = writer.Write([]byte("rate_limited"))
+	}))
+	defer overpass.Close()
+	client := iowrappers.CreateOverpassSearchClient(overpass.URL)
+
+	if _, err := client.NearbySearch(context.Background(), &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000}); err == nil {
+		t.Error("expected an error for a failed Overpass query")
+	}
+}
+
+func TestOSMExtractSearchClient(t *testing.T) {
+	_, redisClient, _ := createRedis(t)
+
+	extractFile := filepath.Join(t.TempDir(), "chicago.json")
+	if err := ioutil.WriteFile(extractFile, []byte(overpassChicagoPlaces), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	numPlaces, err := iowrappers.ImportOSMExtract(ctx, &redisClient, extractFile, iowrappers.GeocodeQuery{City: "Chicago", Country: "USA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numPlaces != 4 {
+		t.Errorf("expected 4 places imported, got %d", numPlaces)
+	}
+
+	client := iowrappers.CreateOSMExtractSearchClient(&redisClient)
+	lat, lng, err := client.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "chicago", Country: "usa"})
+	if err != nil || lat != 41.8781 || lng != -87.6298 {
+		t.Errorf("expected the geocode of the city node, got (%f, %f), %v", lat, lng, err)
+	}
+	if _, _, err = client.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Paris", Country: "France"}); err == nil {
+		t.Error("expected an error for a location without extract")
+	}
+
+	places, err := client.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 3 {
+		t.Errorf("expected 3 places to visit, got %d", len(places))
+	}
+	// imported places are not in the cache of places searched by PoiSearcher
+	cachedPlaces, _ := redisClient.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000})
+	if len(cachedPlaces) != 0 {
+		t.Errorf("expected no cached places, got %d", len(cachedPlaces))
+	}
+
+	place, err := client.PlaceDetailsSearch(ctx, "osm-node-5")
+	if err != nil || place.Name != "Lou Mitchell's" {
+		t.Errorf("expected details of Lou Mitchell's, got %s, %v", place.Name, err)
+	}
+}
+
+func TestFallbackSearchClient(t *testing.T) {
+	chicago := iowrappers.CityFixture{City: "Chicago", Country: "USA", Lat: 41.8781, Lng: -87.6298, Places: []POI.Place{
+		POI.CreatePlace("Field Museum", "41.8663,-87.6169", "", "", string(POI.Operational), POI.LocationTypeMuseum, nil, "fallback-1", 3, 4.7, "", nil, 100),
+		POI.CreatePlace("Grant Park", "41.8739,-87.6193", "", "", string(POI.Operational), POI.LocationTypePark, nil, "fallback-2", 0, 4.7, "", nil, 100),
+	}}
+	sparse := chicago
+	sparse.Places = chicago.Places[:1]
+	failing := iowrappers.CreateFixtureSearchClientFromFixtures(iowrappers.SearchFixtures{})
+	client := iowrappers.CreateFallbackSearchClient(failing,
+		iowrappers.CreateFixtureSearchClientFromFixtures(iowrappers.SearchFixtures{Cities: []iowrappers.CityFixture{sparse}}),
+		iowrappers.CreateFixtureSearchClientFromFixtures(iowrappers.SearchFixtures{Cities: []iowrappers.CityFixture{chicago}}))
+	ctx := context.Background()
+
+	if _, _, err := client.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Chicago", Country: "USA"}); err != nil {
+		t.Errorf("expected geocode from the second search client, got %v", err)
+	}
+	if _, _, err := client.GetGeocode(ctx, &iowrappers.GeocodeQuery{City: "Paris", Country: "France"}); err == nil {
+		t.Error("expected an error if every search client fails")
+	}
+
+	request := &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.878100,-87.629800", Radius: 16000, MinNumResults: 1}
+	places, err := client.NearbySearch(ctx, request)
+	if err != nil || len(places) != 1 {
+		t.Errorf("expected the first search client with enough places to be used, got %d places, %v", len(places), err)
+	}
+	request.MinNumResults = 2
+	if places, err = client.NearbySearch(ctx, request); err != nil || len(places) != 2 {
+		t.Errorf("expected to fall back for too few places, got %d places, %v", len(places), err)
+	}
+	request.MinNumResults = 5
+	if places, err = client.NearbySearch(ctx, request); err != nil || len(places) != 2 {
+		t.Errorf("expected the most places if no search client finds enough, got %d places, %v", len(places), err)
+	}
+
+	if place, err := client.PlaceDetailsSearch(ctx, "fallback-2"); err != nil || place.Name != "Grant Park" {
+		t.Errorf("expected details of Grant Park, got %s, %v", place.Name, err)
+	}
+}
+
+func TestFallbackPlaceDetailsFromGoogleMaps(t *testing.T) {
+	// Google Maps finds details of its own places only
+	detailsServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if req.URL.Query().Get("placeid") != "ChIJ-maps-museum" {
+			_, _ = writer.Write([]byte(`{"status": "NOT_FOUND"}`))
+			return
+		}
+		_, _ = writer.Write([]byte(`{"status": "OK", "result": {"place_id": "ChIJ-maps-museum", "name": "Art Institute of Chicago",
+			"geometry": {"location": {"lat": 41.8796, "lng": -87.6237}}, "types": ["tourist_attraction", "museum"],
+			"business_status": "OPERATIONAL", "rating": 4.8, "user_ratings_total": 2000}}`))
+	}))
+	defer detailsServer.Close()
+	mapsClient := iowrappers.CreateReplayMapsClient(detailsServer.URL)
+
+	chicago := iowrappers.CityFixture{City: "Chicago", Country: "USA", Lat: 41.8781, Lng: -87.6298, Places: []POI.Place{
+		POI.CreatePlace("Grant Park", "41.8739,-87.6193", "", "", string(POI.Operational), POI.LocationTypePark, nil, "fallback-2", 0, 4.7, "", nil, 100),
+	}}
+	client := iowrappers.CreateFallbackSearchClient(&mapsClient,
+		iowrappers.CreateFixtureSearchClientFromFixtures(iowrappers.SearchFixtures{Cities: []iowrappers.CityFixture{chicago}}))
+	ctx := context.Background()
+
+	place, err := client.PlaceDetailsSearch(ctx, "ChIJ-maps-museum")
+	if err != nil || place.Name != "Art Institute of Chicago" || place.LocationType != POI.LocationTypeMuseum || place.UserRatingsTotal != 2000 {
+		t.Errorf("expected details of the Art Institute of Chicago from Google Maps, got %+v, %v", place, err)
+	}
+	if place, err = client.PlaceDetailsSearch(ctx, "fallback-2"); err != nil || place.Name != "Grant Park" {
+		t.Errorf("expected to fall back to the fixtures for details of Grant Park, got %s, %v", place.Name, err)
+	}
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+// pickPlace picks a random place that is not a museum
+func pickPlace(random *rand.Rand, places []matching.Place) matching.Place {
+	for {
+		place := places[random.Intn(len(places))]
+		if place.GetPlaceType() != POI.LocationTypeMuseum {
+			return place
+		}
+	}
+}
+
+func TestSearchTopSolutionsHonorsPlaceConstraints(t *testing.T) {
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit, POI.PlaceCategoryVisit}
+	random := rand.New(rand.NewSource(10))
+	for round := 0; round < 20; round++ {
+		categorizedPlaces := generateSlotPlaces(random, categories, 6)
+		placeTypes := make(map[string]POI.LocationType)
+		for _, places := range categorizedPlaces {
+			for _, place := range places.VisitPlaces {
+				if random.Intn(4) == 0 {
+					place.Place.SetType(POI.LocationTypeMuseum)
+				} else {
+					place.Place.SetType(POI.LocationTypePark)
+				}
+				placeTypes[place.GetPlaceId()] = place.GetPlaceType()
+			}
+		}
+
+		anySlotPlace := pickPlace(random, categorizedPlaces[0].VisitPlaces)
+		eatery := categorizedPlaces[1].EateryPlaces[random.Intn(len(categorizedPlaces[1].EateryPlaces))]
+		excludedPlace := pickPlace(random, categorizedPlaces[2].VisitPlaces)
+		if excludedPlace.GetPlaceId() == anySlotPlace.GetPlaceId() {
+			continue
+		}
+		constraints := &solution.PlaceConstraints{
+			PinnedPlaces: []solution.PinnedPlace{
+				{PlaceId: anySlotPlace.GetPlaceId(), Slot: solution.AnySlot},
+				{PlaceId: eatery.GetPlaceId(), Slot: 1},
+			},
+			ExcludedPlaceIds:      []string{excludedPlace.GetPlaceId()},
+			ExcludedLocationTypes: []POI.LocationType{POI.LocationTypeMuseum},
+		}
+
+		expected, err := solution.EnumerateTopSolutions(categories, categorizedPlaces, 5, constraints, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := solution.SearchTopSolutions(categories, categorizedPlaces, 5, constraints, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("results differ from enumeration, expected %v, got %v", expected, results)
+		}
+
+		for _, result := range results {
+			if result.PlaceIDS[1] != eatery.GetPlaceId() {
+				t.Errorf("expected place %s in slot 1, got %s", eatery.GetPlaceId(), result.PlaceIDS[1])
+			}
+			hasAnySlotPlace := false
+			for _, placeId := range result.PlaceIDS {
+				hasAnySlotPlace = hasAnySlotPlace || placeId == anySlotPlace.GetPlaceId()
+				if placeId == excludedPlace.GetPlaceId() || placeTypes[placeId] == POI.LocationTypeMuseum {
+					t.Errorf("excluded place %s is in plan %v", placeId, result.PlaceIDS)
+				}
+			}
+			if !hasAnySlotPlace {
+				t.Errorf("expected place %s in plan %v", anySlotPlace.GetPlaceId(), result.PlaceIDS)
+			}
+		}
+	}
+}
+
+func TestSearchTopSolutionsUnavailablePinnedPlace(t *testing.T) {
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery}
+	categorizedPlaces := generateSlotPlaces(rand.New(rand.NewSource(3)), categories, 3)
+
+	constraints := []solution.PlaceConstraints{
+		{PinnedPlaces: []solution.PinnedPlace{{PlaceId: "unknown", Slot: solution.AnySlot}}},
+		{PinnedPlaces: []solution.PinnedPlace{{PlaceId: categorizedPlaces[0].VisitPlaces[0].GetPlaceId(), Slot: 1}}},
+		{PinnedPlaces: []solution.PinnedPlace{{PlaceId: categorizedPlaces[0].VisitPlaces[0].GetPlaceId(), Slot: 2}}},
+	}
+	for _, constraint := range constraints {
+		if _, err := solution.SearchTopSolutions(categories, categorizedPlaces, 5, &constraint, nil); err == nil {
+			t.Errorf("expected an error for pinned places %v", constraint.PinnedPlaces)
+		}
+	}
+}
+
+func TestPlaceConstraintsSerialize(t *testing.T) {
+	constraints := solution.PlaceConstraints{
+		PinnedPlaces:          []solution.PinnedPlace{{PlaceId: "b", Slot: 1}, {PlaceId: "a", Slot: solution.AnySlot}},
+		ExcludedPlaceIds:      []string{"d", "c"},
+		ExcludedLocationTypes: []POI.LocationType{POI.LocationTypePark, POI.LocationTypeCafe},
+	}
+	reordered := solution.PlaceConstraints{
+		PinnedPlaces:          []solution.PinnedPlace{{PlaceId: "a", Slot: solution.AnySlot}, {PlaceId: "b", Slot: 1}},
+		ExcludedPlaceIds:      []string{"c", "d"},
+		ExcludedLocationTypes: []POI.LocationType{POI.LocationTypeCafe, POI.LocationTypePark},
+	}
+	if constraints.Serialize() != reordered.Serialize() {
+		t.Errorf("expected the same serialization regardless of order, got %s and %s", constraints.Serialize(), reordered.Serialize())
+	}
+
+	reordered.PinnedPlaces[1].Slot = 0
+	if constraints.Serialize() == reordered.Serialize() {
+		t.Error("expected different serializations for places pinned to different slots")
+	}
+
+	if (&solution.PlaceConstraints{}).Serialize() != "" {
+		t.Error("expected an empty serialization without constraints")
+	}
+}
@@ -0,0 +1,185 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+var refreshedMuseumHours = []string{
+	"Monday: 9:00 AM – 5:00 PM", "Tuesday: 9:00 AM – 5:00 PM", "Wednesday: 9:00 AM – 5:00 PM", "Thursday: 9:00 AM – 5:00 PM",
+	"Friday: 9:00 AM – 8:00 PM", "Saturday: 10:00 AM – 8:00 PM", "Sunday: 10:00 AM – 5:00 PM",
+}
+
+// placeDetailsServer serves details of refresh-museum and NOT_FOUND for other places, and counts details searches
+type placeDetailsServer struct {
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func (server *placeDetailsServer) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	placeId := req.URL.Query().Get("placeid")
+	server.mutex.Lock()
+	server.calls[placeId]++
+	server.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if placeId != "refresh-museum" {
+		_, _ = writer.Write([]byte(`{"status": "NOT_FOUND"}`))
+		return
+	}
+	hours, _ := json.Marshal(refreshedMuseumHours)
+	_, _ = writer.Write([]byte(`{"status": "OK", "result": {"place_id": "refresh-museum", "business_status": "OPERATIONAL",
+		"rating": 4.8, "user_ratings_total": 2000, "opening_hours": {"weekday_text": ` + string(hours) + `}}}`))
+}
+
+func (server *placeDetailsServer) callsOf(placeId string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.calls[placeId]
+}
+
+func cachedPlace(t *testing.T, redisServer *miniredis.Miniredis, placeId string) POI.Place {
+	json_, err := redisServer.Get("place_details:place_ID:" + placeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var place POI.Place
+	if err = json.Unmarshal([]byte(json_), &place); err != nil {
+		t.Fatal(err)
+	}
+	return place
+}
+
+func TestPlaceRefresh(t *testing.T) {
+	_, redisClient, redisServer := createRedis(t)
+	detailsServer := &placeDetailsServer{calls: make(map[string]int)}
+	server := httptest.NewServer(detailsServer)
+	defer server.Close()
+	mapsClient := iowrappers.CreateReplayMapsClient(server.URL)
+	ctx := context.Background()
+
+	places := make([]POI.Place, 0)
+	for _, placeId := range []string{"refresh-museum", "refresh-gone", "refresh-fresh", "refresh-unused", "osm-node-1"} {
+		places = append(places, POI.Place{ID: placeId, Name: placeId, Status: POI.Operational, LocationType: POI.LocationTypeMuseum,
+			Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6237, 41.8796}}, Rating: 4.2, UserRatingsTotal: 100})
+	}
+	redisClient.SetPlacesOnCategory(ctx, places)
+	if lastRefreshed, err := redisClient.GetPlaceLastRefreshed(ctx, "refresh-fresh"); err != nil || time.Since(lastRefreshed) > time.Minute {
+		t.Fatalf("expected cached places to be refreshed now, got %s, %v", lastRefreshed, err)
+	}
+	if _, err := redisClient.GetPlaceLastRefreshed(ctx, "osm-node-1"); err == nil {
+		t.Error("expected places of OpenStreetMap not to be refreshed")
+	}
+	// the unused place is the stalest, and the museum is in a cached plan
+	for placeId, lastRefreshed := range map[string]float64{"refresh-unused": 100, "refresh-gone": 200, "refresh-museum": 300} {
+		if _, err := redisServer.ZAdd(iowrappers.PlaceLastRefreshedKey, lastRefreshed, placeId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slotRequest := iowrappers.SlotSolutionCacheRequest{Country: "USA", City: "Refreshville", EVTags: []string{"v"},
+		Intervals: []POI.TimeInterval{{Start: 10, End: 12}}}
+	redisClient.CacheSlotSolution(ctx, slotRequest, iowrappers.SlotSolutionCacheResponse{
+		SlotSolutionCandidate: []iowrappers.SlotSolutionCandidateCache{{PlaceIds: []string{"refresh-museum"}}},
+	})
+
+	refresher := iowrappers.CreatePlaceRefresher(&mapsClient, &redisClient, iowrappers.PlaceRefreshConfig{DailyQuota: 2, BatchSize: 1, MinAge: time.Hour})
+	stats, err := refresher.RefreshStalePlaces(ctx)
+	if err != nil || stats.Refreshed != 1 || detailsServer.callsOf("refresh-museum") != 1 {
+		t.Fatalf("expected the most used place to be refreshed first, got %+v, %v", stats, err)
+	}
+	museum := cachedPlace(t, redisServer, "refresh-museum")
+	if museum.Rating != 4.8 || museum.UserRatingsTotal != 2000 || museum.Hours[POI.DateFriday] != refreshedMuseumHours[POI.DateFriday] ||
+		museum.Status != POI.Operational {
+		t.Errorf("expected the museum to be refreshed, got %+v", museum)
+	}
+	if lastRefreshed, _ := redisClient.GetPlaceLastRefreshed(ctx, "refresh-museum"); time.Since(lastRefreshed) > time.Minute {
+		t.Errorf("expected the refresh time of the museum to be updated, got %s", lastRefreshed)
+	}
+	if redisClient.GetMultiSlotSolutions(ctx, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err == nil {
+		t.Error("expected the plan with the new opening hours of the museum to be invalidated")
+	}
+
+	stats, err = refresher.RefreshStalePlaces(ctx)
+	if err != nil || stats.Closed != 1 {
+		t.Fatalf("expected the unused place not found to be closed, got %+v, %v", stats, err)
+	}
+	if unused := cachedPlace(t, redisServer, "refresh-unused"); unused.Status != POI.ClosedPermanently {
+		t.Errorf("expected the unused place to be closed permanently, got %s", unused.Status)
+	}
+	// closed places are not candidates of plans
+	nearbyPlaces, _ := redisClient.NearbySearch(ctx, &iowrappers.PlaceSearchRequest{PlaceCat: POI.PlaceCategoryVisit, Location: "41.879600,-87.623700", Radius: 1000})
+	for _, place := range nearbyPlaces {
+		if place.ID == "refresh-unused" {
+			t.Error("expected the closed place not to be found by nearby searches")
+		}
+	}
+	if len(nearbyPlaces) != len(places)-1 {
+		t.Errorf("expected %d open places nearby, got %d", len(places)-1, len(nearbyPlaces))
+	}
+
+	// the daily quota is used up
+	stats, err = refresher.RefreshStalePlaces(ctx)
+	if err != nil || stats != (iowrappers.PlaceRefreshStats{}) || detailsServer.callsOf("refresh-gone") != 0 {
+		t.Errorf("expected no refreshes over the daily quota, got %+v, %v", stats, err)
+	}
+	if detailsServer.callsOf("refresh-fresh") != 0 || detailsServer.callsOf("osm-node-1") != 0 {
+		t.Error("expected fresh places and places of OpenStreetMap not to be refreshed")
+	}
+}
+
+func TestPlaceRefreshBacksOffTransientErrors(t *testing.T) {
+	_, redisClient, redisServer := createRedis(t)
+	calls := make(map[string]int)
+	mutex := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		calls[req.URL.Query().Get("placeid")]++
+		mutex.Unlock()
+		writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		_, _ = writer.Write([]byte(`{"status": "UNKNOWN_ERROR"}`))
+	}))
+	defer server.Close()
+	mapsClient := iowrappers.CreateReplayMapsClient(server.URL)
+	mapsClient.SetRetryBaseDelay(time.Millisecond)
+	ctx := context.Background()
+
+	places := make([]POI.Place, 0)
+	for _, placeId := range []string{"refresh-flaky", "refresh-next"} {
+		places = append(places, POI.Place{ID: placeId, Name: placeId, Status: POI.Operational, LocationType: POI.LocationTypeMuseum,
+			Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6237, 41.8796}}, Rating: 4.2, UserRatingsTotal: 100})
+	}
+	redisClient.SetPlacesOnCategory(ctx, places)
+	for placeId, lastRefreshed := range map[string]float64{"refresh-flaky": 100, "refresh-next": 200} {
+		if _, err := redisServer.ZAdd(iowrappers.PlaceLastRefreshedKey, lastRefreshed, placeId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	refresher := iowrappers.CreatePlaceRefresher(&mapsClient, &redisClient, iowrappers.PlaceRefreshConfig{DailyQuota: 10, BatchSize: 1, MinAge: time.Hour})
+	stats, err := refresher.RefreshStalePlaces(ctx)
+	if err != nil || stats.Failed != 1 || calls["refresh-flaky"] == 0 {
+		t.Fatalf("expected the refresh of the stalest place to fail, got %+v, %v", stats, err)
+	}
+	if lastRefreshed, _ := redisClient.GetPlaceLastRefreshed(ctx, "refresh-flaky"); time.Since(lastRefreshed) > time.Minute {
+		t.Errorf("expected the failed refresh to be recorded, got %s", lastRefreshed)
+	}
+
+	// the failed place backs off and the next stale place is refreshed
+	flakyCalls := calls["refresh-flaky"]
+	stats, err = refresher.RefreshStalePlaces(ctx)
+	if err != nil || stats.Failed != 1 || calls["refresh-next"] == 0 {
+		t.Errorf("expected the next stale place to be refreshed, got %+v, %v", stats, err)
+	}
+	if calls["refresh-flaky"] != flakyCalls {
+		t.Errorf("expected the failed place not to be refreshed again within the backoff, got %d calls", calls["refresh-flaky"])
+	}
+}
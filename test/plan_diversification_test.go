@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+func createDiversificationCandidate(score float64, placeIds []string, placeTypes []POI.LocationType) solution.PlanningSolution {
+	return solution.PlanningSolution{PlaceIDS: placeIds, PlaceTypes: placeTypes, Score: score, IsSet: true}
+}
+
+func TestSelectDiversePlans(t *testing.T) {
+	museumPark := []POI.LocationType{POI.LocationTypeMuseum, POI.LocationTypePark}
+	candidates := []solution.PlanningSolution{
+		createDiversificationCandidate(10, []string{"a", "b"}, museumPark),
+		createDiversificationCandidate(9.9, []string{"a", "c"}, museumPark),
+		createDiversificationCandidate(9.8, []string{"b", "c"}, museumPark),
+		createDiversificationCandidate(9, []string{"d", "e"}, []POI.LocationType{POI.LocationTypeGallery, POI.LocationTypeCafe}),
+	}
+
+	topPlans := solution.SelectDiversePlans(candidates, 2, 0)
+	if !reflect.DeepEqual(topPlans, solution.FindBestPlanningSolutions(candidates, 2)) {
+		t.Errorf("expected the top plans without diversity, got %v", topPlans)
+	}
+
+	diversePlans := solution.SelectDiversePlans(candidates, 2, 0.7)
+	if len(diversePlans) != 2 {
+		t.Fatalf("expected 2 plans, got %d", len(diversePlans))
+	}
+	// plans are in the same order as the results of FindBestPlanningSolutions
+	if diversePlans[0].Score != 9 || diversePlans[1].Score != 10 {
+		t.Errorf("expected the best plan and the plan without shared places, got %v", diversePlans)
+	}
+}
+
+func TestSelectDiversePlansReducesOverlap(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit, POI.PlaceCategoryVisit}
+	categorizedPlaces := generateSlotPlaces(random, categories, 6)
+	candidates, err := solution.SearchTopSolutions(categories, categorizedPlaces, 5*solution.DiversityCandidatesFactor, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	distinctPlaces := func(plans []solution.PlanningSolution) int {
+		places := make(map[string]bool)
+		for _, plan := range plans {
+			for _, placeId := range plan.PlaceIDS {
+				places[placeId] = true
+			}
+		}
+		return len(places)
+	}
+
+	topPlans := solution.SelectDiversePlans(candidates, 5, 0)
+	diversePlans := solution.SelectDiversePlans(candidates, 5, solution.MaxDiversity)
+	if len(diversePlans) != 5 {
+		t.Fatalf("expected 5 plans, got %d", len(diversePlans))
+	}
+	if distinctPlaces(diversePlans) <= distinctPlaces(topPlans) {
+		t.Errorf("expected diverse plans to have more distinct places than the top plans, got %d and %d",
+			distinctPlaces(diversePlans), distinctPlaces(topPlans))
+	}
+	// the best plan is always selected
+	if diversePlans[len(diversePlans)-1].Score != topPlans[len(topPlans)-1].Score {
+		t.Errorf("expected the best plan with score %f to be selected", topPlans[len(topPlans)-1].Score)
+	}
+}
+
+func TestDiversePlansFromCandidatesAcceptedByScheduler(t *testing.T) {
+	redisURL, redisClient, _ := createRedis(t)
+	solver := solution.Solver{}
+	solver.Init(iowrappers.CreatePoiSearcherWithSearchClient(citySearchClient{cities: []string{"chicago"}, numPlaces: 6}, redisURL))
+
+	// the scheduler rejects every other plan
+	numCalls := 0
+	acceptedPlans := make(map[string]bool)
+	scheduler := func(plan solution.PlanningSolution) (solution.PlanningSolution, error) {
+		numCalls++
+		if numCalls%2 == 0 {
+			return plan, errors.New("transit does not fit")
+		}
+		acceptedPlans[strings.Join(plan.PlaceIDS, ",")] = true
+		return plan, nil
+	}
+
+	req := solution.PlanningRequest{
+		Location: "chicago,USA",
+		Slots: []solution.SlotRequest{
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 10, End: 12}}, Category: POI.PlaceCategoryEatery},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 12, End: 15}}, Category: POI.PlaceCategoryVisit},
+			{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 15, End: 18}}, Category: POI.PlaceCategoryVisit},
+		},
+		Weekday:      POI.DateMonday,
+		NumPlans:     2,
+		SearchRadius: 5000,
+		Diversity:    0.5,
+	}
+	if !solver.ValidateLocation(context.Background(), &req.Location) {
+		t.Fatal("failed to geocode the city")
+	}
+	solutions, _, err := solution.GenerateSolutions(context.Background(), solver.Matcher, redisClient, iowrappers.SlotSolutionCacheRequest{}, req, scheduler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solutions) != int(req.NumPlans) {
+		t.Fatalf("expected %d plans, got %d", req.NumPlans, len(solutions))
+	}
+	if minCandidates := int(req.NumPlans) * solution.DiversityCandidatesFactor; len(acceptedPlans) < minCandidates {
+		t.Errorf("expected diverse plans to be selected from at least %d candidates accepted by the scheduler, got %d", minCandidates, len(acceptedPlans))
+	}
+}
@@ -1,40 +1,70 @@
 package test
 
-//func TestPostSlotRequestGenerator(t *testing.T) {
-//	req := planner.PlanningPostRequest{
-//		Country:   "USA",
-//		City:      "Seattle",
-//		Weekday:   0,
-//		StartTime: 8,
-//		EndTime:   20,
-//		NumVisit:  4,
-//		NumEatery: 3,
-//	}
-//
-//	slotRequests := planner.GenSlotRequests(req)
-//
-//	if len(slotRequests) != 3 {
-//		t.Errorf("wrong number of slot requests generated. expected: %d, got: %d", 3, len(slotRequests))
-//	}
-//
-//	expectedEvOptions := []string{"EVEV", "EV", "V"}
-//	for idx, expectedEvOption := range expectedEvOptions {
-//		if slotRequests[idx].EvOption != expectedEvOption {
-//			t.Errorf("expected EV option does not match. expected: %s, got: %s",
-//				expectedEvOption, slotRequests[idx].EvOption)
-//		}
-//	}
-//
-//	var expectedFirstSlotStayTimes []matching.TimeSlot
-//	expectedFirstSlotStayTimes = append(expectedFirstSlotStayTimes, matching.TimeSlot{Slot: POI.TimeInterval{8, 9}})
-//	expectedFirstSlotStayTimes = append(expectedFirstSlotStayTimes, matching.TimeSlot{Slot: POI.TimeInterval{9, 12}})
-//	expectedFirstSlotStayTimes = append(expectedFirstSlotStayTimes, matching.TimeSlot{Slot: POI.TimeInterval{12, 13}})
-//	expectedFirstSlotStayTimes = append(expectedFirstSlotStayTimes, matching.TimeSlot{Slot: POI.TimeInterval{13, 15}})
-//
-//	for idx, expectedFirstSlotStayTime := range expectedFirstSlotStayTimes {
-//		if slotRequests[0].StayTimes[idx] != expectedFirstSlotStayTime {
-//			t.Errorf("expected stay times does not match. expected: %v, got: %v",
-//				expectedFirstSlotStayTime, slotRequests[0].StayTimes[idx])
-//		}
-//	}
-//}
+import (
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/planner"
+	"testing"
+)
+
+func TestPostSlotRequestGenerator(t *testing.T) {
+	req := planner.PlanningPostRequest{
+		Country:   "USA",
+		City:      "Seattle",
+		Weekday:   0,
+		StartTime: 8,
+		EndTime:   20,
+		NumVisit:  4,
+		NumEatery: 3,
+	}
+
+	slotRequests := planner.GenSlotRequests(req)
+
+	expectedCategories := []POI.PlaceCategory{
+		POI.PlaceCategoryEatery, POI.PlaceCategoryVisit,
+		POI.PlaceCategoryEatery, POI.PlaceCategoryVisit,
+		POI.PlaceCategoryEatery, POI.PlaceCategoryVisit,
+		POI.PlaceCategoryVisit,
+	}
+	if len(slotRequests) != len(expectedCategories) {
+		t.Fatalf("wrong number of slot requests generated. expected: %d, got: %d", len(expectedCategories), len(slotRequests))
+	}
+
+	expectedIntervals := []POI.TimeInterval{
+		{Start: 8, End: 9}, {Start: 9, End: 12}, {Start: 12, End: 13}, {Start: 13, End: 15},
+		{Start: 15, End: 16}, {Start: 16, End: 18}, {Start: 18, End: 20},
+	}
+
+	for idx, slotRequest := range slotRequests {
+		if slotRequest.Category != expectedCategories[idx] {
+			t.Errorf("expected category does not match for slot %d. expected: %s, got: %s",
+				idx, expectedCategories[idx], slotRequest.Category)
+		}
+		if slotRequest.TimeSlot.Slot != expectedIntervals[idx] {
+			t.Errorf("expected time slot does not match for slot %d. expected: %v, got: %v",
+				idx, expectedIntervals[idx], slotRequest.TimeSlot.Slot)
+		}
+	}
+}
+
+func TestPostSlotRequestGeneratorMoreEateries(t *testing.T) {
+	req := planner.PlanningPostRequest{
+		StartTime: 10,
+		EndTime:   16,
+		NumVisit:  1,
+		NumEatery: 2,
+	}
+
+	slotRequests := planner.GenSlotRequests(req)
+
+	expectedCategories := []POI.PlaceCategory{POI.PlaceCategoryEatery, POI.PlaceCategoryVisit, POI.PlaceCategoryEatery}
+	expectedIntervals := []POI.TimeInterval{{Start: 10, End: 11}, {Start: 11, End: 15}, {Start: 15, End: 16}}
+	if len(slotRequests) != len(expectedCategories) {
+		t.Fatalf("wrong number of slot requests generated. expected: %d, got: %d", len(expectedCategories), len(slotRequests))
+	}
+	for idx, slotRequest := range slotRequests {
+		if slotRequest.Category != expectedCategories[idx] || slotRequest.TimeSlot.Slot != expectedIntervals[idx] {
+			t.Errorf("expected %s during %v for slot %d, got %s during %v", expectedCategories[idx],
+				expectedIntervals[idx], idx, slotRequest.Category, slotRequest.TimeSlot.Slot)
+		}
+	}
+}
@@ -42,3 +42,49 @@ func TestGetMultiSlotSolutions(t *testing.T) {
 		assert.Equal(t, cacheResponses[idx].SlotSolutionCandidate[0].PlaceIds, slotSolution.SlotSolutionCandidate[0].PlaceIds)
 	}
 }
+
+func TestSlotSolutionCachePlaceConstraints(t *testing.T) {
+	cacheRequest := iowrappers.SlotSolutionCacheRequest{
+		City:    "Chicago",
+		Country: "USA",
+	}
+	cacheResponse := iowrappers.SlotSolutionCacheResponse{}
+	cacheResponse.SlotSolutionCandidate = make([]iowrappers.SlotSolutionCandidateCache, 1)
+	cacheResponse.SlotSolutionCandidate[0].PlaceIds = []string{"1", "2", "3"}
+
+	RedisClient.CacheSlotSolution(RedisContext, cacheRequest, cacheResponse)
+
+	constrainedRequest := cacheRequest
+	constrainedRequest.PlaceConstraints = "pin=ChIJ@-1;exclude=;exclude_type="
+	responses := RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{cacheRequest, constrainedRequest})
+	if responses[0].Err != nil {
+		t.Fatal(responses[0].Err)
+	}
+	if responses[1].Err == nil {
+		t.Error("expected a cache miss for a request with place constraints")
+	}
+}
+
+func TestSlotSolutionCacheScoreWeights(t *testing.T) {
+	cacheRequest := iowrappers.SlotSolutionCacheRequest{
+		City:    "Evanston",
+		Country: "USA",
+	}
+	cacheResponse := iowrappers.SlotSolutionCacheResponse{}
+	cacheResponse.SlotSolutionCandidate = make([]iowrappers.SlotSolutionCandidateCache, 1)
+	cacheResponse.SlotSolutionCandidate[0].PlaceIds = []string{"1", "2"}
+	cacheResponse.SlotSolutionCandidate[0].ScoreBreakdown = iowrappers.ScoreBreakdownCache{Quality: 1.5, Distance: 0.5, Total: 1}
+
+	RedisClient.CacheSlotSolution(RedisContext, cacheRequest, cacheResponse)
+
+	weightedRequest := cacheRequest
+	weightedRequest.ScoreWeights = "2_1_1_1_0"
+	responses := RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{cacheRequest, weightedRequest})
+	if responses[0].Err != nil {
+		t.Fatal(responses[0].Err)
+	}
+	assert.Equal(t, cacheResponse.SlotSolutionCandidate[0].ScoreBreakdown, responses[0].SlotSolutionCandidate[0].ScoreBreakdown)
+	if responses[1].Err == nil {
+		t.Error("expected a cache miss for a request with different score weights")
+	}
+}
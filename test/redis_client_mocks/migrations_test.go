@@ -0,0 +1,203 @@
+package redis_client_mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// counterMigration doubles counters in batches of 2, and batches wait for the gate if it is not nil
+type counterMigration struct {
+	name    string
+	version int
+	gate    chan struct{}
+	failAt  string // checkpoint of the batch failing once
+
+	mutex       sync.Mutex
+	counters    []int
+	checkpoints []string
+}
+
+func (migration *counterMigration) Name() string {
+	return migration.name
+}
+
+func (migration *counterMigration) Version() int {
+	return migration.version
+}
+
+func (migration *counterMigration) Batch(_ context.Context, checkpoint string, dryRun bool) (batch iowrappers.MigrationBatch, err error) {
+	if migration.gate != nil {
+		<-migration.gate
+	}
+	migration.mutex.Lock()
+	defer migration.mutex.Unlock()
+	migration.checkpoints = append(migration.checkpoints, checkpoint)
+	if checkpoint != "" && checkpoint == migration.failAt {
+		migration.failAt = ""
+		return batch, errors.New("connection reset")
+	}
+	start, _ := strconv.Atoi(checkpoint)
+	end := start + 2
+	if end >= len(migration.counters) {
+		end, batch.Done = len(migration.counters), true
+	}
+	for idx := start; idx < end; idx++ {
+		batch.Changes = append(batch.Changes, fmt.Sprintf("counter %d: %d -> %d", idx, migration.counters[idx], 2*migration.counters[idx]))
+		if !dryRun {
+			migration.counters[idx] *= 2
+		}
+	}
+	batch.Checkpoint, batch.Processed = strconv.Itoa(end), end-start
+	return
+}
+
+func (migration *counterMigration) state() ([]int, []string) {
+	migration.mutex.Lock()
+	defer migration.mutex.Unlock()
+	return append([]int(nil), migration.counters...), append([]string(nil), migration.checkpoints...)
+}
+
+// waitForMigration polls the status of the migration until the condition holds
+func waitForMigration(t *testing.T, registry *iowrappers.MigrationRegistry, name string, condition func(status iowrappers.MigrationStatus) bool) iowrappers.MigrationStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := registry.Status(RedisContext, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if condition(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for migration %s, status: %+v", name, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// resumeMigration resumes the migration once the paused run releases its lock
+func resumeMigration(t *testing.T, registry *iowrappers.MigrationRegistry, name string) (status iowrappers.MigrationStatus, err error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err = registry.Resume(RedisContext, name)
+		if !errors.Is(err, iowrappers.ErrMigrationRunning) || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func hasState(state string) func(status iowrappers.MigrationStatus) bool {
+	return func(status iowrappers.MigrationStatus) bool { return status.State == state }
+}
+
+func TestMigrationPauseAndResume(t *testing.T) {
+	iowrappers.CreateLogger()
+	migration := &counterMigration{name: "test-pause-resume", version: 1, gate: make(chan struct{}), counters: []int{1, 2, 3, 4, 5}}
+	registry := iowrappers.CreateMigrationRegistry(&RedisClient)
+	if err := registry.Register(migration); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(migration); err == nil {
+		t.Error("expected migrations of the same name not to be registered")
+	}
+	if status, _ := registry.Status(RedisContext, migration.name); status.State != iowrappers.MigrationStateNotStarted {
+		t.Errorf("expected the migration not to be started, got %s", status.State)
+	}
+
+	if _, err := registry.Start(RedisContext, migration.name, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Start(RedisContext, migration.name, false); !errors.Is(err, iowrappers.ErrMigrationRunning) {
+		t.Errorf("expected the running migration not to be started again, got %v", err)
+	}
+	// the first batch is waiting for the gate while the migration is paused
+	if status, err := registry.Pause(RedisContext, migration.name); err != nil || status.State != iowrappers.MigrationStatePaused {
+		t.Fatalf("expected the migration to be paused, got %+v, %v", status, err)
+	}
+	migration.gate <- struct{}{}
+	status := waitForMigration(t, registry, migration.name, func(status iowrappers.MigrationStatus) bool { return status.Batches == 1 })
+	if status.State != iowrappers.MigrationStatePaused || status.Checkpoint != "2" || status.Processed != 2 {
+		t.Errorf("expected the migration to be paused after the first batch, got %+v", status)
+	}
+	if _, err := registry.Pause(RedisContext, migration.name); !errors.Is(err, iowrappers.ErrMigrationNotRunning) {
+		t.Errorf("expected the paused migration not to be paused again, got %v", err)
+	}
+
+	close(migration.gate)
+	if _, err := resumeMigration(t, registry, migration.name); err != nil {
+		t.Fatal(err)
+	}
+	status = waitForMigration(t, registry, migration.name, hasState(iowrappers.MigrationStateCompleted))
+	counters, checkpoints := migration.state()
+	if fmt.Sprint(counters) != "[2 4 6 8 10]" || fmt.Sprint(checkpoints) != "[ 2 4]" {
+		t.Errorf("expected every counter to be doubled once from the checkpoints, got %v from %v", counters, checkpoints)
+	}
+	if status.Batches != 3 || status.Processed != 5 || status.Changed != 5 || len(status.Changes) != 5 {
+		t.Errorf("unexpected progress %+v", status)
+	}
+	if _, err := registry.Resume(RedisContext, migration.name); !errors.Is(err, iowrappers.ErrMigrationNotResumable) {
+		t.Errorf("expected the completed migration not to be resumed, got %v", err)
+	}
+	if _, err := registry.Status(RedisContext, "test-unknown"); !errors.Is(err, iowrappers.ErrMigrationNotFound) {
+		t.Errorf("expected unknown migrations not to be found, got %v", err)
+	}
+}
+
+func TestMigrationDryRun(t *testing.T) {
+	iowrappers.CreateLogger()
+	migration := &counterMigration{name: "test-dry-run", version: 1, counters: []int{1, 2, 3}}
+	registry := iowrappers.CreateMigrationRegistry(&RedisClient)
+	_ = registry.Register(migration)
+
+	if _, err := registry.Start(RedisContext, migration.name, true); err != nil {
+		t.Fatal(err)
+	}
+	status := waitForMigration(t, registry, migration.name, hasState(iowrappers.MigrationStateCompleted))
+	if counters, _ := migration.state(); fmt.Sprint(counters) != "[1 2 3]" {
+		t.Errorf("expected no changes written by the dry run, got %v", counters)
+	}
+	if !status.DryRun || status.Changed != 3 || status.Changes[0] != "counter 0: 1 -> 2" {
+		t.Errorf("expected the changes of the dry run to be reported, got %+v", status)
+	}
+	statuses, err := registry.Statuses(RedisContext)
+	if err != nil || len(statuses) != 1 || statuses[0].Name != migration.name {
+		t.Errorf("expected the status of the registered migration, got %+v, %v", statuses, err)
+	}
+}
+
+func TestMigrationFailures(t *testing.T) {
+	iowrappers.CreateLogger()
+	migration := &counterMigration{name: "test-failures", version: 1, failAt: "2", counters: []int{1, 2, 3, 4}}
+	registry := iowrappers.CreateMigrationRegistry(&RedisClient)
+	_ = registry.Register(migration)
+
+	if _, err := registry.Start(RedisContext, migration.name, false); err != nil {
+		t.Fatal(err)
+	}
+	status := waitForMigration(t, registry, migration.name, hasState(iowrappers.MigrationStateFailed))
+	if status.Error != "connection reset" || status.Checkpoint != "2" {
+		t.Errorf("expected the migration to fail at the second batch, got %+v", status)
+	}
+
+	// runs of other versions of the migration are not resumed
+	migration.version = 2
+	if _, err := resumeMigration(t, registry, migration.name); !errors.Is(err, iowrappers.ErrMigrationNotResumable) {
+		t.Errorf("expected the run of the old version not to be resumed, got %v", err)
+	}
+	migration.version = 1
+	if _, err := resumeMigration(t, registry, migration.name); err != nil {
+		t.Fatal(err)
+	}
+	status = waitForMigration(t, registry, migration.name, hasState(iowrappers.MigrationStateCompleted))
+	if counters, _ := migration.state(); fmt.Sprint(counters) != "[2 4 6 8]" || status.Error != "" {
+		t.Errorf("expected the failed run to be resumed from the checkpoint, got %v, %+v", counters, status)
+	}
+}
@@ -0,0 +1,46 @@
+package redis_client_mocks
+
+import (
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func TestSlotPlacesCache(t *testing.T) {
+	places := []POI.Place{
+		{ID: "4004", Name: "Art Institute of Chicago", LocationType: POI.LocationTypeMuseum,
+			Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6237, 41.8796}}},
+		{ID: "5005", Name: "Millennium Park", LocationType: POI.LocationTypePark,
+			Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6226, 41.8826}}},
+	}
+	RedisClient.SetPlacesOnCategory(RedisContext, places)
+
+	cacheRequest := iowrappers.SlotPlacesCacheRequest{
+		Country:  "USA",
+		City:     "Chicago",
+		Radius:   5000,
+		Weekday:  POI.DateMonday,
+		Interval: POI.TimeInterval{Start: 10, End: 12},
+		Category: POI.PlaceCategoryVisit,
+	}
+	if _, err := RedisClient.GetSlotPlaces(RedisContext, cacheRequest); err == nil {
+		t.Fatal("expected a cache miss before slot places are cached")
+	}
+
+	// places without cached details are skipped
+	RedisClient.CacheSlotPlaces(RedisContext, cacheRequest, []string{"5005", "unknown", "4004"})
+	cachedPlaces, err := RedisClient.GetSlotPlaces(RedisContext, cacheRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cachedPlaces) != 2 || cachedPlaces[0].ID != "5005" || cachedPlaces[1].ID != "4004" {
+		t.Errorf("expected places 5005 and 4004 in order, got %v", cachedPlaces)
+	}
+
+	otherSlot := cacheRequest
+	otherSlot.Interval = POI.TimeInterval{Start: 12, End: 14}
+	if _, err = RedisClient.GetSlotPlaces(RedisContext, otherSlot); err == nil {
+		t.Error("expected a cache miss for a different slot")
+	}
+}
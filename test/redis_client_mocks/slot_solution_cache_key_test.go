@@ -0,0 +1,168 @@
+package redis_client_mocks
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+// randomSlotSolutionCacheRequest draws fields from small domains so that random requests often share fields
+func randomSlotSolutionCacheRequest(rnd *rand.Rand) iowrappers.SlotSolutionCacheRequest {
+	numSlots := 1 + rnd.Intn(3)
+	req := iowrappers.SlotSolutionCacheRequest{
+		Country:        []string{"usa", "canada"}[rnd.Intn(2)],
+		City:           []string{"chicago", "toronto", "new york"}[rnd.Intn(3)],
+		Radius:         uint64(5000 * (1 + rnd.Intn(2))),
+		EVTags:         make([]string, numSlots),
+		Intervals:      make([]POI.TimeInterval, numSlots),
+		Weekday:        POI.Weekday(rnd.Intn(7)),
+		OptimizedRoute: rnd.Intn(2) == 0,
+		ScorerVersion:  []string{"", "1"}[rnd.Intn(2)],
+	}
+	for idx := range req.EVTags {
+		req.EVTags[idx] = []string{"e", "v"}[rnd.Intn(2)]
+		start := POI.Hour(rnd.Intn(8))
+		req.Intervals[idx] = POI.TimeInterval{Start: start, End: start + POI.Hour(1+rnd.Intn(8))}
+	}
+	if rnd.Intn(3) == 0 {
+		req.PlaceConstraints = []string{"pin=ChIJ@-1;exclude=;exclude_type=", "pin=chij@-1;exclude=;exclude_type="}[rnd.Intn(2)]
+	}
+	if rnd.Intn(3) == 0 {
+		req.ScoreWeights = []string{"2_1_1_1_0", "1_2_1_1_0"}[rnd.Intn(2)]
+	}
+	if rnd.Intn(3) == 0 {
+		req.Diversity = []string{"0.5", "1"}[rnd.Intn(2)]
+	}
+	return req
+}
+
+type slotSolutionCacheRequestPair struct {
+	First, Second iowrappers.SlotSolutionCacheRequest
+}
+
+func (slotSolutionCacheRequestPair) Generate(rnd *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(slotSolutionCacheRequestPair{First: randomSlotSolutionCacheRequest(rnd), Second: randomSlotSolutionCacheRequest(rnd)})
+}
+
+func TestSlotSolutionCacheKeysNeverCollide(t *testing.T) {
+	// keys are equal if and only if requests are equal
+	property := func(pair slotSolutionCacheRequestPair) bool {
+		sameKeys := iowrappers.SlotSolutionCacheKey(pair.First) == iowrappers.SlotSolutionCacheKey(pair.Second)
+		return sameKeys == reflect.DeepEqual(pair.First, pair.Second)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 20000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlotSolutionCacheKeysAreCanonical(t *testing.T) {
+	// keys ignore the case of locations and EV tags, and the key of a copy of a request is the same
+	property := func(pair slotSolutionCacheRequestPair) bool {
+		req := pair.First
+		variant := req
+		variant.Country, variant.City = strings.ToUpper(req.Country), " "+strings.Title(req.City)
+		variant.EVTags = make([]string, len(req.EVTags))
+		for idx, evTag := range req.EVTags {
+			variant.EVTags[idx] = strings.ToUpper(evTag)
+		}
+		variant.Intervals = append([]POI.TimeInterval(nil), req.Intervals...)
+		key := iowrappers.SlotSolutionCacheKey(req)
+		return key == iowrappers.SlotSolutionCacheKey(variant) &&
+			strings.HasPrefix(key, strings.Join([]string{"slot_solution", iowrappers.SlotSolutionCacheVersion, req.Country, req.City}, ":")+":")
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlotSolutionCacheKeyRegressions(t *testing.T) {
+	base := iowrappers.SlotSolutionCacheRequest{
+		Country:   "USA",
+		City:      "Chicago",
+		Radius:    5000,
+		EVTags:    []string{"v"},
+		Intervals: []POI.TimeInterval{{Start: 2, End: 6}},
+		Weekday:   POI.DateMonday,
+	}
+	// slots with the same products of start and end hours
+	sameProduct := base
+	sameProduct.Intervals = []POI.TimeInterval{{Start: 3, End: 4}}
+	// an eatery slot of twice the product of a visit slot
+	eatery := base
+	eatery.EVTags = []string{"e"}
+	eatery.Intervals = []POI.TimeInterval{{Start: 4, End: 6}}
+	otherWeekday := base
+	otherWeekday.Weekday = POI.DateTuesday
+	otherScorer := base
+	otherScorer.ScorerVersion = "2"
+
+	keys := make(map[string]int)
+	for idx, req := range []iowrappers.SlotSolutionCacheRequest{base, sameProduct, eatery, otherWeekday, otherScorer} {
+		key := iowrappers.SlotSolutionCacheKey(req)
+		if strings.IndexFunc(key, func(r rune) bool { return r < ' ' }) >= 0 {
+			t.Errorf("expected no control characters in key %q", key)
+		}
+		if prevIdx, exists := keys[key]; exists {
+			t.Errorf("requests %d and %d share the key %s", prevIdx, idx, key)
+		}
+		keys[key] = idx
+	}
+}
+
+func TestRemoveOutdatedSlotSolutions(t *testing.T) {
+	cacheRequest := iowrappers.SlotSolutionCacheRequest{City: "Chicago", Country: "USA", EVTags: []string{"v"}, Intervals: []POI.TimeInterval{{Start: 10, End: 12}}}
+	cacheResponse := iowrappers.SlotSolutionCacheResponse{SlotSolutionCandidate: []iowrappers.SlotSolutionCandidateCache{{PlaceIds: []string{"1"}}}}
+	RedisClient.CacheSlotSolution(RedisContext, cacheRequest, cacheResponse)
+	RedisClient.CacheMultiDaySolution(RedisContext, iowrappers.MultiDaySolutionCacheRequest{Days: []iowrappers.SlotSolutionCacheRequest{cacheRequest}},
+		iowrappers.MultiDaySolutionCacheResponse{})
+	// keys of the unversioned scheme
+	outdatedKeys := []string{"slot_solution:usa:chicago:5000:\x00:120", "multi_day_solution:1:slot_solution:usa:chicago:5000:\x00:120"}
+	for _, key := range outdatedKeys {
+		if err := RedisMockSvr.Set(key, "{}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = RedisMockSvr.Set("slot_places:canada:toronto:5000:0:10_12:visit", "[]")
+
+	removedCount, err := RedisClient.RemoveOutdatedSlotSolutions(RedisContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removedCount != len(outdatedKeys) {
+		t.Errorf("expected %d keys to be removed, got %d", len(outdatedKeys), removedCount)
+	}
+	for _, key := range outdatedKeys {
+		if RedisMockSvr.Exists(key) {
+			t.Errorf("expected key %q to be removed", key)
+		}
+	}
+	if responses := RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{cacheRequest}); responses[0].Err != nil {
+		t.Errorf("expected the current slot solution to be kept, got %v", responses[0].Err)
+	}
+	if response := RedisClient.GetMultiDaySolution(RedisContext, iowrappers.MultiDaySolutionCacheRequest{Days: []iowrappers.SlotSolutionCacheRequest{cacheRequest}}); response.Err != nil {
+		t.Errorf("expected the current multi-day solution to be kept, got %v", response.Err)
+	}
+	if !RedisMockSvr.Exists("slot_places:canada:toronto:5000:0:10_12:visit") {
+		t.Error("expected other keys to be kept")
+	}
+}
+
+func TestMultiDaySolutionCacheKeyNumPlans(t *testing.T) {
+	dayRequest := iowrappers.SlotSolutionCacheRequest{City: "Detroit", Country: "USA", EVTags: []string{"v"}, Intervals: []POI.TimeInterval{{Start: 10, End: 12}}}
+	tripRequest := iowrappers.MultiDaySolutionCacheRequest{Days: []iowrappers.SlotSolutionCacheRequest{dayRequest, dayRequest}, NumPlans: 1}
+	RedisClient.CacheMultiDaySolution(RedisContext, tripRequest, iowrappers.MultiDaySolutionCacheResponse{})
+	if response := RedisClient.GetMultiDaySolution(RedisContext, tripRequest); response.Err != nil {
+		t.Errorf("expected the cached trips to be found, got %v", response.Err)
+	}
+
+	// trips of a request for more plans are searched from more solutions of each day
+	tripRequest.NumPlans = 3
+	if response := RedisClient.GetMultiDaySolution(RedisContext, tripRequest); response.Err == nil {
+		t.Error("expected requests of other numbers of plans not to share cached trips")
+	}
+}
@@ -0,0 +1,99 @@
+package redis_client_mocks
+
+import (
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+)
+
+func cacheInvalidationTestSolutions(t *testing.T, city string) (iowrappers.SlotSolutionCacheRequest, iowrappers.MultiDaySolutionCacheRequest) {
+	slotRequest := iowrappers.SlotSolutionCacheRequest{Country: "USA", City: city, EVTags: []string{"v", "e"},
+		Intervals: []POI.TimeInterval{{Start: 10, End: 12}, {Start: 12, End: 13}}}
+	RedisClient.CacheSlotSolution(RedisContext, slotRequest, iowrappers.SlotSolutionCacheResponse{
+		SlotSolutionCandidate: []iowrappers.SlotSolutionCandidateCache{{PlaceIds: []string{city + "-museum", city + "-cafe"}}},
+	})
+	tripRequest := iowrappers.MultiDaySolutionCacheRequest{Days: []iowrappers.SlotSolutionCacheRequest{slotRequest, slotRequest}}
+	RedisClient.CacheMultiDaySolution(RedisContext, tripRequest, iowrappers.MultiDaySolutionCacheResponse{
+		Trips: []iowrappers.TripSolutionCache{{Days: []iowrappers.SlotSolutionCandidateCache{{PlaceIds: []string{city + "-park"}}, {PlaceIds: []string{city + "-cafe"}}}}},
+	})
+	if RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err != nil ||
+		RedisClient.GetMultiDaySolution(RedisContext, tripRequest).Err != nil {
+		t.Fatal("expected solutions to be cached")
+	}
+	return slotRequest, tripRequest
+}
+
+func TestInvalidatePlaceSolutions(t *testing.T) {
+	slotRequest, tripRequest := cacheInvalidationTestSolutions(t, "Springfield")
+	statsBefore, _ := RedisClient.GetSolutionInvalidationStats(RedisContext)
+
+	// the park is only in the trip
+	invalidatedCount, err := RedisClient.InvalidatePlaceSolutions(RedisContext, iowrappers.InvalidationReasonAdmin, "Springfield-park")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invalidatedCount != 1 || RedisClient.GetMultiDaySolution(RedisContext, tripRequest).Err == nil {
+		t.Errorf("expected the trip with the park to be invalidated, got %d invalidated solutions", invalidatedCount)
+	}
+	if RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err != nil {
+		t.Error("expected the slot solution without the park to be kept")
+	}
+
+	// the cafe is in both, and the trip is already invalidated
+	if invalidatedCount, err = RedisClient.InvalidatePlaceSolutions(RedisContext, iowrappers.InvalidationReasonAdmin, "Springfield-cafe", "Springfield-museum"); err != nil {
+		t.Fatal(err)
+	}
+	if invalidatedCount != 1 || RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err == nil {
+		t.Errorf("expected the slot solution with the cafe to be invalidated, got %d invalidated solutions", invalidatedCount)
+	}
+	if RedisMockSvr.Exists(iowrappers.PlaceSolutionsKeyPrefix + "Springfield-cafe") {
+		t.Error("expected the solution keys of the cafe to be removed")
+	}
+
+	stats, err := RedisClient.GetSolutionInvalidationStats(RedisContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminStats, adminStatsBefore := stats[iowrappers.InvalidationReasonAdmin], statsBefore[iowrappers.InvalidationReasonAdmin]
+	if adminStats.Places-adminStatsBefore.Places != 3 || adminStats.Solutions-adminStatsBefore.Solutions != 2 {
+		t.Errorf("expected 3 places and 2 solutions invalidated by admins, got %+v", adminStats)
+	}
+}
+
+func TestPlaceUpdatesInvalidateSolutions(t *testing.T) {
+	place := POI.Place{ID: "Shelbyville-museum", Name: "Shelbyville Museum", Status: POI.Operational, LocationType: POI.LocationTypeMuseum,
+		Location: POI.Location{Type: "point", Coordinates: [2]float64{-87.6237, 41.8796}}, URL: "https://maps.google.com/?cid=1", Rating: 4.5}
+	RedisClient.SetPlacesOnCategory(RedisContext, []POI.Place{place})
+	slotRequest, _ := cacheInvalidationTestSolutions(t, "Shelbyville")
+	statsBefore, _ := RedisClient.GetSolutionInvalidationStats(RedisContext)
+
+	// ratings change often and keep cached solutions
+	place.Rating = 4.6
+	place.UserRatingsTotal = 1000
+	RedisClient.SetPlacesOnCategory(RedisContext, []POI.Place{place})
+	if RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err != nil {
+		t.Fatal("expected solutions to be kept after rating changes")
+	}
+
+	place.Status = POI.ClosedPermanently
+	RedisClient.SetPlacesOnCategory(RedisContext, []POI.Place{place})
+	if RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err == nil {
+		t.Error("expected solutions with the closed place to be invalidated")
+	}
+
+	slotRequest, _ = cacheInvalidationTestSolutions(t, "Shelbyville")
+	place.URL = "https://maps.google.com/?cid=2"
+	RedisClient.SetPlacesOnCategory(RedisContext, []POI.Place{place})
+	if RedisClient.GetMultiSlotSolutions(RedisContext, []iowrappers.SlotSolutionCacheRequest{slotRequest})[0].Err == nil {
+		t.Error("expected solutions with the place of a new URL to be invalidated")
+	}
+
+	stats, _ := RedisClient.GetSolutionInvalidationStats(RedisContext)
+	if closed := stats[iowrappers.InvalidationReasonPlaceClosed]; closed.Places-statsBefore[iowrappers.InvalidationReasonPlaceClosed].Places != 1 {
+		t.Errorf("expected 1 closed place, got %+v", closed)
+	}
+	if updated := stats[iowrappers.InvalidationReasonPlaceUpdate]; updated.Solutions-statsBefore[iowrappers.InvalidationReasonPlaceUpdate].Solutions != 1 {
+		t.Errorf("expected 1 solution invalidated by place updates, got %+v", updated)
+	}
+}
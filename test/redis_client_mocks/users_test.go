@@ -65,3 +65,23 @@ func TestUserCreation(t *testing.T) {
 	usr.Password = ""
 	assert.Equal(t, expectedUser, usr)
 }
+
+func TestSetUserScoreWeights(t *testing.T) {
+	username := "keanu_reeves"
+	if err := RedisClient.SetUserScoreWeights(RedisContext, username, `{"rating":2}`); err == nil {
+		t.Error("expected an error for a user that does not exist")
+	}
+
+	if err := RedisClient.CreateUser(RedisContext, user.User{Username: username, Email: "keanu_reeves@gmail.com"}); err != nil {
+		t.Fatal(err)
+	}
+	scoreWeights := `{"rating":2,"review_count":1,"price":0,"distance":1,"diversity":0.5}`
+	if err := RedisClient.SetUserScoreWeights(RedisContext, username, scoreWeights); err != nil {
+		t.Fatal(err)
+	}
+	usr, err := RedisClient.FindUser(RedisContext, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, scoreWeights, usr.ScoreWeights)
+}
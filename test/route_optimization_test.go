@@ -0,0 +1,128 @@
+package test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/graph"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+func routeDistance(points []graph.Point, order []int) (distance float64) {
+	for idx := 1; idx < len(order); idx++ {
+		from, to := points[order[idx-1]], points[order[idx]]
+		distance += utils.HaversineDist([]float64{from.Lat, from.Lng}, []float64{to.Lat, to.Lng})
+	}
+	return
+}
+
+// shortestRoute tries all orders of points with pinned points at their positions
+func shortestRoute(points []graph.Point, pinned []bool, compatible graph.Compatibility) float64 {
+	order := make([]int, len(points))
+	used := make([]bool, len(points))
+	best := math.Inf(1)
+	var permute func(position int)
+	permute = func(position int) {
+		if position == len(points) {
+			best = math.Min(best, routeDistance(points, order))
+			return
+		}
+		for point := range points {
+			if used[point] || pinned[point] != pinned[position] || (pinned[point] && point != position) ||
+				(compatible != nil && !compatible(point, position)) {
+				continue
+			}
+			used[point] = true
+			order[position] = point
+			permute(position + 1)
+			used[point] = false
+		}
+	}
+	permute(0)
+	return best
+}
+
+func randomPoints(random *rand.Rand, numPoints int) []graph.Point {
+	points := make([]graph.Point, numPoints)
+	for idx := range points {
+		points[idx] = graph.Point{Lat: 41.8 + random.Float64()/10, Lng: -87.7 + random.Float64()/10}
+	}
+	return points
+}
+
+func checkRoute(t *testing.T, order []int, pinned []bool) {
+	seen := make(map[int]bool)
+	for position, point := range order {
+		if seen[point] {
+			t.Fatalf("point %d is visited twice in %v", point, order)
+		}
+		seen[point] = true
+		if pinned[point] && point != position {
+			t.Errorf("pinned point %d is moved to position %d", point, position)
+		}
+	}
+}
+
+func TestOptimizeRouteExact(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	for round := 0; round < 5; round++ {
+		points := randomPoints(random, 7)
+		pinned := []bool{false, false, true, false, false, true, false}
+		// point 0 cannot be visited at the last position
+		compatible := func(point int, position int) bool {
+			return point != 0 || position != len(points)-1
+		}
+
+		order, distance := graph.OptimizeRoute(points, pinned, compatible)
+		checkRoute(t, order, pinned)
+		if order[len(order)-1] == 0 {
+			t.Error("point 0 is visited at an incompatible position")
+		}
+		if expected := shortestRoute(points, pinned, compatible); math.Abs(distance-expected) > 1e-6 {
+			t.Errorf("expected the shortest distance %f, got %f", expected, distance)
+		}
+		if math.Abs(distance-routeDistance(points, order)) > 1e-6 {
+			t.Errorf("total distance %f does not match the route %v", distance, order)
+		}
+	}
+}
+
+func TestOptimizeRouteApproximate(t *testing.T) {
+	// more points than the exact algorithm handles, placed on a line in random order
+	numPoints := graph.ExactRouteMaxPoints + 4
+	random := rand.New(rand.NewSource(5))
+	points := make([]graph.Point, numPoints)
+	for idx, offset := range random.Perm(numPoints) {
+		points[idx] = graph.Point{Lat: 41.8 + float64(offset)/100, Lng: -87.6}
+	}
+	pinned := make([]bool, numPoints)
+
+	order, distance := graph.OptimizeRoute(points, pinned, nil)
+	checkRoute(t, order, pinned)
+
+	identity := make([]int, numPoints)
+	for idx := range identity {
+		identity[idx] = idx
+	}
+	lineLength := utils.HaversineDist([]float64{41.8, -87.6}, []float64{41.8 + float64(numPoints-1)/100, -87.6})
+	if distance > routeDistance(points, identity) || distance > 2*lineLength {
+		t.Errorf("route of %f meters is too long, the shortest route is %f meters", distance, lineLength)
+	}
+}
+
+func TestOptimizeVisitOrderKeepsEateries(t *testing.T) {
+	// a detour between two museums next to each other, and lunch in the middle of the day
+	locations := [][2]float64{{-87.62, 41.88}, {-87.70, 41.95}, {-87.63, 41.90}, {-87.621, 41.881}}
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit}
+
+	order, _ := solution.OptimizeVisitOrder(locations, categories, nil)
+	if order[2] != 2 {
+		t.Errorf("the eatery should keep its slot, got order %v", order)
+	}
+	if order[1] == 1 {
+		t.Errorf("expected the distant place to be moved away from the second slot, got order %v", order)
+	}
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+	"github.com/weihesdlegend/Vacation-planner/utils"
+)
+
+func createScoredPlace(id string, locationType POI.LocationType, priceLevel int, rating float32, ratingsCount int, location [2]float64) matching.Place {
+	place := POI.Place{ID: id, Name: id, LocationType: locationType, PriceLevel: priceLevel, Rating: rating, UserRatingsTotal: ratingsCount}
+	place.SetLocation(location)
+	return matching.CreatePlace(place, POI.PlaceCategoryVisit)
+}
+
+// legacyScore is the score in the score design doc before score weights were introduced
+func legacyScore(places []matching.Place) float64 {
+	var sumPlaceScores float64
+	for _, place := range places {
+		sumPlaceScores += matching.SinglePlaceScore(place)
+	}
+	avgPlaceScore := sumPlaceScores / float64(len(places))
+	if len(places) == 1 {
+		return avgPlaceScore
+	}
+	distances := make([]float64, len(places)-1)
+	var sumDistances, maxDistance float64
+	for idx := range distances {
+		x, y := places[idx].GetLocation(), places[idx+1].GetLocation()
+		distances[idx] = utils.HaversineDist([]float64{x[0], x[1]}, []float64{y[0], y[1]})
+		sumDistances += distances[idx]
+		maxDistance = math.Max(maxDistance, distances[idx])
+	}
+	return avgPlaceScore - sumDistances/float64(len(distances))/math.Max(0.001, maxDistance)
+}
+
+func TestDefaultScorerMatchesLegacyScore(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	categorizedPlaces := generateSlotPlaces(random, []POI.PlaceCategory{POI.PlaceCategoryVisit}, 8)
+	places := categorizedPlaces[0].VisitPlaces
+	for numPlaces := 1; numPlaces <= len(places); numPlaces++ {
+		expected := legacyScore(places[:numPlaces])
+		if score := matching.DefaultScorer.Score(places[:numPlaces]); math.Abs(score-expected) > 1e-9 {
+			t.Errorf("expected score %f of %d places with default weights, got %f", expected, numPlaces, score)
+		}
+	}
+}
+
+func TestScoreWeightsChangeRanking(t *testing.T) {
+	// a popular but expensive place against a cheap place with few reviews
+	popular := createScoredPlace("popular", POI.LocationTypeMuseum, 4, 4.5, 20000, [2]float64{-87.62, 41.88})
+	cheap := createScoredPlace("cheap", POI.LocationTypePark, 1, 4.0, 30, [2]float64{-87.63, 41.89})
+
+	defaultScorer := matching.DefaultScorer
+	if defaultScorer.PlaceScore(cheap) <= defaultScorer.PlaceScore(popular) {
+		t.Fatal("expected the cheap place to rank first with the default weights")
+	}
+	popularityScorer := matching.CreateWeightedScorer(matching.ScoreWeights{Rating: 1, ReviewCount: 5, Price: 0, Distance: 1})
+	if popularityScorer.PlaceScore(popular) <= popularityScorer.PlaceScore(cheap) {
+		t.Error("expected the popular place to rank first when review counts weigh more than prices")
+	}
+
+	diversityScorer := matching.CreateWeightedScorer(matching.ScoreWeights{Rating: 1, ReviewCount: 1, Price: 1, Distance: 1, Diversity: 2})
+	breakdown := diversityScorer.Breakdown([]matching.Place{popular, cheap})
+	if breakdown.Diversity != 2 {
+		t.Errorf("expected diversity bonus 2 for places of different location types, got %f", breakdown.Diversity)
+	}
+	if math.Abs(breakdown.Total-(breakdown.Quality-breakdown.Distance+breakdown.Diversity)) > 1e-9 {
+		t.Errorf("breakdown total %f does not add up", breakdown.Total)
+	}
+}
+
+func TestScorerUpperBound(t *testing.T) {
+	random := rand.New(rand.NewSource(11))
+	scorers := []matching.Scorer{
+		matching.DefaultScorer,
+		matching.CreateWeightedScorer(matching.ScoreWeights{Rating: 2, ReviewCount: 0.5, Price: 0, Distance: 3, Diversity: 1.5}),
+	}
+	for _, scorer := range scorers {
+		for round := 0; round < 50; round++ {
+			categorizedPlaces := generateSlotPlaces(random, []POI.PlaceCategory{POI.PlaceCategoryVisit}, 2+random.Intn(5))
+			places := categorizedPlaces[0].VisitPlaces
+			var sumPlaceScores float64
+			for _, place := range places {
+				sumPlaceScores += scorer.PlaceScore(place)
+			}
+			bound := scorer.UpperBound(sumPlaceScores/float64(len(places)), len(places), true)
+			if score := scorer.Score(places); score > bound+1e-9 {
+				t.Fatalf("score %f exceeds the upper bound %f", score, bound)
+			}
+		}
+	}
+}
+
+func TestScoreWeightsValidateAndSerialize(t *testing.T) {
+	if err := matching.DefaultScoreWeights.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, weights := range []matching.ScoreWeights{{Rating: -1}, {Distance: matching.MaxScoreWeight + 1}, {Diversity: math.NaN()}} {
+		if weights.Validate() == nil {
+			t.Errorf("expected weights %v to be invalid", weights)
+		}
+	}
+
+	if serialized := matching.DefaultScoreWeights.Serialize(); serialized != "" {
+		t.Errorf("expected the default weights to serialize to an empty string, got %s", serialized)
+	}
+	weights := matching.ScoreWeights{Rating: 2, ReviewCount: 1, Price: 0.5, Distance: 1}
+	if serialized := weights.Serialize(); serialized != "2_1_0.5_1_0" {
+		t.Errorf("expected 2_1_0.5_1_0, got %s", serialized)
+	}
+}
+
+func TestSearchTopSolutionsScoreBreakdown(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit}
+	categorizedPlaces := generateSlotPlaces(random, categories, 5)
+	scorer := matching.CreateWeightedScorer(matching.ScoreWeights{Rating: 2, ReviewCount: 1, Price: 0.5, Distance: 2, Diversity: 1})
+
+	solutions, err := solution.SearchTopSolutions(categories, categorizedPlaces, 5, nil, scorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := solution.EnumerateTopSolutions(categories, categorizedPlaces, 5, nil, scorer)
+	if len(solutions) != len(expected) {
+		t.Fatalf("expected %d plans, got %d", len(expected), len(solutions))
+	}
+	for idx, planningSolution := range solutions {
+		if math.Abs(planningSolution.Score-expected[idx].Score) > 1e-9 {
+			t.Errorf("plan %d: expected score %f, got %f", idx, expected[idx].Score, planningSolution.Score)
+		}
+		if planningSolution.ScoreBreakdown.Total != planningSolution.Score {
+			t.Errorf("plan %d: breakdown total %f differs from score %f", idx, planningSolution.ScoreBreakdown.Total, planningSolution.Score)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+func TestRankSlotAlternatives(t *testing.T) {
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit}
+	categorizedPlaces := generateSlotPlaces(rand.New(rand.NewSource(5)), categories, 8)
+	plans, err := solution.SearchTopSolutions(categories, categorizedPlaces, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := plans[0]
+
+	const slotIndex = 2
+	alternatives, err := solution.RankSlotAlternatives(categories, categorizedPlaces, plan.PlaceIDS, slotIndex, 5, matching.DefaultScorer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alternatives) != 5 {
+		t.Fatalf("expected 5 alternatives, got %d", len(alternatives))
+	}
+
+	placesById := make(map[string]matching.Place)
+	for slot, category := range categories {
+		for _, place := range categorizedPlaces[slot].PlacesOf(category) {
+			placesById[place.GetPlaceId()] = place
+		}
+	}
+	for idx, alternative := range alternatives {
+		for _, placeId := range plan.PlaceIDS {
+			if alternative.Place.ID == placeId {
+				t.Errorf("alternative %s is already in the plan", placeId)
+			}
+		}
+		places := []matching.Place{placesById[plan.PlaceIDS[0]], placesById[plan.PlaceIDS[1]], placesById[alternative.Place.ID]}
+		if score := matching.Score(places); score != alternative.Score {
+			t.Errorf("expected score %f of alternative %s, got %f", score, alternative.Place.ID, alternative.Score)
+		}
+		if idx > 0 && alternative.Score > alternatives[idx-1].Score {
+			t.Errorf("alternatives are not ranked by score: %v", alternatives)
+		}
+	}
+}
+
+func TestRankSlotAlternativesUnavailablePlace(t *testing.T) {
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery}
+	categorizedPlaces := generateSlotPlaces(rand.New(rand.NewSource(6)), categories, 3)
+
+	placeIds := []string{"unknown", categorizedPlaces[1].EateryPlaces[0].GetPlaceId()}
+	if _, err := solution.RankSlotAlternatives(categories, categorizedPlaces, placeIds, 1, 5, matching.DefaultScorer); err == nil {
+		t.Error("expected an error for a place not available in its slot")
+	}
+	// the place of the replaced slot does not need to be available
+	if _, err := solution.RankSlotAlternatives(categories, categorizedPlaces, placeIds, 0, 5, matching.DefaultScorer); err != nil {
+		t.Error(err)
+	}
+}
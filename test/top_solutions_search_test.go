@@ -0,0 +1,128 @@
+package test
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+// generateSlotPlaces creates random places for slots, places of the same category are shared by slots
+// so that plans with permutations of the same places exist
+func generateSlotPlaces(random *rand.Rand, categories []POI.PlaceCategory, numPlaces int) []solution.CategorizedPlaces {
+	placesByCategory := make(map[POI.PlaceCategory][]matching.Place)
+	for _, category := range []POI.PlaceCategory{POI.PlaceCategoryEatery, POI.PlaceCategoryVisit} {
+		for idx := 0; idx < numPlaces*2; idx++ {
+			place := POI.Place{
+				ID:               string(category) + strconv.Itoa(idx),
+				Name:             string(category) + strconv.Itoa(idx),
+				PriceLevel:       random.Intn(5),
+				Rating:           1 + float32(random.Intn(41))/10,
+				UserRatingsTotal: random.Intn(10000),
+				URL:              "https://www.google.com/",
+			}
+			place.SetLocation([2]float64{-87.6 + random.Float64()/10, 41.8 + random.Float64()/10})
+			placesByCategory[category] = append(placesByCategory[category], matching.CreatePlace(place, category))
+		}
+	}
+
+	categorizedPlaces := make([]solution.CategorizedPlaces, len(categories))
+	for slot, category := range categories {
+		places := placesByCategory[category]
+		selected := make([]matching.Place, 0, numPlaces)
+		for _, idx := range random.Perm(len(places))[:numPlaces] {
+			selected = append(selected, places[idx])
+		}
+		switch category {
+		case POI.PlaceCategoryEatery:
+			categorizedPlaces[slot].EateryPlaces = selected
+		case POI.PlaceCategoryVisit:
+			categorizedPlaces[slot].VisitPlaces = selected
+		}
+	}
+	return categorizedPlaces
+}
+
+func TestSearchTopSolutionsMatchesEnumeration(t *testing.T) {
+	testCases := []struct {
+		categories []POI.PlaceCategory
+		numPlaces  int
+		numResults int64
+	}{
+		{[]POI.PlaceCategory{POI.PlaceCategoryVisit}, 6, 3},
+		{[]POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery, POI.PlaceCategoryVisit}, 6, 5},
+		{[]POI.PlaceCategory{POI.PlaceCategoryEatery, POI.PlaceCategoryVisit, POI.PlaceCategoryVisit, POI.PlaceCategoryVisit}, 5, 10},
+		{[]POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryVisit}, 2, 5},
+	}
+
+	random := rand.New(rand.NewSource(42))
+	for _, testCase := range testCases {
+		for round := 0; round < 10; round++ {
+			categorizedPlaces := generateSlotPlaces(random, testCase.categories, testCase.numPlaces)
+			expected, err := solution.EnumerateTopSolutions(testCase.categories, categorizedPlaces, testCase.numResults, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := solution.SearchTopSolutions(testCase.categories, categorizedPlaces, testCase.numResults, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("results of %v differ from enumeration, expected %v, got %v", testCase.categories, expected, results)
+			}
+		}
+	}
+}
+
+func TestSearchTopSolutionsEmptySlot(t *testing.T) {
+	categories := []POI.PlaceCategory{POI.PlaceCategoryVisit, POI.PlaceCategoryEatery}
+	categorizedPlaces := generateSlotPlaces(rand.New(rand.NewSource(1)), categories, 3)
+	categorizedPlaces[1].EateryPlaces = nil
+
+	if _, err := solution.SearchTopSolutions(categories, categorizedPlaces, 5, nil, nil); err == nil {
+		t.Error("expected an error for a slot without places")
+	}
+}
+
+func benchmarkTopSolutions(b *testing.B, numSlots int, numPlaces int,
+	find func([]POI.PlaceCategory, []solution.CategorizedPlaces, int64, *solution.PlaceConstraints, matching.Scorer) ([]solution.PlanningSolution, error)) {
+	categories := make([]POI.PlaceCategory, numSlots)
+	for slot := range categories {
+		categories[slot] = POI.PlaceCategoryVisit
+		if slot%3 == 1 {
+			categories[slot] = POI.PlaceCategoryEatery
+		}
+	}
+	categorizedPlaces := generateSlotPlaces(rand.New(rand.NewSource(7)), categories, numPlaces)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := find(categories, categorizedPlaces, solution.TopSolutionsCountDefault, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEnumerateTopSolutions3Slots(b *testing.B) {
+	benchmarkTopSolutions(b, 3, 20, solution.EnumerateTopSolutions)
+}
+
+func BenchmarkSearchTopSolutions3Slots(b *testing.B) {
+	benchmarkTopSolutions(b, 3, 20, solution.SearchTopSolutions)
+}
+
+func BenchmarkEnumerateTopSolutions4Slots(b *testing.B) {
+	benchmarkTopSolutions(b, 4, 20, solution.EnumerateTopSolutions)
+}
+
+func BenchmarkSearchTopSolutions4Slots(b *testing.B) {
+	benchmarkTopSolutions(b, 4, 20, solution.SearchTopSolutions)
+}
+
+func BenchmarkSearchTopSolutions6Slots(b *testing.B) {
+	benchmarkTopSolutions(b, 6, 20, solution.SearchTopSolutions)
+}
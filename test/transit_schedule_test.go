@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/POI"
+	"github.com/weihesdlegend/Vacation-planner/iowrappers"
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/solution"
+)
+
+// fixedTravelTimeProvider takes the same time between any two places
+type fixedTravelTimeProvider struct {
+	minutes int
+}
+
+func (provider fixedTravelTimeProvider) EstimateTravel(context context.Context, origin [2]float64, destination [2]float64, mode iowrappers.TravelMode) (iowrappers.TravelEstimate, error) {
+	return iowrappers.TravelEstimate{Mode: mode, DistanceInMeters: 1000, Minutes: provider.minutes}, nil
+}
+
+func createSlotRequests(intervals ...POI.TimeInterval) []solution.SlotRequest {
+	slots := make([]solution.SlotRequest, len(intervals))
+	for idx, interval := range intervals {
+		slots[idx] = solution.SlotRequest{TimeSlot: matching.TimeSlot{Slot: interval}, Category: POI.PlaceCategoryVisit}
+	}
+	return slots
+}
+
+func TestSpeedProfileTravelTimeProvider(t *testing.T) {
+	provider := iowrappers.CreateSpeedProfileTravelTimeProvider()
+	// about 1.11 km apart along a meridian, in the [lng, lat] order
+	origin := [2]float64{-87.62, 41.88}
+	destination := [2]float64{-87.62, 41.89}
+
+	walking, err := provider.EstimateTravel(context.Background(), origin, destination, iowrappers.TravelModeWalking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walking.DistanceInMeters < 1400 || walking.DistanceInMeters > 1500 {
+		t.Errorf("expected walking distance of about 1.45 km, got %.0f meters", walking.DistanceInMeters)
+	}
+	if walking.Minutes != 19 {
+		t.Errorf("expected 19 minutes of walking, got %d", walking.Minutes)
+	}
+
+	driving, _ := provider.EstimateTravel(context.Background(), origin, destination, iowrappers.TravelModeDriving)
+	if driving.Minutes >= walking.Minutes {
+		t.Errorf("expected driving to be faster than walking, got %d and %d minutes", driving.Minutes, walking.Minutes)
+	}
+
+	if _, err = provider.EstimateTravel(context.Background(), origin, destination, "flying"); err == nil {
+		t.Error("expected an error for unsupported travel mode")
+	}
+}
+
+func TestScheduleWithTransit(t *testing.T) {
+	locations := [][2]float64{{-87.62, 41.88}, {-87.63, 41.88}, {-87.64, 41.88}}
+	slots := createSlotRequests(POI.TimeInterval{Start: 10, End: 12}, POI.TimeInterval{Start: 12, End: 13}, POI.TimeInterval{Start: 14, End: 17})
+
+	visits, err := solution.ScheduleWithTransit(context.Background(), fixedTravelTimeProvider{minutes: 20},
+		iowrappers.TravelModeWalking, locations, slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []POI.MinuteInterval{{Start: 600, End: 720}, {Start: 740, End: 780}, {Start: 840, End: 1020}}
+	for idx, visit := range visits {
+		if visit.Start != expected[idx].Start || visit.End != expected[idx].End {
+			t.Errorf("expected visit %d during %v, got %d-%d", idx, expected[idx], visit.Start, visit.End)
+		}
+	}
+	if visits[0].Transit != nil {
+		t.Error("the first place should not have a transit leg")
+	}
+	if visits[1].Transit == nil || visits[1].Transit.Minutes != 20 || visits[1].Transit.Mode != iowrappers.TravelModeWalking {
+		t.Errorf("unexpected transit leg %+v", visits[1].Transit)
+	}
+
+	// 40 minutes of transit leaves 20 minutes in the one-hour slot
+	_, err = solution.ScheduleWithTransit(context.Background(), fixedTravelTimeProvider{minutes: 40},
+		iowrappers.TravelModeWalking, locations, slots)
+	if !errors.Is(err, solution.ErrTransitNotFit) {
+		t.Errorf("expected the plan to be rejected, got %v", err)
+	}
+}
+
+// nearbyPlacesTravelTimeProvider takes a short time to places of the first numNearbyPlaces of citySearchClient,
+// and all the time of a slot to other places
+type nearbyPlacesTravelTimeProvider struct {
+	numNearbyPlaces int
+}
+
+func (provider nearbyPlacesTravelTimeProvider) EstimateTravel(context context.Context, origin [2]float64, destination [2]float64, mode iowrappers.TravelMode) (iowrappers.TravelEstimate, error) {
+	if destination[0] < float64(provider.numNearbyPlaces)/1000 {
+		return iowrappers.TravelEstimate{Mode: mode, DistanceInMeters: 100, Minutes: 5}, nil
+	}
+	return iowrappers.TravelEstimate{Mode: mode, DistanceInMeters: 10000, Minutes: 180}, nil
+}
+
+func TestSolveWithTransit(t *testing.T) {
+	slots := []solution.SlotRequest{
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 8, End: 10}}, Category: POI.PlaceCategoryEatery},
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 10, End: 13}}, Category: POI.PlaceCategoryVisit},
+		{TimeSlot: matching.TimeSlot{Slot: POI.TimeInterval{Start: 13, End: 15}}, Category: POI.PlaceCategoryVisit},
+	}
+	createSolver := func(numNearbyPlaces int) (solution.Solver, iowrappers.RedisClient) {
+		redisURL, redisClient, _ := createRedis(t)
+		solver := solution.Solver{}
+		solver.Init(iowrappers.CreatePoiSearcherWithSearchClient(citySearchClient{cities: []string{"chicago"}, numPlaces: 6}, redisURL))
+		solver.TravelTimeProvider = nearbyPlacesTravelTimeProvider{numNearbyPlaces: numNearbyPlaces}
+		return solver, redisClient
+	}
+
+	// only plans visiting the two nearby places of lower scores fit, which are not among the top candidates
+	solver, redisClient := createSolver(2)
+	req := solution.PlanningRequest{Location: "chicago,USA", Slots: slots, Weekday: POI.DateMonday, NumPlans: 3, SearchRadius: 5000}
+	resp := solution.PlanningResponse{}
+	solver.Solve(context.Background(), redisClient, &req, &resp)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if len(resp.Solutions) != 3 {
+		t.Fatalf("expected 3 plans whose transit fits, got %d", len(resp.Solutions))
+	}
+	for _, plan := range resp.Solutions {
+		if len(plan.Schedule) != len(slots) {
+			t.Errorf("expected plan %v to be scheduled with transit", plan.PlaceIDS)
+		}
+		for _, placeId := range plan.PlaceIDS[1:] {
+			if placeId != "chicago_Visit_0" && placeId != "chicago_Visit_1" {
+				t.Errorf("expected only nearby places to be visited, got %v", plan.PlaceIDS)
+			}
+		}
+	}
+
+	solver, redisClient = createSolver(0)
+	req = solution.PlanningRequest{Location: "chicago,USA", Slots: slots, Weekday: POI.DateMonday, NumPlans: 3, SearchRadius: 5000}
+	resp = solution.PlanningResponse{}
+	solver.Solve(context.Background(), redisClient, &req, &resp)
+	if resp.Err == nil || resp.ErrorCode != solution.NoValidSolution {
+		t.Errorf("expected no valid solution if transit of no plan fits, got %d plans, %v", len(resp.Solutions), resp.Err)
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weihesdlegend/Vacation-planner/matching"
+	"github.com/weihesdlegend/Vacation-planner/planner"
+)
+
+func TestUserScoreWeightsKeepAbsentWeights(t *testing.T) {
+	redisURL, _, _ := createRedis(t)
+
+	myPlanner := planner.MyPlanner{}
+	myPlanner.Init("", redisURL, "", map[string]interface{}{
+		"server:templates_dir":               "../templates",
+		"server:search_client:provider":      "fixtures",
+		"server:search_client:fixtures_file": "../data/fixtures/chicago.json",
+	})
+	defer myPlanner.Destroy()
+	router := myPlanner.SetupRouter("10000").Handler
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/signup",
+		bytes.NewBufferString(`{"username": "weights_user", "password": "secret", "email": "weights_user@example.com"}`)))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/login",
+		bytes.NewBufferString(`{"username": "weights_user", "password": "secret"}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	cookies := recorder.Result().Cookies()
+
+	putWeights := func(body string) matching.ScoreWeights {
+		request := httptest.NewRequest(http.MethodPut, "/v1/users/score-weights", bytes.NewBufferString(body))
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		weights := matching.ScoreWeights{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &weights); err != nil {
+			t.Fatal(err)
+		}
+		return weights
+	}
+
+	// weights absent in the first request are the default weights
+	expected := matching.DefaultScoreWeights
+	expected.Rating = 2
+	if weights := putWeights(`{"rating": 2}`); weights != expected {
+		t.Errorf("expected weights %+v, got %+v", expected, weights)
+	}
+	// weights absent in later requests keep their saved values
+	expected.Distance = 3
+	if weights := putWeights(`{"distance": 3}`); weights != expected {
+		t.Errorf("expected weights %+v, got %+v", expected, weights)
+	}
+}
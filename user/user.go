@@ -13,10 +13,11 @@ const (
 )
 
 type User struct {
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	Email     string `json:"email"`
-	UserLevel string `json:"user_level"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	Email        string `json:"email"`
+	UserLevel    string `json:"user_level"`
+	ScoreWeights string `json:"-"` // JSON of the weights for scoring plans, empty for the default weights
 }
 
 type Credential struct {
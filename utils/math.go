@@ -23,6 +23,14 @@ func MaxInt(a int, b int) int {
 	return b
 }
 
+// MaxUint find the larger unsigned integer of the two input unsigned integers
+func MaxUint(a uint, b uint) uint {
+	if a >= b {
+		return a
+	}
+	return b
+}
+
 // refs: https://bit.ly/2GWSlAC
 // FindCenter calculates centroid given a set of points with geo coordinates
 func FindCenter(points [][]float64) ([]float64, error) {